@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// argFlag collects the repeatable -arg key=value flag.
+type argFlag []string
+
+func (a *argFlag) String() string { return strings.Join(*a, " ") }
+
+func (a *argFlag) Set(v string) error {
+	if key, _, ok := strings.Cut(v, "="); !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	*a = append(*a, v)
+	return nil
+}
+
+// applyArgFlags sets the -arg pairs on args, overriding keys that came from
+// -arguments. Values are strings unless the key ends in :int or :bool, as in
+// limit:int=10 or force:bool=true; numbers are stored as float64, like the
+// JSON arguments they are merged with.
+func applyArgFlags(args map[string]any, pairs []string) error {
+	for _, pair := range pairs {
+		key, raw, _ := strings.Cut(pair, "=")
+		name, typ, typed := strings.Cut(key, ":")
+		if name == "" {
+			return fmt.Errorf("-arg %q: missing key", pair)
+		}
+		if !typed {
+			args[name] = raw
+			continue
+		}
+		switch typ {
+		case "int":
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return fmt.Errorf("-arg %s: %q is not an integer", name, raw)
+			}
+			args[name] = float64(n)
+		case "bool":
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("-arg %s: %q is not a boolean", name, raw)
+			}
+			args[name] = b
+		default:
+			return fmt.Errorf("-arg %s: unknown type %q (use :int or :bool)", name, typ)
+		}
+	}
+	return nil
+}
@@ -3,17 +3,23 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	log "github.com/sirupsen/logrus"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
 )
 
 // MCP Errors
@@ -51,16 +57,73 @@ type ServerConfig struct {
 	Command string   `json:"command,omitempty"`
 	Env     []string `json:"env,omitempty"`
 	Args    []string `json:"args,omitempty"`
+	// Heartbeat is the longest an SSE stream may stay silent (e.g. "45s")
+	// before the connection is considered dead and re-established.
+	Heartbeat string `json:"heartbeat,omitempty"`
+	// Headers are sent with every request to an SSE server, e.g. for an
+	// auth proxy. Values may reference environment variables as $VAR or
+	// ${VAR}.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout bounds each initialize, tools/list and tools/call request to
+	// this server (e.g. "5m") in place of the client's overall deadline.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// expandHeaders returns headers with environment variables in the values
+// expanded, warning about variables that are not set.
+func expandHeaders(name string, headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		out[k] = os.Expand(v, func(env string) string {
+			val, ok := os.LookupEnv(env)
+			if !ok {
+				log.Warnf("server %s: header %s references unset variable %s", name, k, env)
+			}
+			return val
+		})
+	}
+	return out
 }
 
 // MultiClient can drive tools on multiple MCP servers
 type MultiClient struct {
+	mu            sync.RWMutex
 	clients       map[string]*mcpclient.Client
+	configs       map[string]ServerConfig
+	heartbeats    map[string]*heartbeat
+	timeouts      map[string]time.Duration
+	down          map[string]error
 	ctx           context.Context
-	toolToServer  map[string]string
+	toolToServer  map[string][]string
 	serverDetails map[string]*ServerDetails
 }
 
+// unixSocketPath extracts the socket path from a "unix:///path/to.sock" URL.
+func unixSocketPath(rawURL string) (string, bool) {
+	path, ok := strings.CutPrefix(rawURL, "unix://")
+	if !ok || path == "" {
+		return "", false
+	}
+	return path, true
+}
+
+// unixTransport returns an HTTP transport that dials the given Unix socket
+// for every request, regardless of the request's host.
+func unixTransport(socket string) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+}
+
+// clientInfo identifies this client to the servers during initialization.
+var clientInfo = mcp.Implementation{
+	Name:    "multi-mcp-client",
+	Version: "1.0.0",
+}
+
 type ServerDetails struct {
 	Tools []mcp.Tool
 	Info  *mcp.InitializeResult
@@ -74,12 +137,20 @@ func init() {
 	})
 }
 
-// LoadConfig reads your config.json
+// LoadConfig reads your config.json and validates it against
+// config.schema.json.
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("error reading config: %v", err)
 	}
+	problems, err := ValidateConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling config: %v", err)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config %s:\n- %s", path, strings.Join(problems, "\n- "))
+	}
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %v", err)
@@ -87,72 +158,184 @@ func LoadConfig(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// Only restricts the config to the named server, returning an error that
+// lists the configured servers when the name is unknown.
+func (c *Config) Only(name string) error {
+	sc, ok := c.MCPServers[name]
+	if !ok {
+		names := make([]string, 0, len(c.MCPServers))
+		for n := range c.MCPServers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("server %q not found in config; configured: %s", name, strings.Join(names, ", "))
+	}
+	c.MCPServers = map[string]ServerConfig{name: sc}
+	return nil
+}
+
 // NewMultiClient wires up one Client per server
 func NewMultiClient(ctx context.Context, cfg *Config) (*MultiClient, error) {
 	m := &MultiClient{
 		clients:       make(map[string]*mcpclient.Client),
+		configs:       make(map[string]ServerConfig),
+		heartbeats:    make(map[string]*heartbeat),
+		timeouts:      make(map[string]time.Duration),
+		down:          make(map[string]error),
 		ctx:           ctx,
-		toolToServer:  make(map[string]string),
+		toolToServer:  make(map[string][]string),
 		serverDetails: make(map[string]*ServerDetails),
 	}
 
 	for name, sc := range cfg.MCPServers {
-		var (
-			url string
-			cli *mcpclient.Client
-			err error
-		)
-
-		switch {
-		case sc.URL != "":
-			url = sc.URL
-			if !strings.HasSuffix(url, "/sse") {
-				sc.URL = strings.TrimRight(url, "/") + "/sse"
-			}
-			cli, err = mcpclient.NewSSEMCPClient(sc.URL)
-			if err != nil {
-				log.Errorf("creating client error: %v", err)
-				return nil, &SSEClientError{"SSE Client creation failed for " + name, err.Error()}
-			}
-		case sc.Command != "":
-			m.serverDetails[name] = &ServerDetails{}
-			cli, err = mcpclient.NewStdioMCPClient(sc.Command, sc.Env, sc.Args...)
-			if err != nil {
-				return nil, &SSEClientError{"STDIO Client creation failed for " + name, err.Error()}
-			}
-		default:
-			return nil, fmt.Errorf("server '%q' must have either url or command+args", name)
+		hb, err := newHeartbeat(name, sc)
+		if err != nil {
+			return nil, err
 		}
-
-		// **Register notification handler here**:
-		cli.OnNotification(func(n mcp.JSONRPCNotification) {
-			var payload struct {
-				Name   string         `json:"name"`
-				Output map[string]any `json:"output"`
+		if sc.Timeout != "" {
+			d, err := time.ParseDuration(sc.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("server %q has invalid timeout %q", name, sc.Timeout)
 			}
-			// raw params come in JSON format inside n.Params
-			raw, _ := json.Marshal(n.Params)
-			if err := json.Unmarshal(raw, &payload); err != nil {
-				log.Printf("[Notification][%s] failed to decode params: %v", name, err)
-				return
-			}
-			log.Infof("[Notification][%s] Tool '%s' result notification: %+v",
-				name, payload.Name, payload.Output)
-		})
-
+			m.timeouts[name] = d
+		}
+		cli, err := m.newClient(name, sc, hb)
+		if err != nil {
+			return nil, err
+		}
 		m.clients[name] = cli
+		m.configs[name] = sc
+		m.heartbeats[name] = hb
 		m.serverDetails[name] = &ServerDetails{}
 	}
 	return m, nil
 }
 
+// newClient creates the transport-specific client for one server and
+// registers its notification handler. A non-nil hb tracks SSE stream activity.
+func (m *MultiClient) newClient(name string, sc ServerConfig, hb *heartbeat) (*mcpclient.Client, error) {
+	var (
+		url string
+		cli *mcpclient.Client
+		err error
+	)
+
+	switch {
+	case sc.URL != "":
+		url = sc.URL
+		var rt http.RoundTripper = http.DefaultTransport
+		if socket, ok := unixSocketPath(url); ok {
+			// Any host works over the socket; the server only sees the path.
+			rt = unixTransport(socket)
+			sc.URL = "http://unix/sse"
+		} else if !strings.HasSuffix(url, "/sse") {
+			sc.URL = strings.TrimRight(url, "/") + "/sse"
+		}
+		if hb != nil {
+			hb.base = rt
+			rt = hb
+		}
+		var opts []transport.ClientOption
+		if rt != http.DefaultTransport {
+			opts = append(opts, mcpclient.WithHTTPClient(&http.Client{Transport: rt}))
+		}
+		if len(sc.Headers) > 0 {
+			opts = append(opts, mcpclient.WithHeaders(expandHeaders(name, sc.Headers)))
+		}
+		cli, err = mcpclient.NewSSEMCPClient(sc.URL, opts...)
+		if err != nil {
+			log.Errorf("creating client error: %v", err)
+			return nil, &SSEClientError{"SSE Client creation failed for " + name, err.Error()}
+		}
+	case sc.Command != "":
+		if len(sc.Headers) > 0 {
+			log.Warnf("server %s: headers are only sent to url servers; ignoring them", name)
+		}
+		cli, err = mcpclient.NewStdioMCPClient(sc.Command, sc.Env, sc.Args...)
+		if err != nil {
+			return nil, &SSEClientError{"STDIO Client creation failed for " + name, err.Error()}
+		}
+	default:
+		return nil, fmt.Errorf("server '%q' must have either url or command+args", name)
+	}
+
+	// **Register notification handler here**:
+	cli.OnNotification(func(n mcp.JSONRPCNotification) {
+		var payload struct {
+			Name   string         `json:"name"`
+			Output map[string]any `json:"output"`
+		}
+		// raw params come in JSON format inside n.Params
+		raw, _ := json.Marshal(n.Params)
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			log.Printf("[Notification][%s] failed to decode params: %v", name, err)
+			return
+		}
+		log.Infof("[Notification][%s] Tool '%s' result notification: %+v",
+			name, payload.Name, payload.Output)
+	})
+	return cli, nil
+}
+
+// markDown drops a server that failed to start, initialize or list its
+// tools, pruning its tools so the LLM never sees them, and remembers why.
+func (m *MultiClient) markDown(name string, reason error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cli := m.clients[name]; cli != nil {
+		cli.Close()
+	}
+	if hb := m.heartbeats[name]; hb != nil {
+		hb.stop()
+	}
+	delete(m.clients, name)
+	delete(m.heartbeats, name)
+	delete(m.serverDetails, name)
+	for tool, servers := range m.toolToServer {
+		servers = slices.DeleteFunc(servers, func(s string) bool { return s == name })
+		if len(servers) == 0 {
+			delete(m.toolToServer, tool)
+		} else {
+			m.toolToServer[tool] = servers
+		}
+	}
+	m.down[name] = reason
+}
+
+// Unavailable reports whether a "server.tool" reference names a configured
+// server that was dropped, returning a message explaining why.
+func (m *MultiClient) Unavailable(tool string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, sep := range []string{".", "__"} {
+		if prefix, _, found := strings.Cut(tool, sep); found {
+			if reason, down := m.down[prefix]; down {
+				return fmt.Sprintf("server %q is unavailable: %v", prefix, reason), true
+			}
+		}
+	}
+	return "", false
+}
+
+// connected returns a snapshot of the current clients, so callers can talk to
+// the servers without holding the lock.
+func (m *MultiClient) connected() map[string]*mcpclient.Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]*mcpclient.Client, len(m.clients))
+	for name, cli := range m.clients {
+		out[name] = cli
+	}
+	return out
+}
+
 // StartAll and InitializeAll
 func (m *MultiClient) StartAll() error {
 	var upServers []string
-	for name, cli := range m.clients {
+	for name, cli := range m.connected() {
 		if err := cli.Start(m.ctx); err != nil {
 			log.Warnf("Server %q failed to start: %v; marking as down", name, err)
-			delete(m.clients, name)
+			m.markDown(name, err)
 			continue
 		}
 		upServers = append(upServers, name)
@@ -166,43 +349,130 @@ func (m *MultiClient) StartAll() error {
 
 func (m *MultiClient) InitializeAll() error {
 	var inited []string
-	for name, cli := range m.clients {
+	for name, cli := range m.connected() {
 		req := mcp.InitializeRequest{}
 		req.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-		req.Params.ClientInfo = mcp.Implementation{
-			Name:    "multi-mcp-client",
-			Version: "1.0.0",
-		}
-		res, err := cli.Initialize(m.ctx, req)
+		req.Params.ClientInfo = clientInfo
+		ctx, cancel := m.serverContext(name)
+		res, err := cli.Initialize(ctx, req)
+		cancel()
 		if err != nil {
 			log.Warnf("Initialization failed for %q: %v; dropping", name, err)
-			delete(m.clients, name)
+			m.markDown(name, err)
 			continue
 		}
-		m.serverDetails[name].Info = res
+		m.mu.Lock()
+		if details := m.serverDetails[name]; details != nil {
+			details.Info = res
+		}
+		m.mu.Unlock()
 		inited = append(inited, name)
 	}
 	if len(inited) == 0 {
 		return &SSEClientStartError{fmt.Sprintf("No servers found, Initialization failed: %v", len(inited))}
 	}
 	log.Infof("Initialized Server: %v", inited)
+
+	// Watch the surviving SSE streams so silent connections get replaced.
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, name := range inited {
+		if hb := m.heartbeats[name]; hb != nil {
+			go m.watch(name, hb)
+		}
+	}
 	return nil
 }
 
-// ListAllToolsRaw populates toolToServer
+// Connect creates the clients for cfg, starts and initializes them. With a
+// positive wait the whole sequence is retried, with growing pauses, until
+// at least one server comes up or wait has elapsed, so the client can be
+// launched alongside servers that are still starting.
+func Connect(ctx context.Context, cfg *Config, wait time.Duration) (*MultiClient, error) {
+	deadline := time.Now().Add(wait)
+	delay := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		cli, err := connectOnce(ctx, cfg)
+		if err == nil {
+			return cli, nil
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return nil, err
+		}
+		pause := min(delay, left)
+		log.Warnf("Connect attempt %d failed: %v; retrying in %s", attempt, err, pause)
+		select {
+		case <-time.After(pause):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (gave up waiting: %v)", err, ctx.Err())
+		}
+		delay = min(delay*2, 5*time.Second)
+	}
+}
+
+func connectOnce(ctx context.Context, cfg *Config) (*MultiClient, error) {
+	cli, err := NewMultiClient(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("client init: %w", err)
+	}
+	if err := cli.StartAll(); err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("StartAll: %w", err)
+	}
+	if err := cli.InitializeAll(); err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("InitializeAll: %w", err)
+	}
+	return cli, nil
+}
+
+// ListAllToolsRaw populates toolToServer. Tools exposed by more than one
+// server are kept for all of them and must be called as "server.tool".
 func (m *MultiClient) ListAllToolsRaw() (map[string][]mcp.Tool, error) {
 	all := make(map[string][]mcp.Tool)
-	for name, cli := range m.clients {
-		res, err := cli.ListTools(m.ctx, mcp.ListToolsRequest{})
+	m.mu.Lock()
+	m.toolToServer = make(map[string][]string)
+	m.mu.Unlock()
+	for name, cli := range m.connected() {
+		ctx, cancel := m.serverContext(name)
+		res, err := cli.ListTools(ctx, mcp.ListToolsRequest{})
+		cancel()
 		if err != nil {
-			return nil, &SSEClientError{"ListTools for " + name, err.Error()}
+			log.Warnf("ListTools failed for %q: %v; dropping", name, err)
+			m.markDown(name, err)
+			continue
 		}
-		all[name] = res.Tools
-		m.serverDetails[name].Tools = res.Tools
-		for _, t := range res.Tools {
-			m.toolToServer[t.Name] = name
+		m.mu.Lock()
+		// The server may have been dropped while its tools were listed.
+		if details := m.serverDetails[name]; details != nil {
+			all[name] = res.Tools
+			details.Tools = res.Tools
+			for _, t := range res.Tools {
+				m.toolToServer[t.Name] = append(m.toolToServer[t.Name], name)
+			}
+		}
+		m.mu.Unlock()
+	}
+
+	// Sorting the server lists below writes to them, so hold the write lock.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.clients) == 0 {
+		return nil, &SSEClientError{"ListTools", "no servers left after tool discovery"}
+	}
+
+	var conflicts []string
+	for tool, servers := range m.toolToServer {
+		if len(servers) > 1 {
+			sort.Strings(servers)
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", tool, strings.Join(servers, ", ")))
 		}
 	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		log.Warnf("Tools served by multiple servers, call them as server.tool: %s", strings.Join(conflicts, "; "))
+	}
 	return all, nil
 }
 
@@ -219,14 +489,83 @@ func (m *MultiClient) ListToolsJSON() (string, error) {
 	return string(b), nil
 }
 
+// ResolveTool maps a tool reference, either "tool" or "server.tool", to the
+// server that serves it and the bare tool name. "server__tool" is accepted
+// too, as LLM function names cannot contain dots. A bare name served by
+// several servers is rejected as ambiguous.
+func (m *MultiClient) ResolveTool(tool string) (srv, name string, err error) {
+	if msg, down := m.Unavailable(tool); down {
+		return "", "", errors.New(msg)
+	}
+
+	m.mu.RLock()
+	name = tool
+	for _, sep := range []string{".", "__"} {
+		if prefix, rest, found := strings.Cut(tool, sep); found {
+			if m.clients[prefix] != nil {
+				srv, name = prefix, rest
+				break
+			}
+		}
+	}
+	servers := slices.Clone(m.toolToServer[name])
+	m.mu.RUnlock()
+
+	switch {
+	case srv != "" && slices.Contains(servers, srv):
+		return srv, name, nil
+	case srv == "" && len(servers) == 1:
+		return servers[0], name, nil
+	case srv == "" && len(servers) > 1:
+		return "", "", fmt.Errorf("tool %q is served by multiple servers (%s); use server.tool to choose one",
+			tool, strings.Join(servers, ", "))
+	}
+	return "", "", fmt.Errorf("unknown tool %q; available: %s",
+		tool, strings.Join(m.AvailableTools(), ", "))
+}
+
+// Tool returns the discovered definition of a tool reference.
+func (m *MultiClient) Tool(tool string) (mcp.Tool, error) {
+	srv, name, err := m.ResolveTool(tool)
+	if err != nil {
+		return mcp.Tool{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if details := m.serverDetails[srv]; details != nil {
+		for _, t := range details.Tools {
+			if t.Name == name {
+				return t, nil
+			}
+		}
+	}
+	return mcp.Tool{}, fmt.Errorf("tool %q not found on server %q", name, srv)
+}
+
+// AvailableTools returns the sorted "server.tool" names discovered so far.
+func (m *MultiClient) AvailableTools() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.toolToServer))
+	for t, servers := range m.toolToServer {
+		for _, srv := range servers {
+			names = append(names, srv+"."+t)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // CallTool dispatches the right MCPClient.CallTool
 func (m *MultiClient) CallTool(tool string, args map[string]any) (string, error) {
-	ts := strings.Split(tool, ".")
-	srv, ok := m.toolToServer[ts[1]]
-	if !ok {
-		return "", &SSEClientError{"CallTool", "no server for tool " + ts[1]}
+	srv, name, err := m.ResolveTool(tool)
+	if err != nil {
+		return "", err
+	}
+	cli := m.client(srv)
+	if cli == nil {
+		return "", &SSEClientError{"CallTool", fmt.Sprintf("server %q is no longer connected", srv)}
 	}
-	cli := m.clients[srv]
 
 	// Build and send the CallToolRequest
 	req := mcp.CallToolRequest{
@@ -234,17 +573,22 @@ func (m *MultiClient) CallTool(tool string, args map[string]any) (string, error)
 			Method: "tools/call",
 		},
 	}
-	req.Params.Name = ts[1]
+	req.Params.Name = name
 	req.Params.Arguments = args
 
-	res, err := cli.CallTool(m.ctx, req)
+	ctx, cancel := m.serverContext(srv)
+	defer cancel()
+	res, err := cli.CallTool(ctx, req)
 	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && m.timeouts[srv] > 0 {
+			err = fmt.Errorf("%w (server %q timeout %s)", err, srv, m.timeouts[srv])
+		}
 		return "", &SSEClientError{"CallTool", err.Error()}
 	}
 
 	// Start assembling a detailed report
 	var b strings.Builder
-	b.WriteString(fmt.Sprintf("Tool '%s' completed.\n", ts[1]))
+	b.WriteString(fmt.Sprintf("Tool '%s' completed.\n", name))
 	b.WriteString(fmt.Sprintf("  IsError: %v\n", res.IsError))
 
 	if len(res.Content) == 0 {
@@ -282,84 +626,181 @@ func indent(s, prefix string) string {
 	return strings.Join(lines, "\n")
 }
 
+// serverContext returns the context for one request to a server. A server
+// with its own timeout gets that deadline instead of the overall one, so a
+// slow remote server is not cut short by a budget sized for fast local ones.
+func (m *MultiClient) serverContext(name string) (context.Context, context.CancelFunc) {
+	if d := m.timeouts[name]; d > 0 {
+		return context.WithTimeout(context.WithoutCancel(m.ctx), d)
+	}
+	return context.WithCancel(m.ctx)
+}
+
+// client returns the current client for a server, or nil if it is down.
+func (m *MultiClient) client(name string) *mcpclient.Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.clients[name]
+}
+
 func (m *MultiClient) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, hb := range m.heartbeats {
+		if hb != nil {
+			hb.stop()
+		}
+	}
 	for _, cli := range m.clients {
 		cli.Close()
 	}
 }
 
-// ToolCall is the LLM→JSON schema
-type ToolCall struct {
-	Tool      string         `json:"tool"`
-	Arguments map[string]any `json:"arguments"`
+// loadArguments parses the tool arguments from -arguments or, when set,
+// -arguments-file. Giving both is an error.
+func loadArguments(argsJSON, argsFile string) (map[string]any, error) {
+	source := "-arguments"
+	if argsFile != "" {
+		both := false
+		flag.Visit(func(f *flag.Flag) { both = both || f.Name == "arguments" })
+		if both {
+			return nil, fmt.Errorf("-arguments and -arguments-file are mutually exclusive")
+		}
+		data, err := os.ReadFile(argsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read -arguments-file: %v", err)
+		}
+		argsJSON, source = string(data), argsFile
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return nil, fmt.Errorf("failed to parse %s JSON: %v", source, err)
+	}
+	if args == nil {
+		return nil, fmt.Errorf("%s must hold a JSON object", source)
+	}
+	return args, nil
 }
 
 func main() {
 	var (
-		cfgPath  = flag.String("config", "", "Path to config.json")
-		baseURL  = flag.String("baseurl", "http://localhost:1234/sse", "Single SSE URL")
-		toolName = flag.String("tool", "", "Name of the tool to call")
-		argsJSON = flag.String("arguments", "{}", "JSON string of the tool's arguments")
+		cfgPath    = flag.String("config", "", "Path to config.json")
+		baseURL    = flag.String("baseurl", "http://localhost:1234/sse", "Single SSE URL")
+		toolName   = flag.String("tool", "", "Name of the tool to call")
+		argsJSON   = flag.String("arguments", "{}", "JSON string of the tool's arguments")
+		argsFile   = flag.String("arguments-file", "", "File holding the tool's arguments as JSON (instead of -arguments)")
+		dryRun     = flag.Bool("dry-run", false, "Print the validated tool call without executing it")
+		stream     = flag.Bool("stream", false, "Stream LLM output to the terminal as it is generated")
+		session    = flag.String("session", "", "Session id whose conversation history is loaded and saved")
+		maxSteps   = flag.Int("max-steps", 5, "Maximum number of tool calls the LLM may chain before stopping")
+		serverName = flag.String("server", "", "Restrict discovery and dispatch to this configured server")
+		promptFile = flag.String("prompt-file", "", "File holding the system prompt template; {{.Tools}} is replaced by the tool list")
+		direct     = flag.Bool("direct", false, "Call -tool with -arguments as given, without the LLM (no OPENAI_API_KEY needed)")
+		listTools  = flag.Bool("list-tools", false, "Print the tools of the configured servers and exit")
+		health     = flag.Bool("health", false, "Ping the configured servers, print their status and latency and exit (status 1 if any is down)")
+		wait       = flag.Duration("wait", 0, "Keep retrying to start and initialize the servers for up to this long (e.g. 30s)")
+		argPairs   argFlag
 	)
+	flag.Var(&argPairs, "arg", "Tool argument as key=value, repeatable and applied over -arguments; key:int=N and key:bool=true give typed values")
 	flag.Parse()
 
-	if *toolName == "" {
+	if *toolName == "" && !*listTools && !*health {
 		log.Fatal("Please supply -tool")
 	}
 
 	// Parse the arguments JSON into a map
-	var userArgs map[string]any
-	if err := json.Unmarshal([]byte(*argsJSON), &userArgs); err != nil {
-		log.Fatalf("Failed to parse -arguments JSON: %v", err)
+	userArgs, err := loadArguments(*argsJSON, *argsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := applyArgFlags(userArgs, argPairs); err != nil {
+		log.Fatal(err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second+*wait)
 	defer cancel()
 
 	// Initialize MCP client(s)
-	var cli *MultiClient
-	var err error
+	var cfg *Config
 	if *cfgPath != "" {
-		cfg, e := LoadConfig(*cfgPath)
+		loaded, e := LoadConfig(*cfgPath)
 		if e != nil {
 			log.Fatalf("LoadConfig: %v", e)
 		}
-		cli, err = NewMultiClient(ctx, cfg)
+		cfg = loaded
 	} else {
-		cfg := &Config{
+		cfg = &Config{
 			MCPServers: map[string]ServerConfig{
 				"default": {URL: *baseURL},
 			},
 		}
-		cli, err = NewMultiClient(ctx, cfg)
 	}
+	if *serverName != "" {
+		if e := cfg.Only(*serverName); e != nil {
+			log.Fatalf("Config: %v", e)
+		}
+	}
+	cli, err := Connect(ctx, cfg, *wait)
 	if err != nil {
-		log.Fatalf("Client init: %v", err)
+		log.Fatal(err)
 	}
 	defer cli.Close()
 
-	if startErr := cli.StartAll(); startErr != nil {
-		log.Fatalf("StartAll: %v", startErr)
-	}
-	if initErr := cli.InitializeAll(); initErr != nil {
-		log.Fatalf("InitializeAll: %v", initErr)
+	log.Debug("Initialized all servers")
+
+	if *health {
+		if down := printHealth(os.Stdout, cli.Health()); down > 0 {
+			cli.Close()
+			os.Exit(1)
+		}
+		return
 	}
-	fmt.Println("[DEBUG] Initialized all servers")
 
 	// List available tools to include in the LLM system prompt
 	toolsJSON, err := cli.ListToolsJSON()
 	if err != nil {
 		log.Fatalf("ListTools: %v", err)
 	}
+	if *listTools {
+		fmt.Println(toolsJSON)
+		return
+	}
+	if msg, down := cli.Unavailable(*toolName); down {
+		fmt.Printf("Cannot call %q: %s\n", *toolName, msg)
+		return
+	}
+
+	// Direct mode skips the LLM entirely: validate and dispatch as given.
+	if *direct {
+		tool, err := cli.Tool(*toolName)
+		if err != nil {
+			log.Fatalf("Tool: %v", err)
+		}
+		if problems := ValidateArguments(tool, userArgs); len(problems) > 0 {
+			log.Fatalf("Invalid arguments for %q:\n- %s", *toolName, strings.Join(problems, "\n- "))
+		}
+		if *dryRun {
+			out, err := json.MarshalIndent(ToolCall{Tool: *toolName, Arguments: userArgs}, "", "  ")
+			if err != nil {
+				log.Fatalf("Marshal tool call: %v", err)
+			}
+			fmt.Println(string(out))
+			return
+		}
+		result, err := cli.CallTool(*toolName, userArgs)
+		if err != nil {
+			cli.Close()
+			log.Fatalf("Tool call: %v", err)
+		}
+		fmt.Printf("Tool '%s' result:\n%s\n", *toolName, result)
+		return
+	}
 
 	// Build the system prompt with the list of tools
-	systemPrompt := fmt.Sprintf(`
-You are an assistant that validates and normalizes tool calls for MCP, based on the available tools.
-Here are the available tools:
-%s
-Respond only with JSON matching the schema:
-{"tool":"<tool_name>", "arguments": {<key>: <value>, ...}}
-`, toolsJSON)
+	systemPrompt, err := buildSystemPrompt(*promptFile, toolsJSON)
+	if err != nil {
+		log.Fatalf("System prompt: %v", err)
+	}
 
 	// Build the user message containing the raw tool name and arguments
 	inputCall := ToolCall{
@@ -369,51 +810,79 @@ Respond only with JSON matching the schema:
 	inputBytes, _ := json.Marshal(inputCall)
 	userPrompt := string(inputBytes)
 
-	history := []llms.MessageContent{
-		llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt),
-		llms.TextParts(llms.ChatMessageTypeHuman, userPrompt),
+	var history []llms.MessageContent
+	if *session != "" {
+		prior, loadErr := LoadHistory(*session)
+		if loadErr != nil {
+			log.Fatalf("LoadHistory: %v", loadErr)
+		}
+		history = prior
+		defer func() {
+			if saveErr := SaveHistory(*session, history); saveErr != nil {
+				log.Errorf("SaveHistory: %v", saveErr)
+			}
+		}()
 	}
+	history = append(withSystemPrompt(history, systemPrompt),
+		llms.TextParts(llms.ChatMessageTypeHuman, userPrompt))
 
 	// Initialize LLM
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("set OPENAI_API_KEY")
-	}
-	llm, err := openai.New(openai.WithModel("gpt-4"), openai.WithToken(apiKey))
+	llm, err := newLLM()
 	if err != nil {
 		log.Fatalf("OpenAI init: %v", err)
 	}
 
-	// Ask the LLM to produce a validated ToolCall JSON
-	resp, err := llm.GenerateContent(ctx, history)
-	if err != nil {
-		log.Fatalf("LLM error: %v", err)
-	}
-
-	reply := resp.Choices[0].Content
-	fmt.Printf("[DEBUG] LLM reply: %s\n", reply)
+	// Agent loop: let the LLM pick a tool, run it, and feed the result back
+	// until it answers with "none" or the step budget is spent.
+	var previous string
+	for step := 1; step <= *maxSteps; step++ {
+		var tc ToolCall
+		tc, history, err = nextToolCall(ctx, llm, cli, history, *stream)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		log.Debugf("Step %d parsed tool call: %+v", step, tc)
+
+		if isNoToolDecision(tc) {
+			switch {
+			case tc.Answer != "":
+				fmt.Println(tc.Answer)
+			case step == 1:
+				fmt.Println("No tool needed: none of the available tools applies to this request.")
+			}
+			return
+		}
 
-	// Strip markdown fencing if present
-	clean := reply
-	if parts := strings.Split(reply, "```"); len(parts) > 1 {
-		clean = strings.TrimSpace(parts[1])
-	}
+		if *dryRun {
+			out, err := json.MarshalIndent(tc, "", "  ")
+			if err != nil {
+				log.Fatalf("Marshal tool call: %v", err)
+			}
+			fmt.Println(string(out))
+			return
+		}
 
-	var tc ToolCall
-	if er := json.Unmarshal([]byte(clean), &tc); er != nil {
-		log.Fatalf("Parse JSON: %v", er)
-	}
-	fmt.Printf("[DEBUG] Parsed tool call: %+v\n", tc)
+		// Guard against the LLM repeating the exact same call forever.
+		callArgs, _ := json.Marshal(tc.Arguments)
+		callKey := tc.Tool + string(callArgs)
+		if callKey == previous {
+			fmt.Printf("Stopping: the LLM repeated the previous tool call %s(%s)\n", tc.Tool, callArgs)
+			return
+		}
+		previous = callKey
 
-	if tc.Tool == "none" {
-		fmt.Println("No tool needed.")
-		return
+		// Dispatch the validated tool call
+		result, err := cli.CallTool(tc.Tool, tc.Arguments)
+		if err != nil {
+			log.Errorf("Tool call: %v", err)
+			history = append(history, toolResponse(tc, fmt.Sprintf("The tool call failed: %v", err)))
+			return
+		}
+		fmt.Printf("Tool '%s' result:\n%s\n", tc.Tool, result)
+		history = append(history, toolResponse(tc, result))
 	}
-
-	// Dispatch the validated tool call
-	result, err := cli.CallTool(tc.Tool, tc.Arguments)
-	if err != nil {
-		log.Fatalf("Tool call: %v", err)
+	if *maxSteps > 1 {
+		fmt.Printf("Stopping after %d tool calls (-max-steps)\n", *maxSteps)
 	}
-	fmt.Printf("Tool '%s' result:\n%s\n", tc.Tool, result)
 }
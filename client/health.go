@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ServerHealth is the state of one configured server as seen by Health.
+type ServerHealth struct {
+	Name    string
+	Up      bool
+	Latency time.Duration // round trip of the ping; zero when down
+	Err     error         // why the server is down
+}
+
+// Health pings every connected server at once and reports, for each
+// configured server, whether it answered and how fast. Servers dropped
+// earlier are reported down with the reason they were dropped; a failed
+// ping does not drop a server.
+func (m *MultiClient) Health() []ServerHealth {
+	m.mu.RLock()
+	names := make([]string, 0, len(m.clients))
+	for name := range m.clients {
+		names = append(names, name)
+	}
+	report := make([]ServerHealth, 0, len(m.clients)+len(m.down))
+	for name, reason := range m.down {
+		report = append(report, ServerHealth{Name: name, Err: reason})
+	}
+	m.mu.RUnlock()
+
+	results := make([]ServerHealth, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = m.ping(name)
+		}()
+	}
+	wg.Wait()
+
+	report = append(report, results...)
+	sort.Slice(report, func(i, j int) bool { return report[i].Name < report[j].Name })
+	return report
+}
+
+// ping sends an MCP ping to one server, bounded by its timeout.
+func (m *MultiClient) ping(name string) ServerHealth {
+	h := ServerHealth{Name: name}
+	cli := m.client(name)
+	if cli == nil {
+		h.Err = fmt.Errorf("not connected")
+		return h
+	}
+	ctx, cancel := m.serverContext(name)
+	defer cancel()
+	start := time.Now()
+	if err := cli.Ping(ctx); err != nil {
+		h.Err = err
+		return h
+	}
+	h.Up, h.Latency = true, time.Since(start)
+	return h
+}
+
+// printHealth writes the health report as a table and returns the number of
+// servers that are down.
+func printHealth(w io.Writer, report []ServerHealth) int {
+	down := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tSTATUS\tLATENCY\tERROR")
+	for _, h := range report {
+		if h.Up {
+			fmt.Fprintf(tw, "%s\tup\t%s\t\n", h.Name, h.Latency.Round(time.Microsecond))
+			continue
+		}
+		down++
+		fmt.Fprintf(tw, "%s\tdown\t-\t%v\n", h.Name, h.Err)
+	}
+	tw.Flush()
+	return down
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	log "github.com/sirupsen/logrus"
+)
+
+// heartbeat tracks activity on one server's SSE stream. Every byte read from
+// the stream, including keep-alive pings, counts as activity.
+type heartbeat struct {
+	base     http.RoundTripper
+	timeout  time.Duration
+	last     atomic.Int64 // unix nanoseconds of the latest stream activity
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newHeartbeat returns a heartbeat for the server, or nil when the server
+// has no heartbeat configured.
+func newHeartbeat(name string, sc ServerConfig) (*heartbeat, error) {
+	if sc.URL == "" || sc.Heartbeat == "" {
+		return nil, nil
+	}
+	timeout, err := time.ParseDuration(sc.Heartbeat)
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("server %q has invalid heartbeat %q", name, sc.Heartbeat)
+	}
+	return &heartbeat{base: http.DefaultTransport, timeout: timeout, done: make(chan struct{})}, nil
+}
+
+func (h *heartbeat) touch() { h.last.Store(time.Now().UnixNano()) }
+
+// idle reports whether the stream has been silent for longer than the timeout.
+func (h *heartbeat) idle() bool {
+	last := h.last.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) > h.timeout
+}
+
+func (h *heartbeat) stop() { h.stopOnce.Do(func() { close(h.done) }) }
+
+// RoundTrip implements http.RoundTripper, wrapping the body of SSE stream
+// responses so reads are recorded as activity.
+func (h *heartbeat) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := h.base.RoundTrip(req)
+	if err != nil || !strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		return resp, err
+	}
+	h.touch()
+	resp.Body = &activityReader{ReadCloser: resp.Body, hb: h}
+	return resp, nil
+}
+
+type activityReader struct {
+	io.ReadCloser
+	hb *heartbeat
+}
+
+func (r *activityReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.hb.touch()
+	}
+	return n, err
+}
+
+// maxReconnects is how many consecutive reconnect attempts watch makes
+// before dropping a server whose stream went silent.
+const maxReconnects = 3
+
+// errServerDropped reports that a server was dropped while it was being
+// reconnected, so the new connection was discarded.
+var errServerDropped = errors.New("server was dropped")
+
+// watch reconnects the server once its stream goes silent, retrying on every
+// tick. After maxReconnects failed attempts the server is marked down so
+// calls fail fast instead of waiting on a dead stream. It returns when hb is
+// stopped or has been replaced by the heartbeat of the new connection.
+func (m *MultiClient) watch(name string, hb *heartbeat) {
+	ticker := time.NewTicker(hb.timeout / 2)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-hb.done:
+			return
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if !hb.idle() {
+				continue
+			}
+			log.Warnf("Server %q sent nothing for %s; reconnecting", name, hb.timeout)
+			err := m.reconnect(name)
+			if errors.Is(err, errServerDropped) {
+				return
+			}
+			if err != nil {
+				failures++
+				if failures < maxReconnects {
+					log.Warnf("Reconnect to %q failed: %v; will retry", name, err)
+					continue
+				}
+				log.Warnf("Reconnect to %q failed %d times: %v; dropping", name, failures, err)
+				m.markDown(name, fmt.Errorf("stream silent for over %s and reconnect failed: %w", hb.timeout, err))
+				return
+			}
+			log.Infof("Reconnected to server %q", name)
+			hb.stop()
+			return
+		}
+	}
+}
+
+// reconnect builds a fresh client for the server, starts and initializes it,
+// and swaps it in place of the current one. If the server was dropped in the
+// meantime, the new client is closed and errServerDropped returned.
+func (m *MultiClient) reconnect(name string) error {
+	m.mu.RLock()
+	sc := m.configs[name]
+	m.mu.RUnlock()
+	hb, err := newHeartbeat(name, sc)
+	if err != nil {
+		return err
+	}
+	cli, err := m.newClient(name, sc, hb)
+	if err != nil {
+		return err
+	}
+	if err := cli.Start(m.ctx); err != nil {
+		cli.Close()
+		return err
+	}
+	req := mcp.InitializeRequest{}
+	req.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	req.Params.ClientInfo = clientInfo
+	ctx, cancel := m.serverContext(name)
+	res, err := cli.Initialize(ctx, req)
+	cancel()
+	if err != nil {
+		cli.Close()
+		return err
+	}
+
+	m.mu.Lock()
+	details := m.serverDetails[name]
+	if details == nil {
+		m.mu.Unlock()
+		cli.Close()
+		if hb != nil {
+			hb.stop()
+		}
+		return errServerDropped
+	}
+	old := m.clients[name]
+	m.clients[name] = cli
+	m.heartbeats[name] = hb
+	details.Info = res
+	m.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	if hb != nil {
+		go m.watch(name, hb)
+	}
+	return nil
+}
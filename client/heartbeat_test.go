@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// stallingServer serves MCP over SSE with frequent keep-alive pings. Once
+// stallFirst is set the first stream stops delivering anything, as if a
+// proxy had silently dropped it; while refuse is set new streams are
+// rejected.
+type stallingServer struct {
+	sse        http.Handler
+	stallFirst atomic.Bool
+	refuse     atomic.Bool
+	streams    atomic.Int32
+}
+
+func (s *stallingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sse.ServeHTTP(w, r)
+		return
+	}
+	if s.refuse.Load() {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	stalled := func() bool { return false }
+	if s.streams.Add(1) == 1 {
+		stalled = s.stallFirst.Load
+	}
+	s.sse.ServeHTTP(&stallingWriter{ResponseWriter: w, stalled: stalled}, r)
+}
+
+// stallingWriter drops everything written to the stream while stalled.
+type stallingWriter struct {
+	http.ResponseWriter
+	stalled func() bool
+}
+
+func (w *stallingWriter) Write(p []byte) (int, error) {
+	if w.stalled() {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *stallingWriter) Flush() {
+	if !w.stalled() {
+		w.ResponseWriter.(http.Flusher).Flush()
+	}
+}
+
+// connectStalling starts a stallingServer and a client connected to it with
+// a short heartbeat.
+func connectStalling(t *testing.T) (*stallingServer, *MultiClient) {
+	t.Helper()
+	mcpServer := server.NewMCPServer("stalling", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("echo"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("echo"), nil
+	})
+	srv := &stallingServer{sse: server.NewSSEServer(mcpServer,
+		server.WithKeepAliveInterval(20*time.Millisecond),
+		server.WithUseFullURLForMessageEndpoint(false),
+	)}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.CloseClientConnections()
+		ts.Close()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := &Config{MCPServers: map[string]ServerConfig{
+		"stall": {URL: ts.URL, Heartbeat: "200ms"},
+	}}
+	m, err := Connect(ctx, cfg, 0)
+	if err != nil {
+		cancel()
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() {
+		m.Close()
+		cancel()
+	})
+	if _, err := m.ListAllToolsRaw(); err != nil {
+		t.Fatalf("ListAllToolsRaw: %v", err)
+	}
+	return srv, m
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within %s", timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHeartbeatReconnectsStalledStream(t *testing.T) {
+	srv, m := connectStalling(t)
+	old := m.client("stall")
+
+	srv.stallFirst.Store(true)
+	waitFor(t, 5*time.Second, func() bool {
+		cli := m.client("stall")
+		return cli != nil && cli != old
+	})
+
+	if n := srv.streams.Load(); n < 2 {
+		t.Errorf("server saw %d streams, want a second one after the stall", n)
+	}
+	if msg, down := m.Unavailable("stall.echo"); down {
+		t.Fatalf("server marked down after a successful reconnect: %s", msg)
+	}
+	if _, err := m.CallTool("echo", nil); err != nil {
+		t.Errorf("CallTool over the new connection: %v", err)
+	}
+}
+
+func TestHeartbeatMarksDownWhenReconnectFails(t *testing.T) {
+	srv, m := connectStalling(t)
+
+	srv.refuse.Store(true)
+	srv.stallFirst.Store(true)
+	waitFor(t, 5*time.Second, func() bool {
+		_, down := m.Unavailable("stall.echo")
+		return down
+	})
+
+	if cli := m.client("stall"); cli != nil {
+		t.Error("client of the dropped server is still connected")
+	}
+	if _, err := m.Tool("echo"); err == nil {
+		t.Error("tools of the dropped server still resolve")
+	}
+	if tools := m.LLMTools(); len(tools) != 0 {
+		t.Errorf("LLMTools() = %d tools, want none after the server was dropped", len(tools))
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	log "github.com/sirupsen/logrus"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// maxCorrections bounds how many times the LLM is asked to fix an invalid
+// tool call before the client gives up.
+const maxCorrections = 2
+
+// defaultSystemPrompt is used when neither -prompt-file nor
+// MCP_SYSTEM_PROMPT supplies a template.
+const defaultSystemPrompt = `
+You are an assistant that validates and normalizes tool calls for MCP, based on the available tools.
+Here are the available tools:
+{{.Tools}}
+Fulfil the user's request by calling the matching tool function with normalized arguments.
+After a tool runs you will receive its result. Call another tool if more work is needed.
+When no tool applies, or the task is complete, reply with a short plain-text answer instead of calling a tool.
+`
+
+// newLLM connects to OpenAI. It is only called once the agent loop needs
+// the model, so -direct and -list-tools work without credentials.
+func newLLM() (llms.Model, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("set OPENAI_API_KEY, or pass -direct to call the tool without the LLM")
+	}
+	return openai.New(openai.WithModel("gpt-4"), openai.WithToken(apiKey))
+}
+
+// buildSystemPrompt renders the system prompt template with the tools JSON.
+// The template comes from promptFile, then the MCP_SYSTEM_PROMPT env var,
+// falling back to the built-in prompt.
+func buildSystemPrompt(promptFile, toolsJSON string) (string, error) {
+	text := defaultSystemPrompt
+	switch {
+	case promptFile != "":
+		data, err := os.ReadFile(promptFile)
+		if err != nil {
+			return "", fmt.Errorf("error reading prompt file: %v", err)
+		}
+		text = string(data)
+	case os.Getenv("MCP_SYSTEM_PROMPT") != "":
+		text = os.Getenv("MCP_SYSTEM_PROMPT")
+	}
+
+	tmpl, err := template.New("system").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("error parsing prompt template: %v", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, struct{ Tools string }{toolsJSON}); err != nil {
+		return "", fmt.Errorf("error rendering prompt template: %v", err)
+	}
+	return b.String(), nil
+}
+
+// ToolCall is a tool invocation chosen by the LLM
+type ToolCall struct {
+	// ID is the LLM's identifier for the call, echoed back with the result.
+	ID        string         `json:"id,omitempty"`
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments"`
+	// Answer carries the LLM's final answer when it calls no tool.
+	Answer string `json:"answer,omitempty"`
+}
+
+// LLMTools converts the discovered MCP tools into function definitions for
+// native LLM function-calling.
+func (m *MultiClient) LLMTools() []llms.Tool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	servers := make([]string, 0, len(m.serverDetails))
+	for name := range m.serverDetails {
+		servers = append(servers, name)
+	}
+	sort.Strings(servers)
+
+	var tools []llms.Tool
+	for _, srv := range servers {
+		for _, t := range m.serverDetails[srv].Tools {
+			tool := toLLMTool(t)
+			if len(m.toolToServer[t.Name]) > 1 {
+				// Qualify duplicated names so each function stays unique.
+				tool.Function.Name = srv + "__" + t.Name
+			}
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// toLLMTool describes an MCP tool as an LLM function definition.
+func toLLMTool(t mcp.Tool) llms.Tool {
+	return llms.Tool{
+		Type: "function",
+		Function: &llms.FunctionDefinition{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  toLLMParameters(t.InputSchema),
+		},
+	}
+}
+
+// toLLMParameters translates an MCP tool input schema into the JSON Schema
+// object expected for LLM function parameters, keeping each property's type,
+// description and enum, and the list of required properties.
+func toLLMParameters(schema mcp.ToolInputSchema) map[string]any {
+	props := make(map[string]any, len(schema.Properties))
+	for name, raw := range schema.Properties {
+		if prop, ok := raw.(map[string]any); ok {
+			props[name] = toLLMProperty(prop)
+		}
+	}
+
+	params := map[string]any{
+		"type":       "object",
+		"properties": props,
+	}
+	var required []string
+	for _, name := range schema.Required {
+		if _, ok := props[name]; ok {
+			required = append(required, name)
+		}
+	}
+	if len(required) > 0 {
+		params["required"] = required
+	}
+	return params
+}
+
+// toLLMProperty copies the parts of a property schema LLM providers
+// understand, recursing into array items and nested objects.
+func toLLMProperty(prop map[string]any) map[string]any {
+	out := map[string]any{}
+	typ, _ := prop["type"].(string)
+	if typ == "" {
+		typ = "string"
+	}
+	out["type"] = typ
+	if desc, ok := prop["description"].(string); ok && desc != "" {
+		out["description"] = desc
+	}
+	if enum, ok := prop["enum"]; ok {
+		out["enum"] = enum
+	}
+
+	switch typ {
+	case "array":
+		items, _ := prop["items"].(map[string]any)
+		if items == nil {
+			items = map[string]any{"type": "string"}
+		}
+		out["items"] = toLLMProperty(items)
+	case "object":
+		nested := mcp.ToolInputSchema{Type: "object"}
+		nested.Properties, _ = prop["properties"].(map[string]any)
+		nested.Required = asStrings(prop["required"])
+		for k, v := range toLLMParameters(nested) {
+			out[k] = v
+		}
+		// Free-form maps (e.g. a tool's env argument) carry their value schema here.
+		if extra, ok := prop["additionalProperties"]; ok {
+			out["additionalProperties"] = extra
+		}
+	}
+	return out
+}
+
+// asStrings converts a decoded JSON array of strings into a []string.
+func asStrings(v any) []string {
+	switch vals := v.(type) {
+	case []string:
+		return vals
+	case []any:
+		out := make([]string, 0, len(vals))
+		for _, s := range vals {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// toolResponse wraps a tool result (or feedback about a rejected call) as the
+// message answering the LLM's tool call.
+func toolResponse(tc ToolCall, content string) llms.MessageContent {
+	return llms.MessageContent{
+		Role: llms.ChatMessageTypeTool,
+		Parts: []llms.ContentPart{llms.ToolCallResponse{
+			ToolCallID: tc.ID,
+			Name:       tc.Tool,
+			Content:    content,
+		}},
+	}
+}
+
+// generate sends history and the available tools to the LLM and returns its
+// first choice. When stream is set, tokens are printed as they arrive.
+func generate(ctx context.Context, llm llms.Model, history []llms.MessageContent, tools []llms.Tool, stream bool) (*llms.ContentChoice, error) {
+	opts := []llms.CallOption{llms.WithTools(tools)}
+	if stream {
+		opts = append(opts, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			_, err := os.Stdout.Write(chunk)
+			return err
+		}))
+	}
+	resp, err := llm.GenerateContent(ctx, history, opts...)
+	if stream {
+		fmt.Println()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("LLM returned no choices")
+	}
+	return resp.Choices[0], nil
+}
+
+// nextToolCall asks the LLM for its next tool call and checks it against the
+// discovered tools and their input schemas, feeding any problems back so the
+// LLM can correct itself. A reply without a tool call is returned as a "none"
+// decision carrying the LLM's answer. The returned history includes every
+// exchanged message.
+func nextToolCall(ctx context.Context, llm llms.Model, cli *MultiClient, history []llms.MessageContent, stream bool) (ToolCall, []llms.MessageContent, error) {
+	tools := cli.LLMTools()
+	for attempt := 0; ; attempt++ {
+		choice, err := generate(ctx, llm, history, tools, stream)
+		if err != nil {
+			return ToolCall{}, history, fmt.Errorf("LLM error: %v", err)
+		}
+		if len(choice.ToolCalls) == 0 || choice.ToolCalls[0].FunctionCall == nil {
+			log.Debugf("LLM reply: %s", choice.Content)
+			history = append(history, llms.TextParts(llms.ChatMessageTypeAI, choice.Content))
+			return ToolCall{Tool: "none", Answer: strings.TrimSpace(choice.Content)}, history, nil
+		}
+		if len(choice.ToolCalls) > 1 {
+			log.Warnf("LLM requested %d tool calls at once; only the first is run", len(choice.ToolCalls))
+		}
+
+		call := choice.ToolCalls[0]
+		log.Debugf("LLM tool call: %s(%s)", call.FunctionCall.Name, call.FunctionCall.Arguments)
+		history = append(history, llms.MessageContent{
+			Role:  llms.ChatMessageTypeAI,
+			Parts: []llms.ContentPart{call},
+		})
+
+		tc := ToolCall{ID: call.ID, Tool: call.FunctionCall.Name}
+		feedback := ""
+		if err := json.Unmarshal([]byte(call.FunctionCall.Arguments), &tc.Arguments); err != nil {
+			feedback = fmt.Sprintf("The arguments are not valid JSON: %v", err)
+		} else if tool, toolErr := cli.Tool(tc.Tool); toolErr != nil {
+			log.Warnf("LLM selected an %v", toolErr)
+			feedback = "You selected an " + toolErr.Error() + ". Choose one of the available tools or answer without calling a tool."
+		} else if problems := ValidateArguments(tool, tc.Arguments); len(problems) > 0 {
+			log.Warnf("LLM arguments for %q are invalid: %s", tc.Tool, strings.Join(problems, "; "))
+			feedback = schemaFeedback(tool, problems)
+		}
+		if feedback == "" {
+			return tc, history, nil
+		}
+		if attempt == maxCorrections {
+			return ToolCall{}, history, fmt.Errorf("giving up after %d correction attempts:\n%s", maxCorrections, feedback)
+		}
+		history = append(history, toolResponse(tc, feedback))
+	}
+}
+
+// isNoToolDecision reports whether the LLM decided that no tool applies,
+// either explicitly with "none" or by leaving the tool name empty.
+func isNoToolDecision(tc ToolCall) bool {
+	return tc.Tool == "" || strings.EqualFold(tc.Tool, "none")
+}
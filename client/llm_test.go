@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// fakeLLM replays canned choices, one per GenerateContent call.
+type fakeLLM struct {
+	choices []*llms.ContentChoice
+	calls   int
+}
+
+func (f *fakeLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	choice := f.choices[f.calls]
+	f.calls++
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{choice}}, nil
+}
+
+func (f *fakeLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, f, prompt, options...)
+}
+
+func TestIsNoToolDecision(t *testing.T) {
+	tests := []struct {
+		tool string
+		want bool
+	}{
+		{"none", true},
+		{"None", true},
+		{"NONE", true},
+		{"", true},
+		{"get_pods", false},
+		{"nonexistent", false},
+	}
+	for _, tt := range tests {
+		if got := isNoToolDecision(ToolCall{Tool: tt.tool}); got != tt.want {
+			t.Errorf("isNoToolDecision(%q) = %v, want %v", tt.tool, got, tt.want)
+		}
+	}
+}
+
+func TestNextToolCallWithoutToolCall(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		answer  string
+	}{
+		{"answer", "  No tool can list printers.\n", "No tool can list printers."},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &fakeLLM{choices: []*llms.ContentChoice{{Content: tt.content}}}
+			cli := &MultiClient{serverDetails: map[string]*ServerDetails{}}
+
+			tc, history, err := nextToolCall(context.Background(), llm, cli, nil, false)
+			if err != nil {
+				t.Fatalf("nextToolCall: %v", err)
+			}
+			if !isNoToolDecision(tc) {
+				t.Errorf("tool = %q, want a no-tool decision", tc.Tool)
+			}
+			if tc.Answer != tt.answer {
+				t.Errorf("answer = %q, want %q", tc.Answer, tt.answer)
+			}
+			if len(history) != 1 || history[0].Role != llms.ChatMessageTypeAI {
+				t.Errorf("history = %+v, want the AI reply appended", history)
+			}
+		})
+	}
+}
+
+func TestToLLMParameters(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema mcp.ToolInputSchema
+		want   map[string]any
+	}{
+		{
+			name:   "no parameters",
+			schema: mcp.ToolInputSchema{Type: "object"},
+			want: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{},
+			},
+		},
+		{
+			name: "required and enum",
+			schema: mcp.ToolInputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"dialect": map[string]any{"type": "string", "description": "Database", "enum": []any{"sqlite", "postgres"}},
+					"limit":   map[string]any{"type": "number"},
+				},
+				Required: []string{"dialect", "missing"},
+			},
+			want: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"dialect": map[string]any{"type": "string", "description": "Database", "enum": []any{"sqlite", "postgres"}},
+					"limit":   map[string]any{"type": "number"},
+				},
+				"required": []string{"dialect"},
+			},
+		},
+		{
+			name: "arrays with and without items",
+			schema: mcp.ToolInputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"columns": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"rows":    map[string]any{"type": "array", "items": map[string]any{"type": "array"}},
+					"tags":    map[string]any{"type": "array"},
+				},
+			},
+			want: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"columns": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"rows": map[string]any{"type": "array", "items": map[string]any{
+						"type": "array", "items": map[string]any{"type": "string"},
+					}},
+					"tags": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				},
+			},
+		},
+		{
+			name: "nested objects",
+			schema: mcp.ToolInputSchema{
+				Type: "object",
+				Properties: map[string]any{
+					"target": map[string]any{
+						"type":        "object",
+						"description": "Where to deploy",
+						"properties": map[string]any{
+							"cluster": map[string]any{"type": "string"},
+							"options": map[string]any{
+								"type":       "object",
+								"properties": map[string]any{"dry_run": map[string]any{"type": "boolean"}},
+							},
+						},
+						"required": []any{"cluster"},
+					},
+					"env": map[string]any{
+						"type":                 "object",
+						"additionalProperties": map[string]any{"type": "string"},
+					},
+				},
+				Required: []string{"target"},
+			},
+			want: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"target": map[string]any{
+						"type":        "object",
+						"description": "Where to deploy",
+						"properties": map[string]any{
+							"cluster": map[string]any{"type": "string"},
+							"options": map[string]any{
+								"type":       "object",
+								"properties": map[string]any{"dry_run": map[string]any{"type": "boolean"}},
+							},
+						},
+						"required": []string{"cluster"},
+					},
+					"env": map[string]any{
+						"type":                 "object",
+						"properties":           map[string]any{},
+						"additionalProperties": map[string]any{"type": "string"},
+					},
+				},
+				"required": []string{"target"},
+			},
+		},
+		{
+			name: "untyped property defaults to string",
+			schema: mcp.ToolInputSchema{
+				Type:       "object",
+				Properties: map[string]any{"query": map[string]any{"description": "SQL"}},
+			},
+			want: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"query": map[string]any{"type": "string", "description": "SQL"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toLLMParameters(tt.schema)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toLLMParameters() =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
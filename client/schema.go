@@ -0,0 +1,197 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// configSchema is the JSON Schema config.json must satisfy.
+//
+//go:embed config.schema.json
+var configSchema []byte
+
+// ValidateConfig checks raw config JSON against configSchema and returns
+// every problem found, each prefixed with the path of the offending value
+// (e.g. "mcpServers.foo: unknown property \"comand\"").
+func ValidateConfig(data []byte) ([]string, error) {
+	var schema, doc any
+	if err := json.Unmarshal(configSchema, &schema); err != nil {
+		return nil, fmt.Errorf("invalid embedded config schema: %v", err)
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var problems []string
+	validateSchema(schema, doc, "", &problems)
+	return problems, nil
+}
+
+// validateSchema is a small JSON Schema validator covering the keywords
+// config.schema.json uses: type, required, properties,
+// additionalProperties, minProperties, items, anyOf, pattern and minLength.
+func validateSchema(rawSchema, value any, path string, problems *[]string) {
+	schema, ok := rawSchema.(map[string]any)
+	if !ok {
+		return
+	}
+	fail := func(format string, args ...any) {
+		where := path
+		if where == "" {
+			where = "config"
+		}
+		*problems = append(*problems, where+": "+fmt.Sprintf(format, args...))
+	}
+
+	if typ, ok := schema["type"].(string); ok && !matchesType(typ, value) {
+		fail("must be of type %s, got %s", typ, jsonType(value))
+		return
+	}
+
+	if branches, ok := schema["anyOf"].([]any); ok && !anyOfMatches(branches, value, path) {
+		fail("%s", anyOfMessage(branches))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if req, ok := schema["required"].([]any); ok {
+			for _, r := range req {
+				if name, _ := r.(string); name != "" {
+					if _, ok := v[name]; !ok {
+						fail("missing required property %q", name)
+					}
+				}
+			}
+		}
+		if minProps, ok := schema["minProperties"].(float64); ok && float64(len(v)) < minProps {
+			fail("must have at least %d entries", int(minProps))
+		}
+		props, _ := schema["properties"].(map[string]any)
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := joinPath(path, name)
+			if sub, ok := props[name]; ok {
+				validateSchema(sub, v[name], child, problems)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					fail("unknown property %q%s", name, suggestProperty(name, props))
+				}
+			case map[string]any:
+				validateSchema(extra, v[name], child, problems)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"]; ok {
+			for i, item := range v {
+				validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case string:
+		if minLen, ok := schema["minLength"].(float64); ok && float64(utf8.RuneCountInString(v)) < minLen {
+			fail("must not be empty")
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("%q does not match %s", v, pattern)
+			}
+		}
+	}
+}
+
+func anyOfMatches(branches []any, value any, path string) bool {
+	for _, b := range branches {
+		var problems []string
+		validateSchema(b, value, path, &problems)
+		if len(problems) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// anyOfMessage describes a failed anyOf. Branches that only require a
+// property read as "must have 'url' or 'command'".
+func anyOfMessage(branches []any) string {
+	var names []string
+	for _, b := range branches {
+		m, _ := b.(map[string]any)
+		req, _ := m["required"].([]any)
+		if len(m) != 1 || len(req) != 1 {
+			return "does not match any of the allowed forms"
+		}
+		names = append(names, fmt.Sprintf("'%v'", req[0]))
+	}
+	return "must have " + strings.Join(names, " or ")
+}
+
+// suggestProperty returns a hint naming the known property closest to a
+// misspelt one, or "" when none is close.
+func suggestProperty(name string, props map[string]any) string {
+	best, bestDist := "", 3
+	for p := range props {
+		if d := editDistance(strings.ToLower(name), p); d < bestDist || (d == bestDist && p < best) {
+			best, bestDist = p, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
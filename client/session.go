@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// sessionDir returns the directory holding persisted conversation histories,
+// taken from MCP_SESSION_DIR or defaulting to ~/.mcpclient/sessions.
+func sessionDir() (string, error) {
+	if dir := os.Getenv("MCP_SESSION_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error locating home directory: %v", err)
+	}
+	return filepath.Join(home, ".mcpclient", "sessions"), nil
+}
+
+// sessionPath maps a session id to its history file.
+func sessionPath(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid session id %q", id)
+	}
+	dir, err := sessionDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
+
+// LoadHistory reads the message history saved for a session. A session that
+// has never been saved yields an empty history.
+func LoadHistory(id string) ([]llms.MessageContent, error) {
+	path, err := sessionPath(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading session: %v", err)
+	}
+	var history []llms.MessageContent
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("error unmarshaling session: %v", err)
+	}
+	return history, nil
+}
+
+// SaveHistory writes the message history for a session, replacing any
+// previously saved history. Tool calls left without a result (e.g. by
+// -dry-run) are answered first, since the LLM rejects a history in which an
+// assistant tool call is not followed by its tool message.
+func SaveHistory(id string, history []llms.MessageContent) error {
+	path, err := sessionPath(id)
+	if err != nil {
+		return err
+	}
+	history = answerPendingToolCalls(history)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error creating session directory: %v", err)
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling session: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("error writing session: %v", err)
+	}
+	return nil
+}
+
+// withSystemPrompt returns history with its system message replaced by
+// systemPrompt, so resumed sessions always see the current tool list.
+func withSystemPrompt(history []llms.MessageContent, systemPrompt string) []llms.MessageContent {
+	out := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeSystem, systemPrompt)}
+	for _, msg := range history {
+		if msg.Role != llms.ChatMessageTypeSystem {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// answerPendingToolCalls appends a tool message for each tool call of a
+// trailing assistant message that never got a result.
+func answerPendingToolCalls(history []llms.MessageContent) []llms.MessageContent {
+	if len(history) == 0 {
+		return history
+	}
+	last := history[len(history)-1]
+	if last.Role != llms.ChatMessageTypeAI {
+		return history
+	}
+	for _, part := range last.Parts {
+		if call, ok := part.(llms.ToolCall); ok && call.FunctionCall != nil {
+			tc := ToolCall{ID: call.ID, Tool: call.FunctionCall.Name}
+			history = append(history, toolResponse(tc, "The tool call was not executed."))
+		}
+	}
+	return history
+}
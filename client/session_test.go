@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestSaveHistoryAnswersPendingToolCall(t *testing.T) {
+	t.Setenv("MCP_SESSION_DIR", t.TempDir())
+
+	history := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, "list the pods"),
+		{
+			Role: llms.ChatMessageTypeAI,
+			Parts: []llms.ContentPart{llms.ToolCall{
+				ID:           "call_1",
+				Type:         "function",
+				FunctionCall: &llms.FunctionCall{Name: "get_pods", Arguments: "{}"},
+			}},
+		},
+	}
+	if err := SaveHistory("dry-run", history); err != nil {
+		t.Fatalf("SaveHistory: %v", err)
+	}
+	loaded, err := LoadHistory("dry-run")
+	if err != nil {
+		t.Fatalf("LoadHistory: %v", err)
+	}
+	if len(loaded) != 3 {
+		t.Fatalf("loaded %d messages, want 3", len(loaded))
+	}
+	last := loaded[2]
+	if last.Role != llms.ChatMessageTypeTool || len(last.Parts) != 1 {
+		t.Fatalf("last message = %+v, want one tool response", last)
+	}
+	resp, ok := last.Parts[0].(llms.ToolCallResponse)
+	if !ok || resp.ToolCallID != "call_1" || resp.Name != "get_pods" {
+		t.Errorf("tool response = %+v, want one answering call_1", last.Parts[0])
+	}
+}
+
+func TestSaveHistoryKeepsAnsweredToolCall(t *testing.T) {
+	history := []llms.MessageContent{
+		{
+			Role: llms.ChatMessageTypeAI,
+			Parts: []llms.ContentPart{llms.ToolCall{
+				ID:           "call_1",
+				FunctionCall: &llms.FunctionCall{Name: "get_pods", Arguments: "{}"},
+			}},
+		},
+		toolResponse(ToolCall{ID: "call_1", Tool: "get_pods"}, "pod-a"),
+		llms.TextParts(llms.ChatMessageTypeAI, "There is one pod."),
+	}
+	if got := answerPendingToolCalls(history); len(got) != len(history) {
+		t.Errorf("answerPendingToolCalls added %d messages to a complete history", len(got)-len(history))
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ValidateArguments checks args against the tool's declared input schema and
+// returns every problem found: missing required arguments, wrong types and
+// values outside an enum. An empty slice means the arguments are valid.
+func ValidateArguments(tool mcp.Tool, args map[string]any) []string {
+	var problems []string
+
+	for _, name := range tool.InputSchema.Required {
+		if _, ok := args[name]; !ok {
+			problems = append(problems, fmt.Sprintf("missing required argument %q", name))
+		}
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		raw, ok := tool.InputSchema.Properties[name]
+		if !ok {
+			continue
+		}
+		prop, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		value := args[name]
+		if typ, ok := prop["type"].(string); ok && !matchesType(typ, value) {
+			problems = append(problems, fmt.Sprintf("argument %q must be of type %s, got %T", name, typ, value))
+			continue
+		}
+		if enum, ok := prop["enum"].([]any); ok && !inEnum(enum, value) {
+			problems = append(problems, fmt.Sprintf("argument %q must be one of %v, got %v", name, enum, value))
+		}
+	}
+	return problems
+}
+
+// matchesType reports whether a JSON-decoded value matches a JSON Schema type.
+func matchesType(typ string, value any) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	default:
+		return true
+	}
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaFeedback builds the message sent back to the LLM when its arguments
+// fail validation.
+func schemaFeedback(tool mcp.Tool, problems []string) string {
+	schema, _ := json.MarshalIndent(tool.InputSchema, "", "  ")
+	return fmt.Sprintf(
+		"The arguments for tool %q are invalid:\n- %s\nThe tool's input schema is:\n%s\nCall the tool again with corrected arguments.",
+		tool.Name, strings.Join(problems, "\n- "), schema)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	jsonContentType    = "application/json"
+	msgpackContentType = "application/msgpack"
+)
+
+// rpcCodec encodes and decodes /rpc messages in either JSON (the default) or
+// msgpack. Struct fields use their json tags in both formats, so msgpack
+// messages carry the same field names as their JSON counterparts.
+type rpcCodec struct {
+	msgpack bool
+}
+
+// requestCodec picks the codec matching the request's Content-Type.
+func requestCodec(r *http.Request) rpcCodec {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return rpcCodec{msgpack: mediaType == msgpackContentType}
+}
+
+// responseCodec picks the codec for the response: msgpack when the Accept
+// header lists it, otherwise the format the request was sent in.
+func responseCodec(r *http.Request) rpcCodec {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(accept))
+		switch mediaType {
+		case msgpackContentType:
+			return rpcCodec{msgpack: true}
+		case jsonContentType:
+			return rpcCodec{}
+		}
+	}
+	return requestCodec(r)
+}
+
+func (c rpcCodec) ContentType() string {
+	if c.msgpack {
+		return msgpackContentType
+	}
+	return jsonContentType
+}
+
+func (c rpcCodec) Unmarshal(data []byte, v any) error {
+	if !c.msgpack {
+		return json.Unmarshal(data, v)
+	}
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func (c rpcCodec) Marshal(v any) ([]byte, error) {
+	if !c.msgpack {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// id prepares a request id for encoding in the response. A json.Number from
+// a JSON request is written back verbatim in JSON; msgpack gets the integer
+// or float it denotes rather than a string.
+func (c rpcCodec) id(id any) any {
+	n, ok := id.(json.Number)
+	if !ok || !c.msgpack {
+		return id
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return id
+}
+
+// jsonNumbers converts the integer types msgpack decodes into float64, the
+// type tool handlers expect for numeric arguments decoded from JSON.
+func jsonNumbers(v any) any {
+	switch n := v.(type) {
+	case map[string]any:
+		for k, e := range n {
+			n[k] = jsonNumbers(e)
+		}
+	case []any:
+		for i, e := range n {
+			n[i] = jsonNumbers(e)
+		}
+	case int8:
+		return float64(n)
+	case int16:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint8:
+		return float64(n)
+	case uint16:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
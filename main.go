@@ -15,6 +15,8 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/santoshkal/mcpserver/internal/httpgzip"
+	log "github.com/sirupsen/logrus"
 )
 
 // ToolHandler is a function that processes an MCP call.
@@ -24,6 +26,11 @@ type ToolHandler func(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 var toolHandlers = map[string]ToolHandler{}
 
 func main() {
+	// Debug lines (raw requests and responses) are off unless asked for.
+	if lvl, err := log.ParseLevel(os.Getenv("MCP_LOG_LEVEL")); err == nil {
+		log.SetLevel(lvl)
+	}
+
 	// Create a new MCP server instance.
 	s := server.NewMCPServer(
 		"MCP Tool Server",
@@ -44,8 +51,8 @@ func main() {
 		if !ok {
 			return nil, fmt.Errorf("invalid or missing image parameter")
 		}
-		fmt.Printf("[DEBUG] Executing tool 'pull_image' with image: %s\n", image)
-		client, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		log.Debugf("Executing tool 'pull_image' with image: %s", image)
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Docker client: %v", err)
 		}
@@ -67,7 +74,7 @@ func main() {
 		mcp.WithDescription("Get Kubernetes Pods from the cluster"),
 	)
 	getPodsHandler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		fmt.Println("[DEBUG] Executing tool 'get_pods'")
+		log.Debug("Executing tool 'get_pods'")
 		cmd := exec.Command("kubectl", "get", "pods")
 		output, err := cmd.CombinedOutput()
 		if err != nil {
@@ -91,7 +98,7 @@ func main() {
 		if !ok {
 			return nil, fmt.Errorf("invalid or missing directory parameter")
 		}
-		fmt.Printf("[DEBUG] Executing tool 'git_init' with directory: %s\n", directory)
+		log.Debugf("Executing tool 'git_init' with directory: %s", directory)
 		cmd := exec.Command("git", "init", directory)
 		output, err := cmd.CombinedOutput()
 		if err != nil {
@@ -131,7 +138,7 @@ func main() {
 		if !ok {
 			return nil, fmt.Errorf("invalid or missing values parameter")
 		}
-		fmt.Printf("[DEBUG] Executing tool 'create_table' with table_name: %s\n", tableName)
+		log.Debugf("Executing tool 'create_table' with table_name: %s", tableName)
 		sqlCmd := fmt.Sprintf("CREATE TABLE %s (%s); INSERT INTO %s VALUES (%s);", tableName, headers, tableName, values)
 		cmd := exec.Command("psql", "-d", "postgres", "-c", sqlCmd)
 		output, err := cmd.CombinedOutput()
@@ -142,58 +149,126 @@ func main() {
 	}
 	s.AddTool(createTableTool, createTableHandler)
 	toolHandlers["create_table"] = createTableHandler
-	http.HandleFunc("/rpc", func(w http.ResponseWriter, r *http.Request) {
-		// Read the request body for debugging
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "failed to read request: "+err.Error(), http.StatusBadRequest)
-			return
-		}
-		// Log the raw request JSON
-		fmt.Printf("[DEBUG] Received Request: %s\n", string(body))
-		// Reset the body for further decoding
-		r.Body = io.NopCloser(bytes.NewBuffer(body))
-
-		var req mcp.CallToolRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "failed to decode request: "+err.Error(), http.StatusBadRequest)
-			return
-		}
+	http.HandleFunc("/rpc", handleRPC)
 
-		// Retrieve the tool name from req.Params.Name
-		toolName := req.Params.Name
-		fmt.Printf("[DEBUG] Tool invoked: %s\n", toolName)
-		handler, exists := toolHandlers[toolName]
-		if !exists {
-			http.Error(w, "unknown tool: "+toolName, http.StatusBadRequest)
-			return
-		}
+	log.Info("MCP HTTP Server listening on http://localhost:1234/rpc")
+	if err := http.ListenAndServe(":1234", httpgzip.Handler(http.DefaultServeMux)); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
 
-		// Execute the tool handler.
-		result, err := handler(r.Context(), req)
-		if err != nil {
-			http.Error(w, "tool error: "+err.Error(), http.StatusInternalServerError)
+// handleRPC serves /rpc: it decodes a tools/call request, runs the matching
+// handler from toolHandlers and writes the JSON-RPC response.
+func handleRPC(w http.ResponseWriter, r *http.Request) {
+	// Read the request body for debugging
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	codec := requestCodec(r)
+	// Log the raw request JSON
+	if codec.msgpack {
+		log.Debugf("Received msgpack request (%d bytes)", len(body))
+	} else {
+		log.Debugf("Received request: %s", body)
+	}
+
+	var req mcp.CallToolRequest
+	if err := codec.Unmarshal(body, &req); err != nil {
+		http.Error(w, "failed to decode request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if codec.msgpack {
+		jsonNumbers(req.Params.Arguments)
+	}
+
+	// A request without an id is a notification: the client expects no
+	// response, not even an error, so failures are only logged.
+	id, hasID := requestID(body, codec)
+	notification := !hasID
+
+	// Retrieve the tool name from req.Params.Name
+	toolName := req.Params.Name
+	log.Infof("Tool invoked: %s", toolName)
+	handler, exists := toolHandlers[toolName]
+	if !exists {
+		if notification {
+			log.Warnf("Notification for unknown tool: %s", toolName)
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
+		http.Error(w, "unknown tool: "+toolName, http.StatusBadRequest)
+		return
+	}
 
-		// Encode the result to JSON for logging and response.
-		respBody, err := json.Marshal(result)
+	// Execute the tool handler.
+	result, err := handler(r.Context(), req)
+	if notification {
 		if err != nil {
-			http.Error(w, "failed to encode response: "+err.Error(), http.StatusInternalServerError)
-			return
+			log.Warnf("Notification for tool '%s' failed: %v", toolName, err)
 		}
-		// Log the response body.
-		fmt.Printf("[DEBUG] Response: %s\n", string(respBody))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		http.Error(w, "tool error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Encode the result in the format the client accepts.
+	respCodec := responseCodec(r)
+	respBody, err := respCodec.Marshal(rpcResponse{JSONRPC: "2.0", ID: respCodec.id(id), Result: result})
+	if err != nil {
+		http.Error(w, "failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Log the response body.
+	if respCodec.msgpack {
+		log.Debugf("Sending msgpack response (%d bytes)", len(respBody))
+	} else {
+		log.Debugf("Response: %s", respBody)
+	}
+
+	w.Header().Set("Content-Type", respCodec.ContentType())
+	w.Write(respBody)
+}
 
-		// Return the result as JSON.
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(respBody)
-	})
+// rpcResponse is the JSON-RPC 2.0 envelope of a /rpc response.
+type rpcResponse struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      any    `json:"id"`
+	Result  any    `json:"result"`
+}
 
-	fmt.Println("MCP HTTP Server listening on http://localhost:1234/rpc")
-	if err := http.ListenAndServe(":1234", nil); err != nil {
-		fmt.Printf("Server error: %v\n", err)
+// requestID returns the "id" member of a JSON-RPC message with its original
+// type, so it can be echoed unchanged: a JSON number is kept as json.Number
+// (1 stays 1, not 1.0) and a string stays a string. ok is false when the
+// message has no id, i.e. is a notification; an explicit null id is a
+// request.
+func requestID(msg []byte, codec rpcCodec) (id any, ok bool) {
+	if codec.msgpack {
+		var envelope map[string]any
+		if err := codec.Unmarshal(msg, &envelope); err != nil {
+			return nil, false
+		}
+		id, ok = envelope["id"]
+		return id, ok
+	}
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &envelope); err != nil {
+		return nil, false
+	}
+	raw, ok := envelope["id"]
+	if !ok {
+		return nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&id); err != nil {
+		return nil, true
 	}
+	return id, true
 }
 
 // 	http.HandleFunc("/rpc", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestHandleRPCEchoesRequestID(t *testing.T) {
+	toolHandlers["echo"] = func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	}
+	t.Cleanup(func() { delete(toolHandlers, "echo") })
+
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{"number", `7`},
+		{"large number", `9007199254740993`},
+		{"string", `"abc"`},
+		{"numeric string", `"7"`},
+		{"null", `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"jsonrpc":"2.0","id":` + tt.id + `,"method":"tools/call","params":{"name":"echo","arguments":{}}}`
+			r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			handleRPC(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			var resp map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			if got := string(resp["id"]); got != tt.id {
+				t.Errorf("id = %s, want %s", got, tt.id)
+			}
+		})
+	}
+}
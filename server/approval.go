@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// approver decides whether a sensitive tool call may run. A call is only
+// approved when approve returns true and no error.
+type approver interface {
+	approve(ctx context.Context, call approvalRequest) (approved bool, reason string, err error)
+}
+
+// approvalRequest describes a pending tool call to an approver.
+type approvalRequest struct {
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments"`
+	Client    string         `json:"client"`
+}
+
+// approvalResponse is the answer expected from an approval webhook.
+type approvalResponse struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason"`
+}
+
+// webhookApprover asks an HTTP endpoint: the call is POSTed as JSON and the
+// endpoint answers 200 with {"approved": true|false, "reason": "..."}. It
+// may hold the request open while a human decides.
+type webhookApprover struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhookApprover) approve(ctx context.Context, call approvalRequest) (bool, string, error) {
+	body, err := json.Marshal(call)
+	if err != nil {
+		return false, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return false, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("approval webhook returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var ans approvalResponse
+	if err := json.Unmarshal(data, &ans); err != nil {
+		return false, "", fmt.Errorf("invalid approval webhook response: %v", err)
+	}
+	return ans.Approved, ans.Reason, nil
+}
+
+// guardedTools always need approval, whether or not MCP_APPROVAL_TOOLS
+// lists them; without an approval webhook they are refused.
+var guardedTools = map[string]bool{
+	"ops_kill": true,
+}
+
+// approvalGate holds calls to sensitive tools until an approver allows them.
+// A nil approver denies every call.
+type approvalGate struct {
+	tools    map[string]bool
+	approver approver
+	timeout  time.Duration
+}
+
+// newApprovalGateFromEnv reads the sensitive tools from the comma-separated
+// MCP_APPROVAL_TOOLS, the webhook from MCP_APPROVAL_WEBHOOK and how long to
+// wait for an answer from MCP_APPROVAL_TIMEOUT (default 2m). Without a
+// webhook only guardedTools are gated, and they are refused; every other
+// tool runs as before.
+func newApprovalGateFromEnv() *approvalGate {
+	tools := maps.Clone(guardedTools)
+	url := strings.TrimSpace(os.Getenv("MCP_APPROVAL_WEBHOOK"))
+	if url == "" {
+		return &approvalGate{tools: tools}
+	}
+	for _, t := range strings.Split(os.Getenv("MCP_APPROVAL_TOOLS"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tools[t] = true
+		}
+	}
+	return &approvalGate{
+		tools:    tools,
+		approver: &webhookApprover{url: url, client: &http.Client{}},
+		timeout:  envDuration("MCP_APPROVAL_TIMEOUT", 2*time.Minute),
+	}
+}
+
+// check asks for approval of a call, returning a PERMISSION_DENIED error
+// when it is denied, times out or the approver fails.
+func (g *approvalGate) check(ctx context.Context, req mcp.CallToolRequest) error {
+	if g.approver == nil {
+		return toolErrorf(CodePermissionDenied, "tool call not approved: %s needs approval and no MCP_APPROVAL_WEBHOOK is set", req.Params.Name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	// The webhook is outside the server, so it gets the arguments with
+	// secrets masked, like the command lines logged by toolCommand.
+	call := approvalRequest{Tool: req.Params.Name, Arguments: redactArguments(req.Params.Arguments), Client: clientIdentity(ctx)}
+	approved, reason, err := g.approver.approve(ctx, call)
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return toolErrorf(CodePermissionDenied, "tool call not approved: no answer within %s", g.timeout)
+	case err != nil:
+		return toolErrorf(CodePermissionDenied, "tool call not approved: %v", err)
+	case !approved && reason != "":
+		return toolErrorf(CodePermissionDenied, "tool call not approved: %s", reason)
+	case !approved:
+		return toolErrorf(CodePermissionDenied, "tool call not approved")
+	}
+	return nil
+}
+
+// middleware runs sensitive tools only once their call has been approved.
+func (g *approvalGate) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !g.tools[req.Params.Name] {
+			return next(ctx, req)
+		}
+		requestLog(ctx).Infof("Waiting for approval of tool '%s'", req.Params.Name)
+		if err := g.check(ctx, req); err != nil {
+			requestLog(ctx).Warnf("Rejected tool '%s': %v", req.Params.Name, err)
+			return errorResult(err), nil
+		}
+		requestLog(ctx).Infof("Tool '%s' approved", req.Params.Name)
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestApprovalWebhookGetsRedactedArguments(t *testing.T) {
+	var got approvalRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding the approval request: %v", err)
+		}
+		w.Write([]byte(`{"approved": true}`))
+	}))
+	defer ts.Close()
+	t.Setenv("MCP_APPROVAL_WEBHOOK", ts.URL)
+	t.Setenv("MCP_APPROVAL_TOOLS", "run_query")
+
+	var req mcp.CallToolRequest
+	req.Params.Name = "run_query"
+	req.Params.Arguments = map[string]any{
+		"env":      map[string]any{"PGPASSWORD": "hunter2", "KUBECONFIG": "/etc/kube"},
+		"password": "hunter2",
+		"dsn":      "postgres://app:hunter2@db:5432/app",
+		"query":    "SELECT 1",
+	}
+	if err := newApprovalGateFromEnv().check(context.Background(), req); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	want := map[string]any{
+		"env":      map[string]any{"PGPASSWORD": "***", "KUBECONFIG": "***"},
+		"password": "***",
+		"dsn":      "postgres://app:***@db:5432/app",
+		"query":    "SELECT 1",
+	}
+	if !reflect.DeepEqual(got.Arguments, want) {
+		t.Errorf("webhook got arguments %#v, want %#v", got.Arguments, want)
+	}
+	if req.Params.Arguments["password"] != "hunter2" {
+		t.Error("redaction changed the arguments passed to the tool")
+	}
+}
+
+func TestApprovalGateWithoutWebhook(t *testing.T) {
+	t.Setenv("MCP_APPROVAL_WEBHOOK", "")
+	t.Setenv("MCP_APPROVAL_TOOLS", "run_query")
+	g := newApprovalGateFromEnv()
+	if g.tools["run_query"] {
+		t.Error("MCP_APPROVAL_TOOLS gated without an approval webhook")
+	}
+
+	var req mcp.CallToolRequest
+	req.Params.Name = "ops_kill"
+	res, err := g.middleware(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		t.Error("ops_kill ran without an approval webhook")
+		return mcp.NewToolResultText("killed"), nil
+	})(context.Background(), req)
+	if err != nil || res == nil || !res.IsError {
+		t.Errorf("ops_kill without a webhook = %+v, %v; want an error result", res, err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// argLimits bounds the size of a tool call's arguments, so an oversized map
+// is rejected before any handler or middleware walks it.
+type argLimits struct {
+	maxArgs  int
+	maxBytes int
+}
+
+// newArgLimitsFromEnv reads the limits from MCP_MAX_ARGS (default 64) and
+// MCP_MAX_ARGS_BYTES (default 1 MiB). A limit of 0 disables that check.
+func newArgLimitsFromEnv() *argLimits {
+	return &argLimits{
+		maxArgs:  envInt("MCP_MAX_ARGS", 64),
+		maxBytes: envInt("MCP_MAX_ARGS_BYTES", 1<<20),
+	}
+}
+
+// check returns an INVALID_PARAM error when args exceed the limits.
+func (l *argLimits) check(args map[string]any) error {
+	if l.maxArgs > 0 && len(args) > l.maxArgs {
+		return toolErrorf(CodeInvalidParam, "too many arguments: %d (at most %d)", len(args), l.maxArgs)
+	}
+	if l.maxBytes <= 0 {
+		return nil
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return toolErrorf(CodeInvalidParam, "arguments cannot be encoded: %v", err)
+	}
+	if len(b) > l.maxBytes {
+		return toolErrorf(CodeInvalidParam, "arguments too large: %d bytes (at most %d)", len(b), l.maxBytes)
+	}
+	return nil
+}
+
+// middleware rejects tool calls whose arguments exceed the limits.
+func (l *argLimits) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := l.check(req.Params.Arguments); err != nil {
+			requestLog(ctx).Warnf("Rejected tool '%s': %v", req.Params.Name, err)
+			return errorResult(err), nil
+		}
+		return next(ctx, req)
+	}
+}
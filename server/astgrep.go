@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// gitRepoRoot returns the top-level directory of the git repository holding
+// dir.
+func gitRepoRoot(ctx context.Context, dir string, env []string) (string, error) {
+	cmd := toolCommand(ctx, env, "git", "-C", dir, "rev-parse", "--show-toplevel")
+	out, err := combinedOutput(cmd)
+	if err != nil {
+		if strings.Contains(string(out), "not a git repository") {
+			return "", toolErrorf(CodeInvalidParam, "%s is not inside a git repository", dir)
+		}
+		return "", toolErrorf(classifyError(err), "git rev-parse failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// changedFiles lists the files changed in the repository at root, relative
+// to it. With an empty rng that is everything differing from HEAD, staged or
+// not, plus untracked files; otherwise the files changed in the git range
+// (e.g. "main..HEAD"). Deleted files are left out.
+func changedFiles(ctx context.Context, root, rng string, env []string) ([]string, error) {
+	if strings.HasPrefix(rng, "-") {
+		return nil, toolErrorf(CodeInvalidParam, "invalid range %q", rng)
+	}
+	diff := []string{"-C", root, "diff", "--name-only", "--diff-filter=d", "-z"}
+	if rng == "" {
+		diff = append(diff, "HEAD")
+	} else {
+		diff = append(diff, rng)
+	}
+	cmds := [][]string{diff}
+	if rng == "" {
+		cmds = append(cmds, []string{"-C", root, "ls-files", "--others", "--exclude-standard", "-z"})
+	}
+
+	seen := map[string]bool{}
+	var files []string
+	for _, args := range cmds {
+		cmd := toolCommand(ctx, env, "git", append(args, "--")...)
+		var stdout, stderr bytes.Buffer
+		err := runCommand(cmd, &stdout, &stderr)
+		out := stdout.Bytes()
+		if err != nil {
+			return nil, toolErrorf(classifyError(err), "git %s failed: %v: %s", args[2], err, strings.TrimSpace(stderr.String()))
+		}
+		for _, f := range strings.Split(string(out), "\x00") {
+			if f != "" && !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
+
+// astGrepMatch is the part of an ast-grep --json match needed to plan a
+// rewrite.
+type astGrepMatch struct {
+	File        string  `json:"file"`
+	Replacement *string `json:"replacement"`
+}
+
+// astGrepPlan is the set of files a rewrite will modify, in the order
+// ast-grep reported them, with the number of replacements in each.
+type astGrepPlan struct {
+	files  []string
+	counts map[string]int
+}
+
+// planAstGrepRewrite runs the rewrite in JSON mode without applying it. It
+// fails when the output cannot be parsed, e.g. with an ast-grep too old for
+// --json=stream.
+func planAstGrepRewrite(ctx context.Context, args, paths []string, env []string) (*astGrepPlan, error) {
+	cmd := toolCommand(ctx, env, "ast-grep", slices.Concat(args, []string{"--json=stream"}, paths)...)
+	var stdout, stderr bytes.Buffer
+	err := runCommand(cmd, &stdout, &stderr)
+	out := stdout.Bytes()
+	// ast-grep exits non-zero without any output when nothing matches.
+	if err != nil && (len(bytes.TrimSpace(out)) > 0 || stderr.Len() > 0) {
+		return nil, fmt.Errorf("ast-grep --json failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	plan := &astGrepPlan{counts: map[string]int{}}
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var m astGrepMatch
+		if err := json.Unmarshal(line, &m); err != nil {
+			return nil, fmt.Errorf("unexpected ast-grep JSON output: %v", err)
+		}
+		if m.File == "" || m.Replacement == nil {
+			return nil, fmt.Errorf("ast-grep JSON match without file or replacement")
+		}
+		if plan.counts[m.File] == 0 {
+			plan.files = append(plan.files, m.File)
+		}
+		plan.counts[m.File]++
+	}
+	return plan, nil
+}
+
+// applyAstGrepPlan rewrites the planned files one at a time, reporting
+// progress after each, and summarizes the changes.
+func applyAstGrepPlan(ctx context.Context, req mcp.CallToolRequest, args []string, plan *astGrepPlan, env []string) (string, error) {
+	var b strings.Builder
+	total := 0
+	for i, file := range plan.files {
+		cmd := toolCommand(ctx, env, "ast-grep", slices.Concat(args, []string{"-U", file})...)
+		if out, err := combinedOutput(cmd); err != nil {
+			return b.String(), fmt.Errorf("rewriting %s failed after %d of %d files: %w: %s",
+				file, i, len(plan.files), err, strings.TrimSpace(string(out)))
+		}
+		n := plan.counts[file]
+		total += n
+		fmt.Fprintf(&b, "  %s: %d replacements\n", file, n)
+		notifyProgress(ctx, req, i+1, len(plan.files), fmt.Sprintf("rewrote %s (%d replacements)", file, n))
+	}
+	return fmt.Sprintf("Rewrote %d occurrences in %d files:\n%s", total, len(plan.files), b.String()), nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// backendTools maps the tools that depend on an external backend to it.
+// Tools whose backend depends on their arguments are resolved in
+// toolBackend; Docker tools come from dockerTools.
+var backendTools = map[string]string{
+	"docker_ping":          "docker",
+	"create_table":         "postgres",
+	"postgres_table_stats": "postgres",
+	"postgres_ping":        "postgres",
+	"get_pods":             "kubernetes",
+	"k8s_events":           "kubernetes",
+	"k8s_top_pods":         "kubernetes",
+	"kubectl":              "kubernetes",
+	"k8s_watch_pods":       "kubernetes",
+	"k8s_diff":             "kubernetes",
+	"k8s_ping":             "kubernetes",
+	"read-query":           "sqlite",
+	"write-query":          "sqlite",
+	"bulk_insert":          "sqlite",
+	"begin_transaction":    "sqlite",
+	"create-SQLtable":      "sqlite",
+	"list-tables":          "sqlite",
+	"sqlite_schema":        "sqlite",
+	"sqlite_ping":          "sqlite",
+}
+
+// backendDown matches error messages that mean the backend itself could not
+// be reached, as opposed to a bad query or manifest. Errors coded
+// UPSTREAM_UNAVAILABLE or TIMEOUT always count.
+var backendDown = map[string]*regexp.Regexp{
+	"postgres":   regexp.MustCompile(`(?i)could not connect to server|connection to server .* failed|connection refused|server closed the connection`),
+	"kubernetes": regexp.MustCompile(`(?i)unable to connect to the server|connection refused|i/o timeout|no such host`),
+	"sqlite":     regexp.MustCompile(`(?i)unable to open database file|database is locked|disk I/O error`),
+}
+
+// toolBackend names the breaker guarding a call, or "" when the tool uses
+// no external backend. SQLite breakers are per database file.
+func toolBackend(req mcp.CallToolRequest) string {
+	name := req.Params.Name
+	backend := backendTools[name]
+	switch {
+	case dockerTools[name]:
+		backend = "docker"
+	case name == "validate_sql" || name == "create_index":
+		backend, _ = req.Params.Arguments["dialect"].(string)
+	case name == "run_sql_file":
+		backend = "sqlite"
+		if dsn, _ := req.Params.Arguments["dsn"].(string); dsn != "" {
+			backend = "postgres"
+		}
+	}
+	if backend == "sqlite" {
+		db, err := sqliteDB(req.Params.Arguments)
+		if err != nil {
+			return ""
+		}
+		return "sqlite:" + db
+	}
+	return backend
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+var breakerStateNames = [...]string{"closed", "open", "half-open"}
+
+func (s breakerState) String() string { return breakerStateNames[s] }
+
+// breaker is the state of one backend.
+type breaker struct {
+	state    breakerState
+	failures int
+	lastErr  string
+	openedAt time.Time
+	// probing is set while the single half-open trial call runs.
+	probing bool
+}
+
+// breakers short-circuits calls to a backend after threshold consecutive
+// failures reaching it. Once cooldown has passed one trial call is let
+// through: success closes the breaker, failure opens it again. Ping tools
+// always run, so they can be used to check on a backend.
+type breakers struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	backends  map[string]*breaker
+}
+
+// newBreakersFromEnv reads MCP_BREAKER_THRESHOLD (default 5) and
+// MCP_BREAKER_COOLDOWN (default 30s). It returns nil, disabling the
+// breakers, when the threshold is 0.
+func newBreakersFromEnv() *breakers {
+	threshold := envInt("MCP_BREAKER_THRESHOLD", 5)
+	if threshold <= 0 {
+		return nil
+	}
+	return &breakers{
+		threshold: threshold,
+		cooldown:  envDuration("MCP_BREAKER_COOLDOWN", 30*time.Second),
+		backends:  make(map[string]*breaker),
+	}
+}
+
+// allow reports whether a call to backend may run, and whether it is the
+// half-open trial.
+func (b *breakers) allow(backend string, probe bool) (trial bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br := b.backends[backend]
+	if br == nil || br.state == breakerClosed || probe {
+		return false, nil
+	}
+	if br.state == breakerOpen && time.Since(br.openedAt) >= b.cooldown {
+		br.state = breakerHalfOpen
+		log.Infof("Circuit breaker for %s is half-open; letting a trial call through", backend)
+	}
+	if br.state == breakerHalfOpen && !br.probing {
+		br.probing = true
+		return true, nil
+	}
+	retry := time.Until(br.openedAt.Add(b.cooldown)).Round(time.Second)
+	te := toolErrorf(CodeUpstreamUnavailable, "%s backend unavailable after %d consecutive failures; not trying again for %s", backend, br.failures, max(retry, 0))
+	te.Details = map[string]any{"backend": backend, "last_error": br.lastErr}
+	return false, te
+}
+
+// callOutcome classifies a finished call for the breaker.
+type callOutcome int
+
+const (
+	// outcomeOK means the backend answered, even if with an error.
+	outcomeOK callOutcome = iota
+	// outcomeDown means the call failed to reach the backend.
+	outcomeDown
+	// outcomeUnknown is a failure that says nothing about the backend, e.g.
+	// an invalid argument rejected before it was contacted.
+	outcomeUnknown
+)
+
+// record updates backend with the outcome of a call.
+func (b *breakers) record(backend string, trial bool, outcome callOutcome, errMsg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br := b.backends[backend]
+	if br == nil {
+		br = &breaker{}
+		b.backends[backend] = br
+	}
+	if trial {
+		br.probing = false
+	}
+	switch outcome {
+	case outcomeOK:
+		if br.state != breakerClosed {
+			log.Infof("Circuit breaker for %s closed; backend is reachable again", backend)
+		}
+		br.state, br.failures, br.lastErr = breakerClosed, 0, ""
+	case outcomeDown:
+		br.failures++
+		br.lastErr = errMsg
+		if br.state == breakerHalfOpen || (br.state == breakerClosed && br.failures >= b.threshold) {
+			log.Warnf("Circuit breaker for %s opened after %d consecutive failures: %s", backend, br.failures, errMsg)
+			br.state, br.openedAt = breakerOpen, time.Now()
+		}
+	}
+}
+
+// classifyOutcome inspects the result of a call to backend, returning the
+// error message of failed calls.
+func classifyOutcome(backend string, res *mcp.CallToolResult) (callOutcome, string) {
+	if res == nil || !res.IsError {
+		return outcomeOK, ""
+	}
+	var te ToolError
+	for _, c := range res.Content {
+		if text, ok := c.(mcp.TextContent); ok && json.Unmarshal([]byte(text.Text), &te) == nil {
+			break
+		}
+	}
+	if te.Code == CodeUpstreamUnavailable || te.Code == CodeTimeout {
+		return outcomeDown, te.Message
+	}
+	kind, _, _ := strings.Cut(backend, ":")
+	if re := backendDown[kind]; re != nil {
+		detail, _ := json.Marshal(te.Details)
+		if re.MatchString(te.Message) || re.Match(detail) {
+			return outcomeDown, te.Message
+		}
+	}
+	if te.Code == CodeCommandFailed || te.Code == CodeNotFound {
+		// The backend ran the command and rejected it.
+		return outcomeOK, te.Message
+	}
+	return outcomeUnknown, te.Message
+}
+
+// middleware short-circuits calls to backends whose breaker is open.
+func (b *breakers) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		backend := toolBackend(req)
+		if backend == "" {
+			return next(ctx, req)
+		}
+		probe := pingTools[req.Params.Name]
+		trial, err := b.allow(backend, probe)
+		if err != nil {
+			requestLog(ctx).Warnf("Short-circuited tool '%s': %v", req.Params.Name, err)
+			return errorResult(err), nil
+		}
+		res, callErr := next(ctx, req)
+		if callErr != nil {
+			res = errorResult(callErr)
+		}
+		outcome, msg := classifyOutcome(backend, res)
+		b.record(backend, trial, outcome, msg)
+		return res, callErr
+	}
+}
+
+// pingTools are the connectivity checks, which bypass open breakers.
+var pingTools = map[string]bool{
+	"docker_ping":   true,
+	"postgres_ping": true,
+	"sqlite_ping":   true,
+	"k8s_ping":      true,
+}
+
+// writeMetrics reports each backend's breaker state (0 closed, 1 open,
+// 2 half-open) in the Prometheus text format.
+func (b *breakers) writeMetrics(w io.Writer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.backends))
+	for name := range b.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "# HELP mcp_backend_breaker_state Circuit breaker state by backend (0 closed, 1 open, 2 half-open).")
+	fmt.Fprintln(w, "# TYPE mcp_backend_breaker_state gauge")
+	for _, name := range names {
+		fmt.Fprintf(w, "mcp_backend_breaker_state{backend=%q} %d\n", name, b.backends[name].state)
+	}
+}
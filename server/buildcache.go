@@ -0,0 +1,165 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// buildStep is one step of a docker build and whether it came from cache.
+type buildStep struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"` // "cached", "built", "base" or "error"
+	Duration string `json:"duration,omitempty"`
+}
+
+// buildCacheReport summarizes the layer cache use of a docker build.
+type buildCacheReport struct {
+	Builder string      `json:"builder"` // "buildkit" or "legacy"
+	Cached  int         `json:"cached"`
+	Built   int         `json:"built"`
+	Errors  int         `json:"errors"`
+	Steps   []buildStep `json:"steps"`
+	// FirstRebuilt is the first step that missed the cache; the steps after
+	// it in the same stage could not use the cache either.
+	FirstRebuilt string   `json:"first_rebuilt,omitempty"`
+	Hints        []string `json:"hints,omitempty"`
+}
+
+var (
+	// #6 [build 2/5] RUN go mod download
+	buildkitStep = regexp.MustCompile(`^#(\d+) (\[[^\]]*\d+/\d+\] .*)$`)
+	// #6 CACHED, #6 DONE 2.3s, #6 ERROR: ...
+	buildkitState = regexp.MustCompile(`^#(\d+) (CACHED|DONE|ERROR)\b:?\s*(.*)$`)
+	// Step 2/5 : RUN go mod download
+	legacyStep = regexp.MustCompile(`^Step (\d+/\d+) : (.*)$`)
+)
+
+// parseBuildCache reads the output of `docker build --progress=plain` (or
+// the legacy builder) and reports for each step whether it was a cache hit.
+func parseBuildCache(output string) buildCacheReport {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for _, l := range lines {
+		if legacyStep.MatchString(strings.TrimSpace(l)) {
+			return parseLegacyBuild(lines)
+		}
+	}
+	return parseBuildkit(lines)
+}
+
+// parseBuildkit handles BuildKit's plain progress output, where each step
+// is announced as "#N [i/n] INSTRUCTION" and ends with CACHED, DONE or
+// ERROR on a line of the same number.
+func parseBuildkit(lines []string) buildCacheReport {
+	rep := buildCacheReport{Builder: "buildkit", Steps: []buildStep{}}
+	index := map[string]int{}
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if m := buildkitStep.FindStringSubmatch(l); m != nil {
+			if _, seen := index[m[1]]; !seen {
+				index[m[1]] = len(rep.Steps)
+				rep.Steps = append(rep.Steps, buildStep{ID: "#" + m[1], Name: m[2]})
+			}
+			continue
+		}
+		m := buildkitState.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		i, ok := index[m[1]]
+		if !ok {
+			continue
+		}
+		step := &rep.Steps[i]
+		switch m[2] {
+		case "CACHED":
+			step.Status = "cached"
+		case "ERROR":
+			step.Status = "error"
+		case "DONE":
+			if step.Status == "" {
+				step.Status = "built"
+			}
+			step.Duration = m[3]
+		}
+	}
+	for i := range rep.Steps {
+		if isFromStep(rep.Steps[i].Name) {
+			rep.Steps[i].Status = "base"
+		} else if rep.Steps[i].Status == "" {
+			// Steps cancelled by another step's failure never finish.
+			rep.Steps[i].Status = "error"
+		}
+	}
+	rep.summarize()
+	return rep
+}
+
+// parseLegacyBuild handles the classic builder's "Step i/n : ..." output,
+// where a cache hit is reported as " ---> Using cache".
+func parseLegacyBuild(lines []string) buildCacheReport {
+	rep := buildCacheReport{Builder: "legacy", Steps: []buildStep{}}
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if m := legacyStep.FindStringSubmatch(l); m != nil {
+			step := buildStep{ID: m[1], Name: "[" + m[1] + "] " + m[2], Status: "built"}
+			if isFromStep(step.Name) {
+				step.Status = "base"
+			}
+			rep.Steps = append(rep.Steps, step)
+			continue
+		}
+		if len(rep.Steps) == 0 {
+			continue
+		}
+		step := &rep.Steps[len(rep.Steps)-1]
+		switch {
+		case strings.HasPrefix(l, "---> Using cache"):
+			step.Status = "cached"
+		case strings.Contains(l, "returned a non-zero code"), strings.HasPrefix(l, "ERROR"):
+			step.Status = "error"
+		}
+	}
+	rep.summarize()
+	return rep
+}
+
+// isFromStep reports whether a step pulls the base image of a stage.
+func isFromStep(name string) bool {
+	_, instr, _ := strings.Cut(name, "] ")
+	return strings.HasPrefix(strings.ToUpper(instr), "FROM ")
+}
+
+// summarize counts the steps and points out where the cache stopped being
+// used.
+func (r *buildCacheReport) summarize() {
+	for _, s := range r.Steps {
+		switch s.Status {
+		case "cached":
+			r.Cached++
+		case "built":
+			r.Built++
+			if r.FirstRebuilt == "" {
+				r.FirstRebuilt = s.Name
+			}
+		case "error":
+			r.Errors++
+		}
+	}
+	if r.FirstRebuilt == "" {
+		return
+	}
+	_, instr, _ := strings.Cut(r.FirstRebuilt, "] ")
+	upper := strings.ToUpper(instr)
+	switch {
+	case strings.HasPrefix(upper, "COPY . ") || strings.HasPrefix(upper, "ADD . "):
+		r.Hints = append(r.Hints, "the whole build context is copied before later steps; copy dependency manifests (go.mod, package.json, requirements.txt) first and install dependencies before copying the rest, and keep a .dockerignore")
+	case strings.HasPrefix(upper, "COPY ") || strings.HasPrefix(upper, "ADD "):
+		r.Hints = append(r.Hints, "a copied file changed; steps after it are rebuilt, so copy frequently changing files as late as possible")
+	case strings.HasPrefix(upper, "ARG ") || strings.HasPrefix(upper, "ENV "):
+		r.Hints = append(r.Hints, "a changed build argument or variable invalidates the steps after it; declare it just before the step that needs it")
+	}
+	if r.Cached == 0 && r.Built > 1 {
+		r.Hints = append(r.Hints, "no step was cached; the builder may lack a cache (e.g. a fresh CI runner) or the base image changed; consider --cache-from")
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// noCacheArg is the per-call argument that bypasses the result cache.
+const noCacheArg = "no_cache"
+
+// cacheableTools lists the idempotent, read-only tools whose results may be
+// served from the cache. Mutating tools must never appear here.
+var cacheableTools = map[string]bool{
+	"get_pods":    true,
+	"read-query":  true,
+	"list-tables": true,
+}
+
+// sqliteReadTools are the cacheable tools that read a SQLite DB; their
+// entries are dropped when a write to the same DB succeeds.
+var sqliteReadTools = map[string]bool{
+	"read-query":  true,
+	"list-tables": true,
+}
+
+// sqliteWriteTools change the contents of a SQLite DB.
+var sqliteWriteTools = map[string]bool{
+	"write-query":        true,
+	"bulk_insert":        true,
+	"run_sql_file":       true,
+	"create-SQLtable":    true,
+	"create_index":       true,
+	"commit_transaction": true,
+}
+
+type cacheEntry struct {
+	key     string
+	db      string
+	result  *mcp.CallToolResult
+	expires time.Time
+}
+
+// resultCache holds tool results keyed by tool name + normalized arguments.
+// It keeps at most maxEntries results, evicting the least recently used.
+type resultCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	lru        *list.List // of *cacheEntry, most recently used first
+	entries    map[string]*list.Element
+	// txs resolves the DB that commit_transaction writes to.
+	txs *txManager
+}
+
+func newResultCache(ttl time.Duration, maxEntries int, txs *txManager) *resultCache {
+	return &resultCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		txs:        txs,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// newResultCacheFromEnv builds a cache from MCP_CACHE_TTL (e.g. "30s") and
+// MCP_CACHE_MAX_ENTRIES (default 1000). It returns nil when the TTL is unset
+// or invalid, disabling caching.
+func newResultCacheFromEnv(txs *txManager) *resultCache {
+	ttl := envDuration("MCP_CACHE_TTL", 0)
+	if ttl <= 0 {
+		return nil
+	}
+	maxEntries := envInt("MCP_CACHE_MAX_ENTRIES", 1000)
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	log.Infof("Tool result cache enabled with TTL %s, at most %d entries", ttl, maxEntries)
+	return newResultCache(ttl, maxEntries, txs)
+}
+
+// cacheDB returns the absolute path of the SQLite DB a tool call reads or
+// writes, or "" when the call does not target a SQLite DB.
+func (c *resultCache) cacheDB(ctx context.Context, name string, args map[string]any) string {
+	switch name {
+	case "commit_transaction":
+		if session, err := sessionID(ctx); err == nil {
+			if tx := c.txs.lookup(session); tx != nil {
+				return tx.db
+			}
+		}
+		return ""
+	case "run_sql_file":
+		if dsn, _ := args["dsn"].(string); dsn != "" {
+			return ""
+		}
+	case "create_index":
+		if dialect, _ := args["dialect"].(string); dialect != "sqlite" {
+			return ""
+		}
+	}
+	db, err := sqliteDB(args)
+	if err != nil {
+		return ""
+	}
+	abs, err := filepath.Abs(db)
+	if err != nil {
+		return ""
+	}
+	return abs
+}
+
+// cacheKey builds a stable key from the tool name and its arguments.
+// encoding/json sorts map keys, so equal argument maps produce equal keys.
+func cacheKey(tool string, args map[string]any) (string, bool) {
+	normalized := make(map[string]any, len(args))
+	for k, v := range args {
+		if k == noCacheArg {
+			continue
+		}
+		normalized[k] = v
+	}
+	b, err := json.Marshal(normalized)
+	if err != nil {
+		return "", false
+	}
+	return tool + ":" + string(b), true
+}
+
+func (c *resultCache) get(key string) (*mcp.CallToolResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e.result, true
+}
+
+func (c *resultCache) set(key, db string, res *mcp.CallToolResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &cacheEntry{key: key, db: db, result: res, expires: time.Now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// invalidate drops every cached result read from db.
+func (c *resultCache) invalidate(db string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*cacheEntry).db == db {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+// remove unlinks el; c.mu must be held.
+func (c *resultCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// middleware serves cacheable tools from the cache and stores fresh results.
+func (c *resultCache) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := req.Params.Name
+		if sqliteWriteTools[name] {
+			db := c.cacheDB(ctx, name, req.Params.Arguments)
+			res, err := next(ctx, req)
+			if db != "" && err == nil && res != nil && !res.IsError {
+				c.invalidate(db)
+			}
+			return res, err
+		}
+		if !cacheableTools[name] {
+			return next(ctx, req)
+		}
+		if skip, _ := req.Params.Arguments[noCacheArg].(bool); skip {
+			requestLog(ctx).Debugf("Cache bypassed for tool '%s' (no_cache)", name)
+			return next(ctx, req)
+		}
+		key, ok := cacheKey(name, req.Params.Arguments)
+		if !ok {
+			return next(ctx, req)
+		}
+		if res, hit := c.get(key); hit {
+			requestLog(ctx).Debugf("Cache hit for tool '%s'", name)
+			return res, nil
+		}
+		requestLog(ctx).Debugf("Cache miss for tool '%s'", name)
+
+		res, err := next(ctx, req)
+		if err == nil && res != nil && !res.IsError {
+			var db string
+			if sqliteReadTools[name] {
+				db = c.cacheDB(ctx, name, req.Params.Arguments)
+			}
+			c.set(key, db, res)
+		}
+		return res, err
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// logSeverity orders the MCP logging levels, least severe first.
+var logSeverity = map[mcp.LoggingLevel]int{
+	mcp.LoggingLevelDebug:     0,
+	mcp.LoggingLevelInfo:      1,
+	mcp.LoggingLevelNotice:    2,
+	mcp.LoggingLevelWarning:   3,
+	mcp.LoggingLevelError:     4,
+	mcp.LoggingLevelCritical:  5,
+	mcp.LoggingLevelAlert:     6,
+	mcp.LoggingLevelEmergency: 7,
+}
+
+// clientLogLevels maps MCP session IDs to the level the client asked for with
+// logging/setLevel. Sessions that never asked get defaultClientLogLevel.
+var clientLogLevels sync.Map
+
+// defaultClientLogLevel is the least severe level sent to clients that have
+// not called logging/setLevel, from MCP_CLIENT_LOG_LEVEL (default info).
+var defaultClientLogLevel = sync.OnceValue(func() mcp.LoggingLevel {
+	level := mcp.LoggingLevel(os.Getenv("MCP_CLIENT_LOG_LEVEL"))
+	if _, ok := logSeverity[level]; ok {
+		return level
+	}
+	if level != "" {
+		log.Warnf("Ignoring invalid MCP_CLIENT_LOG_LEVEL %q; using info", level)
+	}
+	return mcp.LoggingLevelInfo
+})
+
+// rememberLogLevel records the level a client set with logging/setLevel.
+func rememberLogLevel(ctx context.Context, id any, req *mcp.SetLevelRequest, res *mcp.EmptyResult) {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		clientLogLevels.Store(session.SessionID(), req.Params.Level)
+	}
+}
+
+// forgetLogLevel drops the log level of a closed session.
+func forgetLogLevel(ctx context.Context, session server.ClientSession) {
+	clientLogLevels.Delete(session.SessionID())
+}
+
+// clientLogEnabled reports whether the client of ctx's session wants messages
+// at level.
+func clientLogEnabled(ctx context.Context, level mcp.LoggingLevel) bool {
+	threshold := defaultClientLogLevel()
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		if l, ok := clientLogLevels.Load(session.SessionID()); ok {
+			threshold = l.(mcp.LoggingLevel)
+		}
+	}
+	return logSeverity[level] >= logSeverity[threshold]
+}
+
+// clientLog sends a notifications/message log entry to the client of ctx's
+// session while a tool runs, if the client's level lets it through. Failing
+// to send never fails the tool call.
+func clientLog(ctx context.Context, level mcp.LoggingLevel, logger string, data any) {
+	if !clientLogEnabled(ctx, level) {
+		return
+	}
+	err := mcpServer.SendNotificationToClient(ctx, "notifications/message", map[string]any{
+		"level":  level,
+		"logger": logger,
+		"data":   data,
+	})
+	if err != nil {
+		requestLog(ctx).Debugf("Failed to send %s log message to client: %v", level, err)
+	}
+}
+
+// toolLog sends log messages about one tool call to its client, under the
+// tool's name.
+type toolLog struct {
+	ctx  context.Context
+	tool string
+}
+
+// newToolLog returns the client log of the tool call req.
+func newToolLog(ctx context.Context, req mcp.CallToolRequest) toolLog {
+	return toolLog{ctx: ctx, tool: req.Params.Name}
+}
+
+// toolLogFromContext returns the client log of the tool call ctx belongs to,
+// for helpers that are not handed the request.
+func toolLogFromContext(ctx context.Context) toolLog {
+	tool, _ := requestLog(ctx).Data["tool"].(string)
+	if tool == "" {
+		tool = "mcpserver"
+	}
+	return toolLog{ctx: ctx, tool: tool}
+}
+
+func (l toolLog) Debugf(format string, args ...any) {
+	clientLog(l.ctx, mcp.LoggingLevelDebug, l.tool, fmt.Sprintf(format, args...))
+}
+
+func (l toolLog) Infof(format string, args ...any) {
+	clientLog(l.ctx, mcp.LoggingLevelInfo, l.tool, fmt.Sprintf(format, args...))
+}
+
+func (l toolLog) Warnf(format string, args ...any) {
+	clientLog(l.ctx, mcp.LoggingLevelWarning, l.tool, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// composeFileNames are the default compose file names, in the order docker
+// compose looks for them.
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// composeProject resolves the project directory and compose file of a
+// compose tool call, both confined to the workspace.
+func composeProject(projectDir, file string) (dir, path string, err error) {
+	dir, err = workspacePath(projectDir)
+	if err != nil {
+		return "", "", err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return "", "", fmt.Errorf("project directory not found: %w", err)
+	} else if !info.IsDir() {
+		return "", "", toolErrorf(CodeInvalidParam, "project_dir %q is not a directory", projectDir)
+	}
+
+	if file != "" {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if path, err = workspacePath(file); err != nil {
+			return "", "", err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return "", "", fmt.Errorf("compose file not found: %w", err)
+		}
+		return dir, path, nil
+	}
+	for _, name := range composeFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir, filepath.Join(dir, name), nil
+		}
+	}
+	return "", "", toolErrorf(CodeNotFound, "no compose file (%s) in %s", strings.Join(composeFileNames, ", "), projectDir)
+}
+
+// runCompose runs `docker compose` against the project and returns stdout.
+func runCompose(ctx context.Context, dir, file string, env []string, args ...string) (string, error) {
+	base := []string{"compose", "--project-directory", dir, "-f", file}
+	cmd := toolCommand(ctx, env, "docker", append(base, args...)...)
+	var stdout, stderr bytes.Buffer
+	if err := runCommand(cmd, &stdout, &stderr); err != nil {
+		te := asToolError(fmt.Errorf("docker compose %s failed: %w", args[0], err))
+		if out := strings.TrimSpace(stderr.String()); out != "" {
+			te.Details = map[string]any{"output": out}
+		}
+		return "", te
+	}
+	return stdout.String(), nil
+}
+
+// validateCompose checks that the compose file parses, without side effects.
+func validateCompose(ctx context.Context, dir, file string, env []string) error {
+	_, err := runCompose(ctx, dir, file, env, "config", "--quiet")
+	return err
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// dataFormats are the formats convert_format reads and writes.
+var dataFormats = []string{"json", "yaml", "toml"}
+
+// formatFromExt guesses the data format of a file from its extension.
+func formatFromExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return ""
+}
+
+// convertFormat parses data in format from and renders it in format to.
+// Parse errors are INVALID_PARAM and carry the line and column when the
+// parser reports one.
+func convertFormat(data []byte, from, to string) (string, error) {
+	v, err := decodeData(data, from)
+	if err != nil {
+		return "", err
+	}
+	return encodeData(v, to)
+}
+
+// decodeData parses data into plain maps, slices and scalars. A YAML stream
+// with several documents decodes to a list of them.
+func decodeData(data []byte, format string) (any, error) {
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, jsonParseError(data, err)
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			line, col := lineCol(data, dec.InputOffset())
+			return nil, toolErrorf(CodeInvalidParam, "invalid JSON at line %d, column %d: unexpected data after the top-level value", line, col)
+		}
+		return plainValue(v), nil
+	case "yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		var docs []any
+		for {
+			var v any
+			err := dec.Decode(&v)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, toolErrorf(CodeInvalidParam, "invalid YAML: %s", strings.TrimPrefix(err.Error(), "yaml: "))
+			}
+			docs = append(docs, plainValue(v))
+		}
+		switch len(docs) {
+		case 0:
+			return nil, nil
+		case 1:
+			return docs[0], nil
+		}
+		return docs, nil
+	case "toml":
+		var v map[string]any
+		if err := toml.Unmarshal(data, &v); err != nil {
+			var de *toml.DecodeError
+			if errors.As(err, &de) {
+				row, col := de.Position()
+				return nil, toolErrorf(CodeInvalidParam, "invalid TOML at line %d, column %d: %v", row, col, de)
+			}
+			return nil, toolErrorf(CodeInvalidParam, "invalid TOML: %v", err)
+		}
+		return plainValue(v), nil
+	}
+	return nil, toolErrorf(CodeInvalidParam, "unsupported format %q (use one of %s)", format, strings.Join(dataFormats, ", "))
+}
+
+// jsonParseError turns a decoding error into an INVALID_PARAM error with the
+// line and column of the offending byte.
+func jsonParseError(data []byte, err error) error {
+	offset, err := jsonErrorOffset(data, err)
+	if offset < 0 {
+		return toolErrorf(CodeInvalidParam, "invalid JSON: %v", err)
+	}
+	line, col := lineCol(data, offset)
+	return toolErrorf(CodeInvalidParam, "invalid JSON at line %d, column %d: %v", line, col, err)
+}
+
+// jsonErrorOffset returns the byte offset a JSON decoding error points at,
+// or -1 when it has none, and the error to report.
+func jsonErrorOffset(data []byte, err error) (int64, error) {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		return syntax.Offset, err
+	case errors.As(err, &typ):
+		return typ.Offset, err
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return int64(len(data)), errors.New("unexpected end of input")
+	}
+	return -1, err
+}
+
+// lineCol converts a byte offset in data to a 1-based line and column.
+func lineCol(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - (bytes.LastIndexByte(before, '\n') + 1)
+	if col < 1 {
+		col = 1
+	}
+	return line, col
+}
+
+// plainValue rewrites decoded values into types every encoder handles:
+// json.Number becomes int64 or float64 and YAML maps with non-string keys
+// get string keys.
+func plainValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = plainValue(e)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = plainValue(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = plainValue(e)
+		}
+		return v
+	}
+	return v
+}
+
+// encodeData renders v in format.
+func encodeData(v any, format string) (string, error) {
+	switch format {
+	case "json":
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return "", toolErrorf(CodeInvalidParam, "cannot encode as JSON: %v", err)
+		}
+		return b.String(), nil
+	case "yaml":
+		var b bytes.Buffer
+		enc := yaml.NewEncoder(&b)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return "", toolErrorf(CodeInvalidParam, "cannot encode as YAML: %v", err)
+		}
+		if err := enc.Close(); err != nil {
+			return "", toolErrorf(CodeInvalidParam, "cannot encode as YAML: %v", err)
+		}
+		return b.String(), nil
+	case "toml":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return "", toolErrorf(CodeInvalidParam, "cannot encode as TOML: the top level must be a table, not %s", kindOf(v))
+		}
+		if path := tomlNull(m, ""); path != "" {
+			return "", toolErrorf(CodeInvalidParam, "cannot encode as TOML: %s is null, which TOML cannot represent", path)
+		}
+		b, err := toml.Marshal(m)
+		if err != nil {
+			return "", toolErrorf(CodeInvalidParam, "cannot encode as TOML: %v", err)
+		}
+		return string(b), nil
+	}
+	return "", toolErrorf(CodeInvalidParam, "unsupported format %q (use one of %s)", format, strings.Join(dataFormats, ", "))
+}
+
+// tomlNull returns the path of the first null value in v, or "".
+func tomlNull(v any, path string) string {
+	switch v := v.(type) {
+	case nil:
+		if path == "" {
+			return "the document"
+		}
+		return path
+	case map[string]any:
+		for k, e := range v {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			if n := tomlNull(e, p); n != "" {
+				return n
+			}
+		}
+	case []any:
+		for i, e := range v {
+			if n := tomlNull(e, fmt.Sprintf("%s[%d]", path, i)); n != "" {
+				return n
+			}
+		}
+	}
+	return ""
+}
+
+// kindOf names the kind of a decoded value for error messages.
+func kindOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case []any:
+		return "a list"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int64, float64, int:
+		return "a number"
+	}
+	return fmt.Sprintf("%T", v)
+}
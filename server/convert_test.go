@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// TestConvertFormatKeepsInlineContent runs inline JSON holding path-like
+// strings through the argument normalization that precedes every handler.
+func TestConvertFormatKeepsInlineContent(t *testing.T) {
+	doc := `  {"url": "http://example.com/a/../b", "dir": "./data//cache/", "up": "../etc"}` + "\n"
+	for _, arg := range []string{"content", "input"} {
+		t.Run(arg, func(t *testing.T) {
+			var got string
+			handler := normalizeMiddleware(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				content, _ := req.Params.Arguments["content"].(string)
+				out, err := convertFormat([]byte(content), "json", "yaml")
+				if err != nil {
+					return nil, err
+				}
+				got = out
+				return mcp.NewToolResultText(out), nil
+			})
+			req := mcp.CallToolRequest{}
+			req.Params.Name = "convert_format"
+			req.Params.Arguments = map[string]any{arg: doc, "to": "yaml"}
+			if _, err := handler(context.Background(), req); err != nil {
+				t.Fatalf("convert_format: %v", err)
+			}
+			for _, want := range []string{"http://example.com/a/../b", "./data//cache/", "../etc"} {
+				if !strings.Contains(got, want) {
+					t.Errorf("converted YAML lost %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/go-units"
+)
+
+// diskUsage is the free space of the filesystem holding one path.
+type diskUsage struct {
+	Label       string  `json:"label"`
+	Path        string  `json:"path"`
+	Total       string  `json:"total,omitempty"`
+	Free        string  `json:"free,omitempty"`
+	FreePercent float64 `json:"free_percent,omitempty"`
+	Low         bool    `json:"low,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// minFreeDisk is the free space below which disk_usage flags a filesystem
+// and builds are refused, from MCP_MIN_FREE_DISK_MB (default 2048).
+func minFreeDisk() uint64 {
+	return uint64(max(envInt("MCP_MIN_FREE_DISK_MB", 2048), 0)) << 20
+}
+
+func measureDisk(label, path string) diskUsage {
+	u := diskUsage{Label: label, Path: path}
+	total, free, err := statDisk(path)
+	if err != nil {
+		u.Error = err.Error()
+		return u
+	}
+	u.Total = units.BytesSize(float64(total))
+	u.Free = units.BytesSize(float64(free))
+	if total > 0 {
+		u.FreePercent = float64(free*1000/total) / 10
+	}
+	u.Low = free < minFreeDisk()
+	return u
+}
+
+// diskReport measures the workspace and the Docker daemon's data directory.
+// The latter is only measurable when the daemon runs on this host.
+func diskReport(ctx context.Context) []diskUsage {
+	var report []diskUsage
+	if root, err := workspaceRoot(); err != nil {
+		report = append(report, diskUsage{Label: "workspace", Error: err.Error()})
+	} else {
+		report = append(report, measureDisk("workspace", root))
+	}
+
+	cli, err := newDockerClient()
+	if err != nil {
+		return append(report, diskUsage{Label: "docker", Error: err.Error()})
+	}
+	defer cli.Close()
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return append(report, diskUsage{Label: "docker", Error: dockerError("daemon", err).Message})
+	}
+	u := measureDisk("docker", info.DockerRootDir)
+	if u.Error != "" {
+		u.Error = fmt.Sprintf("cannot measure the Docker data directory from the server (daemon on another host?): %s", u.Error)
+	}
+	return append(report, u)
+}
+
+// checkDiskSpace fails with RESOURCE_EXHAUSTED when a measured filesystem has
+// less than minFreeDisk available, so a build is refused up front rather
+// than failing midway. Filesystems that cannot be measured are skipped.
+func checkDiskSpace(report []diskUsage) error {
+	for _, u := range report {
+		if u.Low {
+			te := toolErrorf(CodeResourceExhausted, "only %s free on the %s filesystem (%s); at least %s is required (MCP_MIN_FREE_DISK_MB)",
+				u.Free, u.Label, u.Path, units.BytesSize(float64(minFreeDisk())))
+			te.Details = map[string]any{"disk": report}
+			return te
+		}
+	}
+	return nil
+}
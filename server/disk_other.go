@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// statDisk is not implemented on this platform.
+func statDisk(path string) (total, free uint64, err error) {
+	return 0, 0, errors.New("disk usage is not supported on this platform")
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	img "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-units"
+	log "github.com/sirupsen/logrus"
+)
+
+// newDockerClient connects to the Docker daemon configured in the environment.
+func newDockerClient() (*client.Client, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	return cli, nil
+}
+
+// dockerError turns a Docker API error into a ToolError that tells a
+// missing object apart from an unreachable daemon.
+func dockerError(what string, err error) *ToolError {
+	switch {
+	case errdefs.IsNotFound(err):
+		return toolErrorf(CodeNotFound, "no such %s: %w", what, err)
+	case client.IsErrConnectionFailed(err):
+		return toolErrorf(CodeUpstreamUnavailable, "Docker daemon is not reachable (is it running?): %w", err)
+	default:
+		return toolErrorf(classifyError(err), "Docker error: %w", err)
+	}
+}
+
+// formatImageHistory renders image layers as a compact table, newest first.
+func formatImageHistory(items []img.HistoryResponseItem) string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CREATED\tSIZE\tCREATED BY")
+	for _, it := range items {
+		createdBy := strings.Join(strings.Fields(it.CreatedBy), " ")
+		if len(createdBy) > 80 {
+			createdBy = createdBy[:77] + "..."
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n",
+			time.Unix(it.Created, 0).UTC().Format(time.RFC3339),
+			units.HumanSize(float64(it.Size)),
+			createdBy,
+		)
+	}
+	tw.Flush()
+	return b.String()
+}
+
+// imageSummary is the trimmed view of an image returned by docker_image_inspect.
+type imageSummary struct {
+	ID           string   `json:"id"`
+	Tags         []string `json:"tags"`
+	Size         string   `json:"size"`
+	Architecture string   `json:"architecture"`
+	OS           string   `json:"os"`
+	ExposedPorts []string `json:"exposed_ports,omitempty"`
+	Env          []string `json:"env,omitempty"`
+	Entrypoint   []string `json:"entrypoint,omitempty"`
+	Cmd          []string `json:"cmd,omitempty"`
+}
+
+func summarizeImage(info img.InspectResponse) imageSummary {
+	sum := imageSummary{
+		ID:           info.ID,
+		Tags:         info.RepoTags,
+		Size:         units.HumanSize(float64(info.Size)),
+		Architecture: info.Architecture,
+		OS:           info.Os,
+	}
+	if cfg := info.Config; cfg != nil {
+		for port := range cfg.ExposedPorts {
+			sum.ExposedPorts = append(sum.ExposedPorts, string(port))
+		}
+		sort.Strings(sum.ExposedPorts)
+		sum.Env = cfg.Env
+		sum.Entrypoint = cfg.Entrypoint
+		sum.Cmd = cfg.Cmd
+	}
+	return sum
+}
+
+// daemonSummary is the trimmed view of the daemon returned by docker_info.
+type daemonSummary struct {
+	ServerVersion     string `json:"server_version"`
+	Containers        int    `json:"containers"`
+	ContainersRunning int    `json:"containers_running"`
+	ContainersStopped int    `json:"containers_stopped"`
+	Images            int    `json:"images"`
+	StorageDriver     string `json:"storage_driver"`
+	CPUs              int    `json:"cpus"`
+	TotalMemory       string `json:"total_memory"`
+	OS                string `json:"os"`
+	Architecture      string `json:"architecture"`
+	KernelVersion     string `json:"kernel_version"`
+}
+
+func summarizeDaemon(info system.Info) daemonSummary {
+	return daemonSummary{
+		ServerVersion:     info.ServerVersion,
+		Containers:        info.Containers,
+		ContainersRunning: info.ContainersRunning,
+		ContainersStopped: info.ContainersStopped,
+		Images:            info.Images,
+		StorageDriver:     info.Driver,
+		CPUs:              info.NCPU,
+		TotalMemory:       units.BytesSize(float64(info.MemTotal)),
+		OS:                info.OperatingSystem,
+		Architecture:      info.Architecture,
+		KernelVersion:     info.KernelVersion,
+	}
+}
+
+// renderFormat applies a docker-style Go template (e.g. "{{.Os}}") to v.
+func renderFormat(format string, v any) (string, error) {
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(format)
+	if err != nil {
+		return "", fmt.Errorf("invalid format template: %v", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, v); err != nil {
+		return "", fmt.Errorf("failed to render format template: %v", err)
+	}
+	return b.String(), nil
+}
+
+// loadedImages reads the progress stream of an image load and returns the
+// tags (or IDs, for untagged images) the daemon reports as loaded.
+func loadedImages(body io.Reader) ([]string, error) {
+	var loaded []string
+	dec := json.NewDecoder(body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return loaded, nil
+		} else if err != nil {
+			return loaded, fmt.Errorf("failed to read load response: %v", err)
+		}
+		if msg.Error != nil {
+			return loaded, errors.New(msg.Error.Message)
+		}
+		line := strings.TrimSpace(msg.Stream)
+		for _, prefix := range []string{"Loaded image: ", "Loaded image ID: "} {
+			if ref, ok := strings.CutPrefix(line, prefix); ok {
+				loaded = append(loaded, ref)
+			}
+		}
+	}
+}
+
+// maxRegistryBackoff caps the delay between registry retries.
+const maxRegistryBackoff = 30 * time.Second
+
+// retryRegistry runs op, retrying transient registry failures (rate limits,
+// 5xx responses, timeouts) with exponential backoff. The number of retries
+// comes from MCP_REGISTRY_RETRIES (default 3) and the first delay from
+// MCP_REGISTRY_BACKOFF (default 1s).
+func retryRegistry(ctx context.Context, what string, op func() error) error {
+	retries := envInt("MCP_REGISTRY_RETRIES", 3)
+	backoff := envDuration("MCP_REGISTRY_BACKOFF", time.Second)
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt > retries || !retryableRegistryError(err) {
+			return err
+		}
+		log.Warnf("%s failed (attempt %d of %d): %v; retrying in %s", what, attempt, retries+1, err, backoff)
+		toolLogFromContext(ctx).Warnf("%s failed (attempt %d of %d): %v; retrying in %s", what, attempt, retries+1, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, maxRegistryBackoff)
+	}
+}
+
+// retryableRegistryError reports whether err looks transient. Auth failures
+// and missing images are never retried.
+func retryableRegistryError(err error) bool {
+	switch {
+	case errdefs.IsNotFound(err), errdefs.IsUnauthorized(err), errdefs.IsForbidden(err),
+		errdefs.IsInvalidParameter(err), client.IsErrConnectionFailed(err):
+		return false
+	case errdefs.IsUnavailable(err), errdefs.IsDeadline(err):
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, permanent := range []string{"unauthorized", "denied", "authentication required", "not found", "manifest unknown", "no such"} {
+		if strings.Contains(msg, permanent) {
+			return false
+		}
+	}
+	for _, transient := range []string{
+		"toomanyrequests", "too many requests", "429",
+		"500 internal server error", "502 bad gateway", "503 service unavailable", "504 gateway timeout",
+		"timeout", "connection reset", "connection refused", "eof", "temporary failure",
+	} {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// pullImage pulls ref and waits for the pull to finish, writing progress to
+// stderr. Errors reported inside the progress stream (which is where the
+// daemon puts registry failures) are returned as errors.
+func pullImage(ctx context.Context, cli *client.Client, ref string) error {
+	out, err := cli.ImagePull(ctx, ref, img.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	// Layer status goes to the client as debug messages, and to stderr as
+	// the docker CLI would print it.
+	tl := toolLogFromContext(ctx)
+	dec := json.NewDecoder(out)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		msg.Display(os.Stderr, false)
+		switch {
+		case msg.Progress != nil:
+			// Download progress is too chatty to forward.
+		case msg.ID != "":
+			tl.Debugf("%s: %s", msg.ID, msg.Status)
+		case msg.Status != "":
+			tl.Infof("%s", msg.Status)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// execResult is the outcome of a docker_exec call.
+type execResult struct {
+	ExitCode  int    `json:"exit_code"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// cappedBuffer keeps the first max bytes written to it and drops the rest,
+// so a chatty command cannot grow the result without bound.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); len(p) > room {
+		b.truncated = true
+		b.buf.Write(p[:max(room, 0)])
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// execInContainer runs cmd in a running container and collects its output,
+// each stream capped at maxBytes. When ctx ends the output stream is closed;
+// Docker offers no way to stop the process itself, so it may keep running in
+// the container.
+func execInContainer(ctx context.Context, cli *client.Client, name string, cmd []string, maxBytes int) (execResult, error) {
+	created, err := cli.ContainerExecCreate(ctx, name, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return execResult{}, err
+	}
+	resp, err := cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return execResult{}, err
+	}
+	defer resp.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			resp.Close()
+		case <-done:
+		}
+	}()
+
+	// Without a TTY stdout and stderr arrive multiplexed on one stream.
+	stdout := &cappedBuffer{max: maxBytes}
+	stderr := &cappedBuffer{max: maxBytes}
+	_, copyErr := stdcopy.StdCopy(stdout, stderr, resp.Reader)
+	if ctx.Err() != nil {
+		return execResult{}, toolErrorf(CodeTimeout, "command did not finish in time: %w", ctx.Err())
+	}
+	if copyErr != nil {
+		return execResult{}, copyErr
+	}
+
+	// The exit code is recorded shortly after the stream ends.
+	var info container.ExecInspect
+	for i := 0; i < 10; i++ {
+		if info, err = cli.ContainerExecInspect(ctx, created.ID); err != nil {
+			return execResult{}, err
+		}
+		if !info.Running {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return execResult{
+		ExitCode:  info.ExitCode,
+		Stdout:    stdout.buf.String(),
+		Stderr:    stderr.buf.String(),
+		Truncated: stdout.truncated || stderr.truncated,
+	}, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dockerfileFinding is one problem reported by dockerfile_lint.
+type dockerfileFinding struct {
+	Line    int    `json:"line"`
+	Level   string `json:"level"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// dockerfileReport is the result of dockerfile_lint. Linter is "hadolint"
+// or "builtin".
+type dockerfileReport struct {
+	Linter   string              `json:"linter"`
+	Findings []dockerfileFinding `json:"findings"`
+}
+
+// lintDockerfile checks content with hadolint when it is installed, and with
+// the built-in checks otherwise or when hadolint cannot be run.
+func lintDockerfile(ctx context.Context, content string, env []string) dockerfileReport {
+	if _, err := exec.LookPath("hadolint"); err == nil {
+		findings, err := runHadolint(ctx, content, env)
+		if err == nil {
+			return dockerfileReport{Linter: "hadolint", Findings: findings}
+		}
+		requestLog(ctx).Warnf("hadolint failed, using built-in Dockerfile checks: %v", err)
+	}
+	return dockerfileReport{Linter: "builtin", Findings: builtinDockerfileLint(content)}
+}
+
+// runHadolint lints content read from stdin with hadolint's JSON output.
+func runHadolint(ctx context.Context, content string, env []string) ([]dockerfileFinding, error) {
+	cmd := toolCommand(ctx, env, "hadolint", "--no-fail", "--format", "json", "-")
+	cmd.Stdin = strings.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	if err := runCommand(cmd, &stdout, &stderr); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var findings []dockerfileFinding
+	if err := json.Unmarshal(stdout.Bytes(), &findings); err != nil {
+		return nil, fmt.Errorf("unexpected hadolint output: %v", err)
+	}
+	if findings == nil {
+		findings = []dockerfileFinding{}
+	}
+	return findings, nil
+}
+
+// dockerInstruction is one logical Dockerfile instruction, with line
+// continuations joined and heredoc bodies dropped.
+type dockerInstruction struct {
+	line int
+	cmd  string
+	args string
+}
+
+// dockerfileInstructions splits a Dockerfile into instructions, honouring the
+// escape parser directive, comments, line continuations and heredocs.
+func dockerfileInstructions(content string) []dockerInstruction {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	escape := `\`
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, "#") {
+			break
+		}
+		if k, v, ok := strings.Cut(strings.TrimSpace(l[1:]), "="); ok && strings.EqualFold(strings.TrimSpace(k), "escape") {
+			if v = strings.TrimSpace(v); v == "`" {
+				escape = v
+			}
+		}
+	}
+
+	var out []dockerInstruction
+	for i := 0; i < len(lines); i++ {
+		l := strings.TrimSpace(lines[i])
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		start := i + 1
+		var b strings.Builder
+		for {
+			if cont, ok := strings.CutSuffix(l, escape); ok {
+				b.WriteString(cont)
+				b.WriteString(" ")
+				// Comment and blank lines inside a continuation are skipped.
+				for i++; i < len(lines); i++ {
+					l = strings.TrimSpace(lines[i])
+					if l != "" && !strings.HasPrefix(l, "#") {
+						break
+					}
+				}
+				if i < len(lines) {
+					continue
+				}
+				break
+			}
+			b.WriteString(l)
+			break
+		}
+		cmd, args, _ := strings.Cut(strings.TrimSpace(b.String()), " ")
+		ins := dockerInstruction{line: start, cmd: strings.ToUpper(cmd), args: strings.TrimSpace(args)}
+		out = append(out, ins)
+		for _, m := range heredocStart.FindAllStringSubmatch(ins.args, -1) {
+			for i++; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) == m[1] {
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// heredocStart matches the start of a heredoc such as <<EOF or <<-"EOF".
+var heredocStart = regexp.MustCompile(`<<-?["']?([A-Za-z_][A-Za-z0-9_]*)["']?`)
+
+// knownInstructions are the Dockerfile instructions the builder accepts.
+var knownInstructions = map[string]bool{
+	"ADD": true, "ARG": true, "CMD": true, "COPY": true, "ENTRYPOINT": true,
+	"ENV": true, "EXPOSE": true, "FROM": true, "HEALTHCHECK": true,
+	"LABEL": true, "MAINTAINER": true, "ONBUILD": true, "RUN": true,
+	"SHELL": true, "STOPSIGNAL": true, "USER": true, "VOLUME": true,
+	"WORKDIR": true,
+}
+
+var (
+	flagArg       = regexp.MustCompile(`^--[a-z-]+(=\S*)?\s+`)
+	archiveSource = regexp.MustCompile(`(?i)\.(tar|tar\.gz|tgz|tar\.bz2|tbz2|tar\.xz|txz)$`)
+	exposePort    = regexp.MustCompile(`^(\d+)(-\d+)?(/(tcp|udp|sctp))?$`)
+	runCd         = regexp.MustCompile(`(^|&&|;)\s*cd\s`)
+	runSudo       = regexp.MustCompile(`(^|\s)sudo\s`)
+	aptUpgrade    = regexp.MustCompile(`apt-get\s+(-\S+\s+)*(dist-)?upgrade`)
+	aptInstall    = regexp.MustCompile(`apt-get\s+(-\S+\s+)*install`)
+	aptYes        = regexp.MustCompile(`\s(-[a-z]*y[a-z]*|--yes|--assume-yes)(\s|$)`)
+	pipInstall    = regexp.MustCompile(`pip3?\s+install`)
+	apkAdd        = regexp.MustCompile(`apk\s+add`)
+)
+
+// builtinDockerfileLint runs a small set of hadolint-style checks, for hosts
+// without hadolint.
+func builtinDockerfileLint(content string) []dockerfileFinding {
+	findings := []dockerfileFinding{}
+	add := func(line int, level, code, format string, args ...any) {
+		findings = append(findings, dockerfileFinding{Line: line, Level: level, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	instructions := dockerfileInstructions(content)
+	if len(instructions) == 0 {
+		add(1, "error", "BL001", "Dockerfile has no instructions")
+		return findings
+	}
+	stages := map[string]bool{}
+	var lastUser string
+	var userLine, cmds, entrypoints int
+	seenFrom := false
+	for _, ins := range instructions {
+		if !knownInstructions[ins.cmd] {
+			add(ins.line, "error", "BL002", "unknown instruction %s", ins.cmd)
+			continue
+		}
+		if !seenFrom && ins.cmd != "FROM" && ins.cmd != "ARG" {
+			add(ins.line, "error", "BL003", "%s before the first FROM; only ARG may come first", ins.cmd)
+		}
+		args := ins.args
+		for flagArg.MatchString(args) {
+			args = flagArg.ReplaceAllString(args, "")
+		}
+		switch ins.cmd {
+		case "FROM":
+			seenFrom = true
+			lastUser, cmds, entrypoints = "", 0, 0
+			fields := strings.Fields(args)
+			if len(fields) == 0 {
+				add(ins.line, "error", "BL004", "FROM needs an image")
+				continue
+			}
+			image := fields[0]
+			if len(fields) >= 3 && strings.EqualFold(fields[1], "AS") {
+				stages[strings.ToLower(fields[2])] = true
+			}
+			switch {
+			case image == "scratch" || stages[strings.ToLower(image)] || strings.Contains(image, "$"):
+			case strings.Contains(image, "@"):
+			case strings.HasSuffix(image, ":latest"):
+				add(ins.line, "warning", "DL3007", "image %s uses the latest tag; pin a version", image)
+			case !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":"):
+				add(ins.line, "warning", "DL3006", "image %s has no tag; pin a version", image)
+			}
+		case "MAINTAINER":
+			add(ins.line, "error", "DL4000", "MAINTAINER is deprecated; use LABEL maintainer=...")
+		case "ADD":
+			fields := strings.Fields(args)
+			for _, src := range fields[:max(len(fields)-1, 0)] {
+				if !strings.Contains(src, "://") && !archiveSource.MatchString(src) {
+					add(ins.line, "error", "DL3020", "use COPY instead of ADD for files and directories")
+					break
+				}
+			}
+		case "USER":
+			lastUser, userLine = args, ins.line
+		case "WORKDIR":
+			if !strings.HasPrefix(args, "/") && !strings.HasPrefix(args, "$") {
+				add(ins.line, "warning", "DL3000", "WORKDIR %s is relative; use an absolute path", args)
+			}
+		case "EXPOSE":
+			for _, p := range strings.Fields(args) {
+				m := exposePort.FindStringSubmatch(p)
+				if m == nil {
+					if !strings.Contains(p, "$") {
+						add(ins.line, "error", "DL3011", "invalid port %s", p)
+					}
+					continue
+				}
+				if n, _ := strconv.Atoi(m[1]); n < 1 || n > 65535 {
+					add(ins.line, "error", "DL3011", "port %s is out of range 1-65535", p)
+				}
+			}
+		case "CMD", "ENTRYPOINT":
+			if ins.cmd == "CMD" {
+				cmds++
+				if cmds == 2 {
+					add(ins.line, "warning", "DL4003", "multiple CMD instructions in one stage; only the last takes effect")
+				}
+			} else {
+				entrypoints++
+				if entrypoints == 2 {
+					add(ins.line, "error", "DL4004", "multiple ENTRYPOINT instructions in one stage; only the last takes effect")
+				}
+			}
+			if !strings.HasPrefix(args, "[") {
+				add(ins.line, "warning", "DL3025", "use the JSON form for %s so signals reach the process", ins.cmd)
+			}
+		case "RUN":
+			lintRun(ins.line, args, add)
+		}
+	}
+	if u := strings.ToLower(strings.SplitN(lastUser, ":", 2)[0]); u == "root" || u == "0" {
+		add(userLine, "warning", "DL3002", "the last USER is root; switch to an unprivileged user")
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+	return findings
+}
+
+// lintRun checks the command of a RUN instruction.
+func lintRun(line int, cmd string, add func(line int, level, code, format string, args ...any)) {
+	if runCd.MatchString(cmd) {
+		add(line, "warning", "DL3003", "use WORKDIR to switch directory instead of cd")
+	}
+	if runSudo.MatchString(cmd) {
+		add(line, "warning", "DL3004", "do not use sudo; RUN already runs as the current USER")
+	}
+	if aptUpgrade.MatchString(cmd) {
+		add(line, "warning", "DL3005", "do not use apt-get upgrade or dist-upgrade in images")
+	}
+	if aptInstall.MatchString(cmd) {
+		if !aptYes.MatchString(cmd) {
+			add(line, "warning", "DL3014", "use apt-get install -y so the build does not wait for input")
+		}
+		if !strings.Contains(cmd, "--no-install-recommends") {
+			add(line, "info", "DL3015", "add --no-install-recommends to avoid extra packages")
+		}
+		if !strings.Contains(cmd, "/var/lib/apt/lists") {
+			add(line, "info", "DL3009", "delete /var/lib/apt/lists after installing to keep the layer small")
+		}
+	}
+	if pipInstall.MatchString(cmd) && !strings.Contains(cmd, "--no-cache-dir") {
+		add(line, "info", "DL3042", "use pip install --no-cache-dir to keep the layer small")
+	}
+	if apkAdd.MatchString(cmd) && !strings.Contains(cmd, "--no-cache") {
+		add(line, "info", "DL3019", "use apk add --no-cache to keep the layer small")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// dockerTools lists the tools that talk to the Docker daemon and share the
+// Docker concurrency limit.
+var dockerTools = map[string]bool{
+	"pull_image":            true,
+	"docker_resolve_digest": true,
+	"docker_image_history":  true,
+	"docker_image_inspect":  true,
+	"docker_info":           true,
+	"docker_list_volumes":   true,
+	"docker_list_networks":  true,
+	"docker_image_save":     true,
+	"docker_image_load":     true,
+	"docker_logs_multi":     true,
+	"docker_exec":           true,
+	"compose_up":            true,
+	"compose_down":          true,
+}
+
+// dockerLimiter bounds how many Docker tool calls run at once. Excess calls
+// wait up to queueTimeout for a free slot and are rejected after that.
+type dockerLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// newDockerLimiterFromEnv builds the limiter from MCP_DOCKER_MAX_CONCURRENT
+// (default 2) and MCP_DOCKER_QUEUE_TIMEOUT (default 30s; 0 rejects at once).
+func newDockerLimiterFromEnv() *dockerLimiter {
+	max := envInt("MCP_DOCKER_MAX_CONCURRENT", 2)
+	if max < 1 {
+		log.Warnf("Ignoring MCP_DOCKER_MAX_CONCURRENT %d; using 1", max)
+		max = 1
+	}
+	return &dockerLimiter{
+		slots:        make(chan struct{}, max),
+		queueTimeout: envDuration("MCP_DOCKER_QUEUE_TIMEOUT", 30*time.Second),
+	}
+}
+
+// acquire takes a slot, waiting at most queueTimeout.
+func (l *dockerLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if l.queueTimeout <= 0 {
+		return toolErrorf(CodeResourceExhausted, "too many concurrent Docker operations (limit %d); try again later", cap(l.slots))
+	}
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return toolErrorf(CodeTimeout, "timed out after %s waiting for one of %d Docker operation slots; try again later", l.queueTimeout, cap(l.slots))
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *dockerLimiter) release() { <-l.slots }
+
+// middleware runs Docker tools under the concurrency limit.
+func (l *dockerLimiter) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !dockerTools[req.Params.Name] {
+			return next(ctx, req)
+		}
+		if err := l.acquire(ctx); err != nil {
+			requestLog(ctx).Warnf("Rejected tool '%s': %v", req.Params.Name, err)
+			return errorResult(err), nil
+		}
+		defer l.release()
+		return next(ctx, req)
+	}
+}
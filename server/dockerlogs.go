@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// maxLogTailers bounds how many container log streams are followed at once.
+const maxLogTailers = 4
+
+// logCollector gathers prefixed log lines from several containers, in the
+// order they arrive, up to a byte limit.
+type logCollector struct {
+	mu        sync.Mutex
+	buf       strings.Builder
+	maxBytes  int
+	truncated bool
+	full      context.CancelFunc
+	// live, if set, also receives every line as it arrives.
+	live io.Writer
+}
+
+func (c *logCollector) add(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.truncated {
+		return
+	}
+	if c.buf.Len()+len(line) > c.maxBytes {
+		c.truncated = true
+		c.full()
+		return
+	}
+	c.buf.WriteString(line)
+	if c.live != nil {
+		io.WriteString(c.live, line)
+	}
+}
+
+// prefixWriter splits a container's log stream into lines and hands each one
+// to the collector prefixed with the container name.
+type prefixWriter struct {
+	prefix  string
+	c       *logCollector
+	partial []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.c.add(w.prefix + string(w.partial[:i+1]))
+		w.partial = w.partial[i+1:]
+	}
+}
+
+func (w *prefixWriter) flush() {
+	if len(w.partial) > 0 {
+		w.c.add(w.prefix + string(w.partial) + "\n")
+		w.partial = nil
+	}
+}
+
+// tailContainerLogs follows the logs of the containers until ctx is done or
+// maxBytes of output has been collected. Each line is prefixed with its
+// container's name and also written to live, if not nil, as it arrives;
+// per-container failures are reported inline.
+func tailContainerLogs(ctx context.Context, cli *client.Client, names []string, tail int, maxBytes int, live io.Writer) (string, bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c := &logCollector{maxBytes: maxBytes, full: cancel, live: live}
+
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+
+	sem := make(chan struct{}, maxLogTailers)
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			w := &prefixWriter{prefix: fmt.Sprintf("%-*s | ", width, name), c: c}
+			if err := followLogs(ctx, cli, name, tail, w); err != nil {
+				c.add(w.prefix + "error: " + dockerError("container", err).Message + "\n")
+			}
+			w.flush()
+		}(name)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String(), c.truncated
+}
+
+// followLogs copies one container's stdout and stderr to w until ctx is done.
+func followLogs(ctx context.Context, cli *client.Client, name string, tail int, w io.Writer) error {
+	info, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return err
+	}
+	rc, err := cli.ContainerLogs(ctx, name, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Tail:       fmt.Sprint(tail),
+	})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// Containers without a TTY multiplex stdout and stderr in one stream.
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(w, rc)
+	} else {
+		_, err = stdcopy.StdCopy(w, w, rc)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// logsDuration reads the duration argument of docker_logs_multi in seconds.
+func logsDuration(args map[string]any) (time.Duration, error) {
+	secs, ok := args["duration"].(float64)
+	if !ok {
+		return 10 * time.Second, nil
+	}
+	if secs <= 0 || secs > 120 {
+		return 0, toolErrorf(CodeInvalidParam, "invalid duration parameter: must be between 1 and 120 seconds")
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
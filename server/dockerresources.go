@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// dockerFilters parses the filter argument of the list tools: a "key=value"
+// string or a list of them, as given to `docker ... ls --filter`. Repeating
+// a key matches any of its values.
+func dockerFilters(v any) (filters.Args, error) {
+	args := filters.NewArgs()
+	var items []any
+	switch v := v.(type) {
+	case nil:
+		return args, nil
+	case string:
+		items = []any{v}
+	case []any:
+		items = v
+	default:
+		return args, fmt.Errorf("invalid filter parameter: expected key=value or a list of them")
+	}
+	for _, it := range items {
+		s, _ := it.(string)
+		key, value, ok := strings.Cut(strings.TrimSpace(s), "=")
+		if !ok || key == "" {
+			return args, fmt.Errorf("invalid filter %q: expected key=value (e.g. driver=local)", s)
+		}
+		args.Add(key, value)
+	}
+	return args, nil
+}
+
+// volumeUsers maps volume names to the containers mounting them.
+func volumeUsers(containers []container.Summary) map[string][]string {
+	users := make(map[string][]string)
+	for _, c := range containers {
+		for _, m := range c.Mounts {
+			if m.Type == mount.TypeVolume && m.Name != "" {
+				users[m.Name] = append(users[m.Name], containerName(c))
+			}
+		}
+	}
+	return users
+}
+
+// networkUsers maps network names to the containers attached to them.
+func networkUsers(containers []container.Summary) map[string][]string {
+	users := make(map[string][]string)
+	for _, c := range containers {
+		if c.NetworkSettings == nil {
+			continue
+		}
+		for name := range c.NetworkSettings.Networks {
+			users[name] = append(users[name], containerName(c))
+		}
+	}
+	return users
+}
+
+// containerName returns a container's name without the leading slash, or
+// its short ID.
+func containerName(c container.Summary) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return c.ID[:min(len(c.ID), 12)]
+}
+
+// formatVolumes renders volumes as a table with the containers using each,
+// sorted by name.
+func formatVolumes(vols []*volume.Volume, users map[string][]string) string {
+	sort.Slice(vols, func(i, j int) bool { return vols[i].Name < vols[j].Name })
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tDRIVER\tSCOPE\tCREATED\tUSED BY")
+	for _, v := range vols {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", v.Name, v.Driver, orDash(v.Scope), orDash(v.CreatedAt), usedBy(users[v.Name]))
+	}
+	tw.Flush()
+	return b.String()
+}
+
+// formatNetworks renders networks as a table with their subnets and the
+// containers attached, sorted by name.
+func formatNetworks(nets []network.Summary, users map[string][]string) string {
+	sort.Slice(nets, func(i, j int) bool { return nets[i].Name < nets[j].Name })
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tID\tDRIVER\tSCOPE\tSUBNET\tINTERNAL\tUSED BY")
+	for _, n := range nets {
+		var subnets []string
+		for _, c := range n.IPAM.Config {
+			if c.Subnet != "" {
+				subnets = append(subnets, c.Subnet)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\t%s\n",
+			n.Name, n.ID[:min(len(n.ID), 12)], n.Driver, n.Scope,
+			orDash(strings.Join(subnets, ",")), n.Internal, usedBy(users[n.Name]))
+	}
+	tw.Flush()
+	return b.String()
+}
+
+// usedBy lists container names, or "-" for an unused resource.
+func usedBy(names []string) string {
+	sort.Strings(names)
+	return orDash(strings.Join(names, ","))
+}
+
+// orDash returns s, or "-" when it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
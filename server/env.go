@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// envInt returns the integer value of the named env var, or def when it is
+// unset or not a valid integer.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Warnf("Ignoring invalid %s %q; using %d", name, raw, def)
+		return def
+	}
+	return v
+}
+
+// envDuration returns the duration value of the named env var (e.g. "30s"),
+// or def when it is unset or not a valid duration.
+func envDuration(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Warnf("Ignoring invalid %s %q; using %s", name, raw, def)
+		return def
+	}
+	return v
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// checksumAlgorithms are the hashes the checksum tool supports.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+// fileChecksum streams the file at path through the named hash and returns
+// the hex digest and the number of bytes read.
+func fileChecksum(path, algorithm string) (string, int64, error) {
+	newHash, ok := checksumAlgorithms[algorithm]
+	if !ok {
+		return "", 0, toolErrorf(CodeInvalidParam, "unsupported algorithm %q: use sha256, sha1 or md5", algorithm)
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", 0, toolErrorf(CodeNotFound, "no such file: %s", path)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil && fi.IsDir() {
+		return "", 0, toolErrorf(CodeInvalidParam, "%s is a directory", path)
+	}
+	h := newHash()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// maxExtractBytes bounds the total size extract_archive writes, as a guard
+// against decompression bombs.
+const maxExtractBytes = 1 << 30
+
+// createArchive writes src, a file or directory, to a gzipped tarball at out.
+// Entries are named relative to src's parent, so the archive unpacks into a
+// directory named like src. It returns the archived entry names.
+func createArchive(src, out string) ([]string, error) {
+	if _, err := os.Lstat(src); errors.Is(err, os.ErrNotExist) {
+		return nil, toolErrorf(CodeNotFound, "no such file or directory: %s", src)
+	}
+	if src == out {
+		return nil, toolErrorf(CodeInvalidParam, "output must differ from source")
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	base := filepath.Dir(src)
+	var names []string
+	err = filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == out {
+			return nil
+		}
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		} else if !fi.Mode().IsRegular() && !fi.IsDir() {
+			return nil // sockets, devices and pipes are not archived
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			in, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, in)
+			in.Close()
+			if err != nil {
+				return err
+			}
+		}
+		names = append(names, hdr.Name)
+		return nil
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(out)
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	return names, nil
+}
+
+// extractTarget returns where an archive entry is written under dest,
+// rejecting names that would land outside it ("zip slip"), also by way of
+// symlinks extracted earlier: the longest existing prefix of the target is
+// resolved before it is compared with dest.
+func extractTarget(dest, name string) (string, error) {
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	if filepath.IsAbs(filepath.FromSlash(name)) || !within(dest, target) || !within(dest, resolveExisting(target)) {
+		return "", toolErrorf(CodePermissionDenied, "archive entry %q points outside the destination", name)
+	}
+	return target, nil
+}
+
+// linkInside reports whether a symlink at target pointing to linkname stays
+// inside dest. A ".." after another component is refused outright:
+// filepath.Join would cancel the two lexically, while the kernel follows the
+// component first, which may itself be a symlink.
+func linkInside(dest, target, linkname string) bool {
+	if filepath.IsAbs(linkname) {
+		return false
+	}
+	leading := true
+	for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+		switch {
+		case part == ".." && !leading:
+			return false
+		case part != ".." && part != "." && part != "":
+			leading = false
+		}
+	}
+	return within(dest, resolveExisting(filepath.Join(resolveExisting(filepath.Dir(target)), linkname)))
+}
+
+// extractArchive unpacks a gzipped tarball into dest and returns the names of
+// the extracted entries. Hard links and special files are skipped; symlinks
+// must stay inside dest.
+func extractArchive(archive, dest string) ([]string, error) {
+	f, err := os.Open(archive)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, toolErrorf(CodeNotFound, "no such archive: %s", archive)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, toolErrorf(CodeInvalidParam, "%s is not a gzip archive: %v", archive, err)
+	}
+	defer gz.Close()
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return nil, err
+	}
+	// Compare against the real path, as extractTarget resolves symlinks.
+	if real, err := filepath.EvalSymlinks(dest); err == nil {
+		dest = real
+	}
+
+	tr := tar.NewReader(gz)
+	var names []string
+	var written int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return names, toolErrorf(CodeInvalidParam, "corrupt archive: %v", err)
+		}
+		target, err := extractTarget(dest, hdr.Name)
+		if err != nil {
+			return names, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return names, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return names, err
+			}
+			// Replace rather than follow a symlink already at target; a
+			// dangling one could point anywhere once its target is created.
+			if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				os.Remove(target)
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0o777)
+			if err != nil {
+				return names, err
+			}
+			n, err := io.CopyN(out, tr, maxExtractBytes-written+1)
+			out.Close()
+			written += n
+			if written > maxExtractBytes {
+				return names, toolErrorf(CodeResourceExhausted, "archive expands to more than %d bytes", int64(maxExtractBytes))
+			}
+			if err != nil && err != io.EOF {
+				return names, err
+			}
+		case tar.TypeSymlink:
+			if !linkInside(dest, target, hdr.Linkname) {
+				return names, toolErrorf(CodePermissionDenied, "symlink %q points outside the destination", hdr.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return names, err
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return names, err
+			}
+		default:
+			continue
+		}
+		names = append(names, hdr.Name)
+	}
+	return names, nil
+}
+
+// renderTemplate executes a Go text/template with data. Referencing a key
+// missing from data is an error rather than "<no value>", so typos surface.
+func renderTemplate(name, text string, data map[string]any) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"default": func(def, v any) any {
+			if v == nil || v == "" {
+				return def
+			}
+			return v
+		},
+	}).Parse(text)
+	if err != nil {
+		return "", toolErrorf(CodeInvalidParam, "template parse error: %v", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", toolErrorf(CodeInvalidParam, "template execution error: %v", err)
+	}
+	return b.String(), nil
+}
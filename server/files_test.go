@@ -0,0 +1,119 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tarEntry is one member of a test archive; a non-empty link makes it a
+// symlink, a name ending in "/" a directory.
+type tarEntry struct {
+	name, link, body string
+}
+
+// writeTarGz writes entries as a gzipped tarball and returns its path.
+func writeTarGz(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		case e.name[len(e.name)-1] == '/':
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0o755, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractArchive(t *testing.T) {
+	archive := writeTarGz(t, []tarEntry{
+		{name: "app/"},
+		{name: "app/main.go", body: "package main\n"},
+		{name: "app/current", link: "main.go"},
+		{name: "app/lib/up", link: "../main.go"},
+	})
+	dest := filepath.Join(t.TempDir(), "out")
+	names, err := extractArchive(archive, dest)
+	if err != nil {
+		t.Fatalf("extractArchive: %v", err)
+	}
+	if len(names) != 4 {
+		t.Errorf("extracted %v, want 4 entries", names)
+	}
+	if b, err := os.ReadFile(filepath.Join(dest, "app", "current")); err != nil || string(b) != "package main\n" {
+		t.Errorf("reading through the extracted symlink = %q, %v", b, err)
+	}
+}
+
+func TestExtractArchiveStaysInDestination(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{"traversal name", []tarEntry{{name: "../escaped.txt", body: "x"}}},
+		{"nested traversal name", []tarEntry{{name: "a/../../escaped.txt", body: "x"}}},
+		{"absolute name", []tarEntry{{name: "/escaped.txt", body: "x"}}},
+		{"absolute symlink", []tarEntry{{name: "link", link: "/"}, {name: "link/escaped.txt", body: "x"}}},
+		{"symlink out", []tarEntry{{name: "link", link: "../.."}, {name: "link/escaped.txt", body: "x"}}},
+		{"chained symlinks", []tarEntry{
+			{name: "z/"},
+			{name: "x/"},
+			{name: "x/y/"},
+			{name: "x/y/q", link: "../../z"},
+			{name: "x/y/p", link: "q/../../.."},
+			{name: "x/y/p/escaped/file.txt", body: "x"},
+		}},
+		{"dotdot after a later symlink", []tarEntry{
+			{name: "d1/"},
+			{name: "a", link: "d1/c/../.."},
+			{name: "d1/c", link: ".."},
+			{name: "a/escaped.txt", body: "x"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// dest sits two levels down so escapes land in the test's own
+			// temporary directory.
+			root := t.TempDir()
+			dest := filepath.Join(root, "a", "b")
+			_, err := extractArchive(writeTarGz(t, tt.entries), dest)
+			var escaped []string
+			filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() && d.Type()&os.ModeSymlink == 0 && !within(dest, path) {
+					escaped = append(escaped, path)
+				}
+				return nil
+			})
+			if len(escaped) > 0 {
+				t.Fatalf("files written outside the destination: %v", escaped)
+			}
+			if err == nil {
+				t.Error("extractArchive succeeded, want the escaping entry rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isLocalChart reports whether chart names a chart directory or archive on
+// disk rather than a repository reference such as "bitnami/nginx" or an
+// oci:// URL.
+func isLocalChart(chart string) bool {
+	if strings.Contains(chart, "://") {
+		return false
+	}
+	if strings.HasPrefix(chart, ".") || strings.HasPrefix(chart, "/") || strings.HasSuffix(chart, ".tgz") {
+		return true
+	}
+	_, err := os.Stat(chart)
+	return err == nil
+}
+
+// helmValuesFile returns a values file for helm's -f flag. values is either
+// the path of a YAML file in the workspace or inline YAML, which is written
+// to the call's temp dir.
+func helmValuesFile(ctx context.Context, values string) (string, error) {
+	if !strings.Contains(values, "\n") && (strings.HasSuffix(values, ".yaml") || strings.HasSuffix(values, ".yml")) {
+		path, err := workspacePath(values)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+
+	dir, err := requestTempDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "values.yaml")
+	if err := os.WriteFile(path, []byte(values), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
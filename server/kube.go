@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// withKubeconfigArg declares the optional kubeconfig argument of the
+// Kubernetes tools.
+func withKubeconfigArg() mcp.ToolOption {
+	return mcp.WithString("kubeconfig",
+		mcp.Description("Path to the kubeconfig file to use (defaults to KUBECONFIG, then ~/.kube/config)"),
+	)
+}
+
+// withContextArg declares the optional kubeconfig context argument of the
+// Kubernetes tools.
+func withContextArg() mcp.ToolOption {
+	return mcp.WithString("context",
+		mcp.Description("Name of the kubeconfig context to use (defaults to the current context)"),
+	)
+}
+
+// kubectlFlags turns the kubeconfig and context arguments of a call into
+// kubectl global flags, checking that the kubeconfig file exists.
+func kubectlFlags(args map[string]any) ([]string, error) {
+	var flags []string
+	if path, _ := args["kubeconfig"].(string); path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("kubeconfig %q is not accessible: %w", path, err)
+		}
+		if info.IsDir() {
+			return nil, toolErrorf(CodeInvalidParam, "kubeconfig %q is a directory", path)
+		}
+		flags = append(flags, "--kubeconfig", path)
+	}
+	if kctx, _ := args["context"].(string); kctx != "" {
+		flags = append(flags, "--context", kctx)
+	}
+	return flags, nil
+}
+
+// podEvent is the part of a `kubectl get pods --watch --output-watch-events
+// -o json` event needed to follow a pod's state.
+type podEvent struct {
+	Type   string `json:"type"`
+	Object struct {
+		Metadata struct {
+			Name              string  `json:"name"`
+			DeletionTimestamp *string `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Status struct {
+			Phase             string `json:"phase"`
+			ContainerStatuses []struct {
+				Ready bool `json:"ready"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"object"`
+}
+
+// state summarizes the pod as a single comparable string, e.g. "Pending",
+// "Running (1/2 ready)" or "Terminating".
+func (e podEvent) state() string {
+	if e.Type == "DELETED" {
+		return "Deleted"
+	}
+	if e.Object.Metadata.DeletionTimestamp != nil {
+		return "Terminating"
+	}
+	phase := e.Object.Status.Phase
+	if phase != "Running" {
+		return phase
+	}
+	ready := 0
+	for _, cs := range e.Object.Status.ContainerStatuses {
+		if cs.Ready {
+			ready++
+		}
+	}
+	if total := len(e.Object.Status.ContainerStatuses); ready < total {
+		return fmt.Sprintf("Running (%d/%d ready)", ready, total)
+	}
+	return phase
+}
+
+// podTransition is one observed change of a pod's state.
+type podTransition struct {
+	Time time.Time `json:"time"`
+	Pod  string    `json:"pod"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+}
+
+func (t podTransition) String() string {
+	return fmt.Sprintf("%s  %s: %s -> %s", t.Time.Format("15:04:05"), t.Pod, t.From, t.To)
+}
+
+// watchPods runs kubectl in watch mode until ctx is done and reports every
+// pod state change to notify. The first state seen for a pod is taken as its
+// baseline rather than a transition. The kubectl process is killed when ctx
+// is cancelled or times out.
+func watchPods(ctx context.Context, kubeArgs, env []string, notify func(podTransition)) ([]podTransition, error) {
+	args := append(kubeArgs, "get", "pods", "--watch", "--output-watch-events", "-o", "json")
+	cmd := toolCommand(ctx, env, "kubectl", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start kubectl: %v", err)
+	}
+
+	var transitions []podTransition
+	states := make(map[string]string)
+	dec := json.NewDecoder(stdout)
+	for {
+		var ev podEvent
+		if err := dec.Decode(&ev); err != nil {
+			break
+		}
+		name, state := ev.Object.Metadata.Name, ev.state()
+		prev, seen := states[name]
+		states[name] = state
+		if !seen || prev == state {
+			continue
+		}
+		t := podTransition{Time: time.Now(), Pod: name, From: prev, To: state}
+		transitions = append(transitions, t)
+		notify(t)
+	}
+
+	err = cmd.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		// The watch ran for its full duration; kubectl being killed is expected.
+		return transitions, nil
+	}
+	if ctx.Err() != nil {
+		return transitions, ctx.Err()
+	}
+	if err != nil {
+		return transitions, fmt.Errorf("kubectl watch failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return transitions, nil
+}
+
+// clusterUnreachable matches kubectl errors meaning the API server could not
+// be reached at all.
+var clusterUnreachable = regexp.MustCompile(`(?i)unable to connect to the server|connection refused|i/o timeout|no such host`)
+
+// kubectlError reports a failed kubectl command, coding an unreachable
+// cluster as UPSTREAM_UNAVAILABLE.
+func kubectlError(msg string, err error, output string) *mcp.CallToolResult {
+	if clusterUnreachable.MatchString(output) {
+		te := toolErrorf(CodeUpstreamUnavailable, "%s: the Kubernetes API server is not reachable (check kubeconfig and context)", msg)
+		te.Details = map[string]any{"output": strings.TrimSpace(output)}
+		return te.Result()
+	}
+	return commandError(msg, err, output)
+}
+
+// metricsUnavailable matches kubectl top errors meaning the cluster serves no
+// resource metrics, i.e. metrics-server is not installed or not ready.
+var metricsUnavailable = regexp.MustCompile(`(?i)metrics api not available|the server could not find the requested resource \(get pods\.metrics\.k8s\.io\)|metrics not available yet`)
+
+// kubeEvent is the part of a Kubernetes Event shown by k8s_events.
+type kubeEvent struct {
+	Metadata struct {
+		Namespace         string    `json:"namespace"`
+		CreationTimestamp time.Time `json:"creationTimestamp"`
+	} `json:"metadata"`
+	Type           string `json:"type"`
+	Reason         string `json:"reason"`
+	Message        string `json:"message"`
+	Count          int    `json:"count"`
+	InvolvedObject struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	} `json:"involvedObject"`
+	LastTimestamp  *time.Time `json:"lastTimestamp"`
+	EventTime      *time.Time `json:"eventTime"`
+	FirstTimestamp *time.Time `json:"firstTimestamp"`
+}
+
+// seen is when the event last occurred; events from newer reporters only set
+// eventTime.
+func (e kubeEvent) seen() time.Time {
+	for _, t := range []*time.Time{e.LastTimestamp, e.EventTime, e.FirstTimestamp} {
+		if t != nil && !t.IsZero() {
+			return *t
+		}
+	}
+	return e.Metadata.CreationTimestamp
+}
+
+// eventsArgs builds the kubectl arguments listing events, cluster-wide unless
+// namespace is set. eventType and the involved object's kind and name become
+// field selectors.
+func eventsArgs(namespace, eventType, kind, name string) []string {
+	args := []string{"get", "events", "-o", "json"}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	} else {
+		args = append(args, "--all-namespaces")
+	}
+	var selectors []string
+	if eventType != "" {
+		selectors = append(selectors, "type="+eventType)
+	}
+	if kind != "" {
+		selectors = append(selectors, "involvedObject.kind="+kind)
+	}
+	if name != "" {
+		selectors = append(selectors, "involvedObject.name="+name)
+	}
+	if len(selectors) > 0 {
+		args = append(args, "--field-selector", strings.Join(selectors, ","))
+	}
+	return args
+}
+
+// formatEvents renders the limit most recent events, newest first, as a
+// table.
+func formatEvents(out []byte, limit int, now time.Time) (string, error) {
+	var list struct {
+		Items []kubeEvent `json:"items"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return "", fmt.Errorf("unexpected kubectl output: %v", err)
+	}
+	if len(list.Items) == 0 {
+		return "No events found.", nil
+	}
+	events := list.Items
+	sort.SliceStable(events, func(i, j int) bool { return events[i].seen().After(events[j].seen()) })
+	shown := events[:min(limit, len(events))]
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAST SEEN\tNAMESPACE\tTYPE\tREASON\tOBJECT\tCOUNT\tMESSAGE")
+	for _, e := range shown {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s/%s\t%d\t%s\n",
+			units.HumanDuration(now.Sub(e.seen()))+" ago",
+			e.Metadata.Namespace, e.Type, e.Reason,
+			strings.ToLower(e.InvolvedObject.Kind), e.InvolvedObject.Name,
+			max(e.Count, 1), strings.Join(strings.Fields(e.Message), " "),
+		)
+	}
+	tw.Flush()
+	if len(events) > len(shown) {
+		fmt.Fprintf(&b, "... %d older events not shown\n", len(events)-len(shown))
+	}
+	return b.String(), nil
+}
+
+// kubectlVerbs are the verbs the kubectl tool knows, mapped to whether they
+// change the cluster. rollout counts as mutating since restart and undo do.
+// Interactive verbs such as edit, exec, attach and port-forward are left out:
+// the tool has no terminal to give them.
+var kubectlVerbs = map[string]bool{
+	"api-resources": false,
+	"api-versions":  false,
+	"auth":          false,
+	"cluster-info":  false,
+	"describe":      false,
+	"diff":          false,
+	"events":        false,
+	"explain":       false,
+	"get":           false,
+	"logs":          false,
+	"top":           false,
+	"version":       false,
+	"annotate":      true,
+	"apply":         true,
+	"autoscale":     true,
+	"cordon":        true,
+	"create":        true,
+	"delete":        true,
+	"drain":         true,
+	"expose":        true,
+	"label":         true,
+	"patch":         true,
+	"replace":       true,
+	"rollout":       true,
+	"scale":         true,
+	"set":           true,
+	"taint":         true,
+	"uncordon":      true,
+}
+
+// allowedKubectlVerbs returns the verbs the kubectl tool may run, from the
+// comma-separated MCP_KUBECTL_VERBS. By default only the verbs that do not
+// change the cluster are allowed.
+func allowedKubectlVerbs() map[string]bool {
+	allowed := make(map[string]bool)
+	list := os.Getenv("MCP_KUBECTL_VERBS")
+	if strings.TrimSpace(list) == "" {
+		for verb, mutating := range kubectlVerbs {
+			if !mutating {
+				allowed[verb] = true
+			}
+		}
+		return allowed
+	}
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			allowed[v] = true
+		}
+	}
+	return allowed
+}
+
+// kubectlReservedFlags pick the cluster or credentials; they come from the
+// kubeconfig and context arguments, never from free-form args.
+var kubectlReservedFlags = []string{
+	"--kubeconfig", "--context", "--cluster", "--user", "--server", "-s",
+	"--token", "--username", "--password", "--as", "--as-group", "--as-uid",
+	"--certificate-authority", "--client-certificate", "--client-key",
+	"--insecure-skip-tls-verify",
+}
+
+// kubectlCommand checks verb and args for the kubectl tool and returns the
+// kubectl arguments to run, after the global flags.
+func kubectlCommand(verb string, args []string) ([]string, error) {
+	mutating, known := kubectlVerbs[verb]
+	if !known {
+		return nil, toolErrorf(CodeInvalidParam, "unsupported kubectl verb %q", verb)
+	}
+	if !allowedKubectlVerbs()[verb] {
+		if mutating {
+			return nil, toolErrorf(CodePermissionDenied, "kubectl %s changes the cluster and is not allowed (add it to MCP_KUBECTL_VERBS)", verb)
+		}
+		return nil, toolErrorf(CodePermissionDenied, "kubectl %s is not allowed (see MCP_KUBECTL_VERBS)", verb)
+	}
+	for _, a := range args {
+		flag, _, _ := strings.Cut(a, "=")
+		if slices.Contains(kubectlReservedFlags, flag) {
+			return nil, toolErrorf(CodeInvalidParam, "%s cannot be passed in args; use the kubeconfig and context arguments", flag)
+		}
+		// Following output would run until the timeout; k8s_watch_pods and
+		// k8s_events cover those uses.
+		if (verb == "logs" && (flag == "-f" || flag == "--follow")) ||
+			(verb == "get" && (flag == "-w" || flag == "--watch" || flag == "--watch-only")) {
+			return nil, toolErrorf(CodeInvalidParam, "%s is not supported; output must end for the tool to return", flag)
+		}
+	}
+	return append([]string{verb}, args...), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// kustomizationFiles are the file names kustomize recognizes in a directory.
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// hasKustomization reports whether dir contains a kustomization file.
+func hasKustomization(dir string) bool {
+	for _, name := range kustomizationFiles {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomizeCommand builds `kustomize build dir`, falling back to
+// `kubectl kustomize dir` when the standalone binary is not installed.
+func kustomizeCommand(ctx context.Context, dir string, env []string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath("kustomize"); err == nil {
+		return toolCommand(ctx, env, "kustomize", "build", dir), nil
+	}
+	if err := requireBinary("kubectl"); err != nil {
+		return nil, toolErrorf(CodeUpstreamUnavailable, "neither kustomize nor kubectl is installed or on PATH")
+	}
+	return toolCommand(ctx, env, "kubectl", "kustomize", dir), nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// setupLogging configures logrus output. Logs go to stdout so containers
+// keep working, or to stderr when stdout carries the stdio transport; when
+// MCP_LOG_FILE is set they are additionally written to a size/age rotated
+// file.
+//
+//	MCP_LOG_FILE         path of the log file (file logging disabled if empty)
+//	MCP_LOG_MAX_SIZE_MB  size in megabytes before rotation (default 100)
+//	MCP_LOG_MAX_BACKUPS  rotated files to keep (default 5)
+//	MCP_LOG_MAX_AGE_DAYS days to keep rotated files, 0 keeps forever (default 28)
+func setupLogging(stdio bool) {
+	var console io.Writer = os.Stdout
+	if stdio {
+		console = os.Stderr
+	}
+	log.SetLevel(log.TraceLevel)
+	log.SetOutput(console)
+
+	path := os.Getenv("MCP_LOG_FILE")
+	if path == "" {
+		return
+	}
+	rotator := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    envInt("MCP_LOG_MAX_SIZE_MB", 100),
+		MaxBackups: envInt("MCP_LOG_MAX_BACKUPS", 5),
+		MaxAge:     envInt("MCP_LOG_MAX_AGE_DAYS", 28),
+		Compress:   true,
+	}
+	log.SetOutput(io.MultiWriter(console, rotator))
+	log.Infof("Logging to %s (max %dMB, %d backups)", path, rotator.MaxSize, rotator.MaxBackups)
+}
+
+// requestLogKey is the context key of a request's log entry.
+type requestLogKey struct{}
+
+// withRequestLog attaches a log entry for one MCP message to ctx. Every line
+// logged through it carries a fresh correlation_id, plus the message's
+// JSON-RPC id as rpc_id when it has one, so all lines of a busy server that
+// belong to one call can be found with a single grep.
+func withRequestLog(ctx context.Context, msg []byte) context.Context {
+	fields := log.Fields{"correlation_id": uuid.New().String()}
+	var envelope struct {
+		ID any `json:"id"`
+	}
+	if len(msg) > 0 && json.Unmarshal(msg, &envelope) == nil && envelope.ID != nil {
+		fields["rpc_id"] = envelope.ID
+	}
+	return context.WithValue(ctx, requestLogKey{}, log.WithFields(fields))
+}
+
+// requestLog returns the log entry of the request ctx belongs to, or a plain
+// entry outside of one.
+func requestLog(ctx context.Context) *log.Entry {
+	if entry, ok := ctx.Value(requestLogKey{}).(*log.Entry); ok {
+		return entry
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// maxPeekBytes bounds how much of a request body httpContext reads to find
+// the JSON-RPC id.
+const maxPeekBytes = 64 << 10
+
+// httpContext prepares the context of a message posted over HTTP: it records
+// the remote address and starts the request's log entry. The body is put
+// back for the SSE server to decode.
+func httpContext(ctx context.Context, r *http.Request) context.Context {
+	ctx = withRemoteAddr(ctx, r)
+	if r.Method != http.MethodPost || r.Body == nil {
+		return ctx
+	}
+	head, _ := io.ReadAll(io.LimitReader(r.Body, maxPeekBytes))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	return withRequestLog(ctx, head)
+}
+
+// requestLogMiddleware gives every tool call a log entry, tagged with the
+// tool name, for handlers and later middleware to log through.
+func requestLogMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if _, ok := ctx.Value(requestLogKey{}).(*log.Entry); !ok {
+			ctx = withRequestLog(ctx, nil)
+		}
+		entry := requestLog(ctx).WithField("tool", req.Params.Name)
+		return next(context.WithValue(ctx, requestLogKey{}, entry), req)
+	}
+}
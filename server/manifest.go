@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestKinds are the kind hints of validate_manifest: kubernetes also
+// checks the fields every object needs, yaml and json check syntax only.
+var manifestKinds = []string{"kubernetes", "yaml", "json"}
+
+// manifestProblem is one error found by validate_manifest. Document counts
+// the non-empty documents of a YAML stream from 1.
+type manifestProblem struct {
+	Document int    `json:"document"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+// manifestReport is the result of validate_manifest. Objects names the
+// Kubernetes objects found, as "apiVersion kind name".
+type manifestReport struct {
+	Valid     bool              `json:"valid"`
+	Format    string            `json:"format"`
+	Documents int               `json:"documents"`
+	Objects   []string          `json:"objects,omitempty"`
+	Problems  []manifestProblem `json:"problems"`
+}
+
+// yamlErrorLine matches the position yaml.v3 puts in syntax errors.
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// manifestFormat picks the syntax to check: json for the json hint, .json
+// files and content starting with '{' or '['; yaml otherwise.
+func manifestFormat(kind, path string, data []byte) string {
+	switch {
+	case kind == "json":
+		return "json"
+	case kind == "yaml":
+		return "yaml"
+	case path != "":
+		if formatFromExt(path) == "json" {
+			return "json"
+		}
+		return "yaml"
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return "json"
+	}
+	return "yaml"
+}
+
+// validateManifest checks data in format and, for the kubernetes kind, the
+// apiVersion, kind and metadata.name of every object, including the items
+// of List kinds. YAML streams are checked document by document up to the
+// first syntax error, after which the parser cannot continue.
+func validateManifest(data []byte, format, kind string) *manifestReport {
+	rep := &manifestReport{Format: format, Problems: []manifestProblem{}}
+	if format == "json" {
+		if p := checkJSONSyntax(data); p != nil {
+			rep.Problems = append(rep.Problems, *p)
+			return rep
+		}
+	}
+
+	// JSON is read as YAML too, for the node positions.
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			rep.Problems = append(rep.Problems, yamlProblem(rep.Documents+1, err))
+			break
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+			continue
+		}
+		rep.Documents++
+		if kind == "kubernetes" {
+			rep.checkObject(rep.Documents, doc.Content[0], "")
+		}
+	}
+	rep.Valid = len(rep.Problems) == 0
+	return rep
+}
+
+// checkJSONSyntax reports the first JSON syntax error in data, if any.
+func checkJSONSyntax(data []byte) *manifestProblem {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var v any
+	err := dec.Decode(&v)
+	if err == nil {
+		if _, err := dec.Token(); err != io.EOF {
+			line, col := lineCol(data, dec.InputOffset())
+			return &manifestProblem{Document: 1, Line: line, Column: col, Message: "unexpected data after the top-level value"}
+		}
+		return nil
+	}
+	offset, err := jsonErrorOffset(data, err)
+	p := &manifestProblem{Document: 1, Message: strings.TrimPrefix(err.Error(), "json: ")}
+	if offset >= 0 {
+		p.Line, p.Column = lineCol(data, offset)
+	}
+	return p
+}
+
+// yamlProblem turns a yaml.v3 syntax error into a problem of document doc.
+func yamlProblem(doc int, err error) manifestProblem {
+	msg := err.Error()
+	p := manifestProblem{Document: doc}
+	if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+		p.Line, _ = strconv.Atoi(m[1])
+		msg = msg[len(m[0]):]
+	}
+	p.Message = strings.TrimPrefix(msg, "yaml: ")
+	return p
+}
+
+// checkObject checks one Kubernetes object; prefix is its path inside the
+// document, e.g. "items[2].".
+func (rep *manifestReport) checkObject(doc int, n *yaml.Node, prefix string) {
+	if n.Kind != yaml.MappingNode {
+		rep.problem(doc, n, strings.TrimSuffix(prefix, "."), "expected a Kubernetes object (a mapping), got %s", nodeKindName(n))
+		return
+	}
+	apiVersion := rep.requireString(doc, n, prefix, "apiVersion")
+	kind := rep.requireString(doc, n, prefix, "kind")
+
+	if items := mappingValue(n, "items"); strings.HasSuffix(kind, "List") && items != nil {
+		if items.Kind != yaml.SequenceNode {
+			rep.problem(doc, items, prefix+"items", "items must be a list, got %s", nodeKindName(items))
+			return
+		}
+		for i, item := range items.Content {
+			rep.checkObject(doc, item, fmt.Sprintf("%sitems[%d].", prefix, i))
+		}
+		return
+	}
+
+	meta := mappingValue(n, "metadata")
+	if meta == nil {
+		rep.problem(doc, n, prefix+"metadata", "missing metadata")
+		return
+	}
+	if meta.Kind != yaml.MappingNode {
+		rep.problem(doc, meta, prefix+"metadata", "metadata must be a mapping, got %s", nodeKindName(meta))
+		return
+	}
+	var name string
+	if generated := mappingValue(meta, "generateName"); generated != nil && generated.Value != "" && mappingValue(meta, "name") == nil {
+		name = generated.Value + "*"
+	} else {
+		name = rep.requireString(doc, meta, prefix+"metadata.", "name")
+	}
+	if apiVersion != "" && kind != "" && name != "" {
+		rep.Objects = append(rep.Objects, strings.Join([]string{apiVersion, kind, name}, " "))
+	}
+}
+
+// requireString returns the non-empty string value of key in mapping n,
+// recording a problem when it is missing, empty or not a string.
+func (rep *manifestReport) requireString(doc int, n *yaml.Node, prefix, key string) string {
+	v := mappingValue(n, key)
+	switch {
+	case v == nil:
+		rep.problem(doc, n, prefix+key, "missing %s", key)
+	case v.Kind != yaml.ScalarNode || v.Tag != "!!str":
+		rep.problem(doc, v, prefix+key, "%s must be a string, got %s", key, nodeKindName(v))
+	case strings.TrimSpace(v.Value) == "":
+		rep.problem(doc, v, prefix+key, "%s is empty", key)
+	default:
+		return v.Value
+	}
+	return ""
+}
+
+// problem records a problem at the position of n.
+func (rep *manifestReport) problem(doc int, n *yaml.Node, field, format string, args ...any) {
+	rep.Problems = append(rep.Problems, manifestProblem{
+		Document: doc,
+		Line:     n.Line,
+		Column:   n.Column,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// mappingValue returns the value of key in mapping n, or nil.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// nodeKindName describes a node for error messages.
+func nodeKindName(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.AliasNode:
+		return "an alias"
+	}
+	switch n.Tag {
+	case "!!null":
+		return "null"
+	case "!!str":
+		return "a string"
+	case "!!int", "!!float":
+		return "a number"
+	case "!!bool":
+		return "a boolean"
+	}
+	return "a " + strings.TrimPrefix(n.Tag, "!!")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+)
+
+type remoteAddrKey struct{}
+
+// clientNames maps MCP session IDs to the client name sent in Initialize.
+var clientNames sync.Map
+
+// withRemoteAddr stores the caller's host in the request context so tool
+// calls can be attributed when the client never identified itself. The port
+// is dropped: it changes with every connection and would give each one its
+// own metric series.
+func withRemoteAddr(ctx context.Context, r *http.Request) context.Context {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return context.WithValue(ctx, remoteAddrKey{}, host)
+}
+
+// rememberClient records the client name of an initialized session.
+func rememberClient(ctx context.Context, id any, req *mcp.InitializeRequest, res *mcp.InitializeResult) {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil || req.Params.ClientInfo.Name == "" {
+		return
+	}
+	clientNames.Store(session.SessionID(), req.Params.ClientInfo.Name)
+}
+
+// forgetClient drops the client name of a closed session.
+func forgetClient(ctx context.Context, session server.ClientSession) {
+	clientNames.Delete(session.SessionID())
+}
+
+// clientIdentity names the client behind a request: the name it sent in
+// Initialize, else its remote host, else "unknown".
+func clientIdentity(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		if name, ok := clientNames.Load(session.SessionID()); ok {
+			return name.(string)
+		}
+	}
+	if addr, ok := ctx.Value(remoteAddrKey{}).(string); ok && addr != "" {
+		return addr
+	}
+	return "unknown"
+}
+
+type metricKey struct {
+	tool   string
+	client string
+}
+
+// toolMetrics counts tool invocations and failures per tool and client.
+type toolMetrics struct {
+	mu      sync.Mutex
+	calls   map[metricKey]uint64
+	errors  map[metricKey]uint64
+	seconds map[metricKey]float64
+	// gauges are the optional components (tool queue, circuit breakers)
+	// whose state is also served at /metrics.
+	gauges []metricsWriter
+}
+
+// metricsWriter writes gauges in the Prometheus text format.
+type metricsWriter interface {
+	writeMetrics(w io.Writer)
+}
+
+func newToolMetrics() *toolMetrics {
+	return &toolMetrics{
+		calls:   make(map[metricKey]uint64),
+		errors:  make(map[metricKey]uint64),
+		seconds: make(map[metricKey]float64),
+	}
+}
+
+// middleware counts every tool call and writes an audit log entry for it.
+func (m *toolMetrics) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		key := metricKey{tool: req.Params.Name, client: clientIdentity(ctx)}
+		start := time.Now()
+		res, err := next(ctx, req)
+		elapsed := time.Since(start)
+		failed := err != nil || (res != nil && res.IsError)
+
+		m.mu.Lock()
+		m.calls[key]++
+		m.seconds[key] += elapsed.Seconds()
+		if failed {
+			m.errors[key]++
+		}
+		m.mu.Unlock()
+
+		requestLog(ctx).WithFields(log.Fields{
+			"audit":    true,
+			"tool":     key.tool,
+			"client":   key.client,
+			"duration": elapsed.String(),
+			"failed":   failed,
+		}).Info("tool call")
+		return res, err
+	}
+}
+
+// ServeHTTP exposes the counters in the Prometheus text format.
+func (m *toolMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeCounter(w, "mcp_tool_calls_total", "Tool invocations by tool and client.", m.calls)
+	writeCounter(w, "mcp_tool_errors_total", "Failed tool invocations by tool and client.", m.errors)
+	fmt.Fprintln(w, "# HELP mcp_tool_duration_seconds_total Time spent in tool handlers by tool and client.")
+	fmt.Fprintln(w, "# TYPE mcp_tool_duration_seconds_total counter")
+	for _, k := range sortedKeys(m.seconds) {
+		fmt.Fprintf(w, "mcp_tool_duration_seconds_total{tool=%q,client=%q} %g\n", k.tool, k.client, m.seconds[k])
+	}
+	for _, g := range m.gauges {
+		g.writeMetrics(w)
+	}
+}
+
+func writeCounter(w http.ResponseWriter, name, help string, values map[metricKey]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, k := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{tool=%q,client=%q} %d\n", name, k.tool, k.client, values[k])
+	}
+}
+
+func sortedKeys[V any](m map[metricKey]V) []metricKey {
+	keys := make([]metricKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tool != keys[j].tool {
+			return keys[i].tool < keys[j].tool
+		}
+		return keys[i].client < keys[j].client
+	})
+	return keys
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIdentityDropsPort(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"10.0.0.7:51234", "10.0.0.7"},
+		{"[::1]:40000", "::1"},
+		{"@", "@"},
+		{"", "unknown"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/message", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if got := clientIdentity(withRemoteAddr(context.Background(), r)); got != tt.want {
+			t.Errorf("clientIdentity(%q) = %q, want %q", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
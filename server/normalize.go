@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// argNormalizers clean up specific arguments of each tool, after whitespace
+// has been trimmed. cleanPath is only for arguments that are filesystem
+// paths: applied to URLs, references or inline text it would mangle them.
+var argNormalizers = map[string]map[string]func(string) string{
+	"to-markdown":             {"input": cleanPath, "output": cleanPath},
+	"ast-grep":                {"language": strings.ToLower, "path": cleanPath},
+	"ast-grep-diff":           {"language": strings.ToLower, "directory": cleanPath},
+	"mirrord-exec":            {"config": cleanPath},
+	"docker_build_cache_info": {"output_file": cleanPath},
+	"docker_image_save":       {"output_path": cleanPath},
+	"docker_image_load":       {"input_path": cleanPath},
+	"dockerfile_lint":         {"path": cleanPath},
+	"compose_up":              {"project_dir": cleanPath, "file": cleanPath},
+	"compose_down":            {"project_dir": cleanPath, "file": cleanPath},
+	"get_pods":                {"kubeconfig": cleanPath},
+	"k8s_events":              {"kubeconfig": cleanPath},
+	"k8s_top_pods":            {"kubeconfig": cleanPath},
+	"k8s_watch_pods":          {"kubeconfig": cleanPath},
+	"k8s_diff":                {"kubeconfig": cleanPath, "path": cleanPath},
+	"kubectl":                 {"kubeconfig": cleanPath},
+	"k8s_ping":                {"kubeconfig": cleanPath},
+	"kustomize_build":         {"path": cleanPath},
+	"checksum":                {"path": cleanPath},
+	"watch_file":              {"path": cleanPath},
+	"create_archive":          {"source": cleanPath, "output": cleanPath},
+	"extract_archive":         {"archive": cleanPath, "destination": cleanPath},
+	"render_template":         {"template_file": cleanPath, "output": cleanPath},
+	"convert_format":          {"input_file": cleanPath, "output": cleanPath},
+	"validate_manifest":       {"path": cleanPath},
+	"git_init":                {"directory": cleanPath},
+	"read-query":              {"db": cleanPath},
+	"write-query":             {"db": cleanPath},
+	"bulk_insert":             {"db": cleanPath},
+	"run_sql_file":            {"path": cleanPath, "db": cleanPath},
+	"begin_transaction":       {"db": cleanPath},
+	"create-SQLtable":         {"db": cleanPath},
+	"list-tables":             {"db": cleanPath},
+	"sqlite_schema":           {"db": cleanPath},
+	"sqlite_ping":             {"db": cleanPath},
+	"validate_sql":            {"dialect": strings.ToLower, "db": cleanPath},
+	"format_sql":              {"dialect": strings.ToLower},
+	"create_index":            {"dialect": strings.ToLower, "db": cleanPath},
+}
+
+// contentArgs lists, per tool, the arguments that carry inline content:
+// documents, manifests, templates, build logs, stdin and SQL. Indentation
+// and trailing newlines matter there, so they are passed through untouched.
+var contentArgs = map[string]map[string]bool{
+	"ast-grep":                {"pattern": true, "new-pattern": true},
+	"ast-grep-diff":           {"pattern": true, "new-pattern": true},
+	"docker_build_cache_info": {"build_output": true},
+	"docker_image_inspect":    {"format": true},
+	"dockerfile_lint":         {"content": true},
+	"helm_template":           {"values": true},
+	"k8s_diff":                {"manifest": true},
+	"kubectl":                 {"stdin": true},
+	"pipeline":                {"stdin": true},
+	"render_template":         {"template": true},
+	"convert_format":          {"content": true},
+	"validate_manifest":       {"content": true},
+	"read-query":              {"query": true},
+	"write-query":             {"query": true},
+	"create-SQLtable":         {"definition": true},
+	"validate_sql":            {"query": true},
+	"format_sql":              {"query": true},
+}
+
+// argAliases maps, per tool, argument names callers commonly guess to the
+// canonical name the tool declares.
+var argAliases = map[string]map[string]string{
+	"to-markdown":             {"file": "input", "path": "input", "input_file": "input", "output_file": "output"},
+	"ast-grep":                {"new_pattern": "new-pattern", "replacement": "new-pattern", "rewrite": "new-pattern", "lang": "language", "dir": "path", "directory": "path", "file": "path"},
+	"ast-grep-undo":           {"operation": "operation_id", "op_id": "operation_id", "id": "operation_id"},
+	"ast-grep-diff":           {"new_pattern": "new-pattern", "replacement": "new-pattern", "rewrite": "new-pattern", "lang": "language", "dir": "directory", "path": "directory"},
+	"mirrord-exec":            {"config_file": "config", "file": "config"},
+	"pull_image":              {"img": "image", "image_name": "image"},
+	"docker_resolve_digest":   {"img": "image", "image_name": "image", "arch": "platform", "local_only": "local"},
+	"docker_image_history":    {"img": "image", "image_name": "image"},
+	"docker_build_cache_info": {"output": "build_output", "log": "build_output", "file": "output_file", "path": "output_file"},
+	"docker_image_inspect":    {"img": "image", "image_name": "image"},
+	"docker_image_save":       {"img": "image", "image_name": "image", "output": "output_path", "path": "output_path"},
+	"docker_image_load":       {"input": "input_path", "path": "input_path"},
+	"docker_list_volumes":     {"filters": "filter"},
+	"docker_list_networks":    {"filters": "filter"},
+	"docker_logs_multi":       {"names": "containers", "container_names": "containers"},
+	"dockerfile_lint":         {"file": "path", "dockerfile": "path", "text": "content", "input": "content"},
+	"docker_exec":             {"container_name": "container", "name": "container", "cmd": "command", "arguments": "args"},
+	"compose_up":              {"dir": "project_dir", "directory": "project_dir", "project_directory": "project_dir", "compose_file": "file"},
+	"docker_cancel":           {"id": "operation_id", "operation": "operation_id", "op_id": "operation_id"},
+	"ops_kill":                {"id": "operation_id", "operation": "operation_id", "op_id": "operation_id"},
+	"compose_down":            {"dir": "project_dir", "directory": "project_dir", "project_directory": "project_dir", "compose_file": "file"},
+	"k8s_events":              {"ns": "namespace"},
+	"k8s_top_pods":            {"ns": "namespace", "sort": "sort_by", "all": "all_namespaces"},
+	"k8s_watch_pods":          {"ns": "namespace", "label_selector": "selector", "labels": "selector"},
+	"helm_template":           {"chart_path": "chart", "release": "release_name", "name": "release_name", "ns": "namespace", "values_file": "values"},
+	"kustomize_build":         {"dir": "path", "directory": "path"},
+	"kubectl":                 {"command": "verb", "subcommand": "verb", "arguments": "args", "input": "stdin", "manifest": "stdin"},
+	"pipeline":                {"commands": "stages", "steps": "stages", "input": "stdin"},
+	"k8s_diff":                {"manifests": "manifest", "yaml": "manifest", "file": "path", "dir": "path"},
+	"checksum":                {"file": "path", "algo": "algorithm", "hash": "algorithm"},
+	"watch_file":              {"file": "path", "directory": "path", "dir": "path", "duration": "timeout", "seconds": "timeout"},
+	"create_archive":          {"src": "source", "path": "source", "dest": "output", "destination": "output"},
+	"extract_archive":         {"file": "archive", "path": "archive", "dest": "destination", "output": "destination"},
+	"render_template":         {"text": "template", "file": "template_file", "vars": "data", "variables": "data"},
+	"validate_manifest":       {"file": "path", "manifest": "content", "input": "content", "text": "content", "type": "kind"},
+	"convert_format":          {"file": "input_file", "path": "input_file", "input": "content", "data": "content", "from_format": "from", "to_format": "to"},
+	"git_init":                {"dir": "directory", "path": "directory"},
+	"create_table":            {"table": "table_name", "name": "table_name", "columns": "headers"},
+	"read-query":              {"sql": "query", "database": "db"},
+	"write-query":             {"sql": "query", "database": "db"},
+	"bulk_insert":             {"table_name": "table", "database": "db"},
+	"run_sql_file":            {"file": "path", "sql_file": "path", "script": "path", "database": "db", "url": "dsn", "connection_string": "dsn"},
+	"begin_transaction":       {"database": "db"},
+	"create-SQLtable":         {"sql": "definition", "query": "definition", "database": "db"},
+	"list-tables":             {"database": "db"},
+	"sqlite_schema":           {"table_name": "table", "database": "db"},
+	"get_secret":              {"name": "key", "secret": "key", "secret_name": "key"},
+	"validate_sql":            {"sql": "query", "database": "db"},
+	"format_sql":              {"sql": "query"},
+	"create_index":            {"table_name": "table", "index_name": "name", "database": "db"},
+	"sqlite_ping":             {"database": "db"},
+	"describe_tool":           {"tool": "name", "tool_name": "name"},
+	"list_tools":              {"tool": "name", "tool_name": "name"},
+}
+
+// resolveAliases returns args with the aliased arguments of a call to tool
+// renamed to their canonical names, logging each. An alias is left alone when
+// the canonical argument is also given. args itself is not modified.
+func resolveAliases(ctx context.Context, tool string, args map[string]any) map[string]any {
+	out, copied := args, false
+	for alias, name := range argAliases[tool] {
+		v, ok := args[alias]
+		if !ok {
+			continue
+		}
+		if _, taken := out[name]; taken {
+			continue
+		}
+		if !copied {
+			out, copied = maps.Clone(args), true
+		}
+		delete(out, alias)
+		out[name] = v
+		requestLog(ctx).Infof("Tool '%s': using argument '%s' for '%s'", tool, alias, name)
+	}
+	return out
+}
+
+// cleanPath tidies a file path ("./a//b/" becomes "a/b") and leaves empty
+// values alone so required-argument checks still see them as missing.
+func cleanPath(p string) string {
+	if p == "" {
+		return p
+	}
+	return filepath.Clean(p)
+}
+
+// normalizeArgs returns a copy of the arguments of a call to tool with
+// string values trimmed and the tool's normalizers applied. Content
+// arguments are copied as they are.
+func normalizeArgs(tool string, args map[string]any) map[string]any {
+	if args == nil {
+		return nil
+	}
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		if s, ok := v.(string); ok && !contentArgs[tool][k] {
+			s = strings.TrimSpace(s)
+			if norm, ok := argNormalizers[tool][k]; ok {
+				s = norm(s)
+			}
+			v = s
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// normalizeMiddleware resolves argument aliases and normalizes tool
+// arguments before any handler, or the result cache, sees them.
+func normalizeMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := resolveAliases(ctx, req.Params.Name, req.Params.Arguments)
+		req.Params.Arguments = normalizeArgs(req.Params.Name, args)
+		return next(ctx, req)
+	}
+}
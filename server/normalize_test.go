@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		tool string
+		args map[string]any
+		want map[string]any
+	}{
+		{
+			name: "paths are cleaned",
+			tool: "read-query",
+			args: map[string]any{"db": " ./data//app.db ", "limit": 5.0},
+			want: map[string]any{"db": "data/app.db", "limit": 5.0},
+		},
+		{
+			name: "language is lowercased",
+			tool: "ast-grep",
+			args: map[string]any{"language": " Go ", "path": "src/../cmd/"},
+			want: map[string]any{"language": "go", "path": "cmd"},
+		},
+		{
+			name: "unlisted arguments are only trimmed",
+			tool: "unknown_tool",
+			args: map[string]any{"url": " http://example.com/a/../b ", "input": "a//b"},
+			want: map[string]any{"url": "http://example.com/a/../b", "input": "a//b"},
+		},
+		{
+			name: "paths of other tools are not cleaned",
+			tool: "pull_image",
+			args: map[string]any{"image": " registry.local//team/app:1 "},
+			want: map[string]any{"image": "registry.local//team/app:1"},
+		},
+		{
+			name: "content keeps its whitespace",
+			tool: "k8s_diff",
+			args: map[string]any{"manifest": "  kind: Pod\n  metadata:\n    name: a\n", "path": "./k8s/"},
+			want: map[string]any{"manifest": "  kind: Pod\n  metadata:\n    name: a\n", "path": "k8s"},
+		},
+		{
+			name: "stdin is passed through",
+			tool: "pipeline",
+			args: map[string]any{"stdin": "line one\nline two\n\n"},
+			want: map[string]any{"stdin": "line one\nline two\n\n"},
+		},
+		{
+			name: "build output keeps its layout",
+			tool: "docker_build_cache_info",
+			args: map[string]any{"build_output": "#5 [2/3] COPY ./src//app /app\n#5 CACHED\n"},
+			want: map[string]any{"build_output": "#5 [2/3] COPY ./src//app /app\n#5 CACHED\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeArgs(tt.tool, tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeArgs(%q) = %#v, want %#v", tt.tool, got, tt.want)
+			}
+		})
+	}
+}
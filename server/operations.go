@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// cancellableDockerTools lists the Docker tools whose calls can run long
+// enough to be worth stopping with docker_cancel.
+var cancellableDockerTools = map[string]bool{
+	"pull_image":        true,
+	"docker_image_save": true,
+	"docker_image_load": true,
+	"docker_logs_multi": true,
+	"docker_exec":       true,
+	"compose_up":        true,
+	"compose_down":      true,
+}
+
+// operation is one tool call in flight.
+type operation struct {
+	ID      string
+	Tool    string
+	Client  string
+	Session string
+	Started time.Time
+
+	cancel      context.CancelFunc
+	cancelledBy string // tool that cancelled the operation, set under the registry lock
+}
+
+// operationInfo describes an operation in ops_list.
+type operationInfo struct {
+	ID      string `json:"id"`
+	Tool    string `json:"tool"`
+	Client  string `json:"client"`
+	Started string `json:"started"`
+	Elapsed string `json:"elapsed"`
+}
+
+type operationKey struct{}
+
+// operationFromContext returns the operation a tool call runs as, or nil.
+func operationFromContext(ctx context.Context) *operation {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	return op
+}
+
+// operationRegistry tracks every tool call from start to completion so it
+// can be listed and cancelled by id.
+type operationRegistry struct {
+	mu  sync.Mutex
+	ops map[string]*operation
+}
+
+func newOperationRegistry() *operationRegistry {
+	return &operationRegistry{ops: make(map[string]*operation)}
+}
+
+// start registers a call to tool and returns the operation with the context
+// the call must run under. finish must be called when the call returns.
+func (r *operationRegistry) start(ctx context.Context, tool string) (*operation, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	session, _ := sessionID(ctx)
+	op := &operation{
+		ID:      uuid.New().String(),
+		Tool:    tool,
+		Client:  clientIdentity(ctx),
+		Session: session,
+		Started: time.Now(),
+		cancel:  cancel,
+	}
+	r.mu.Lock()
+	r.ops[op.ID] = op
+	r.mu.Unlock()
+	return op, context.WithValue(ctx, operationKey{}, op)
+}
+
+// finish removes op and releases its context. It returns the tool that
+// cancelled op, if any.
+func (r *operationRegistry) finish(op *operation) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.ops, op.ID)
+	op.cancel()
+	return op.cancelledBy
+}
+
+// cancel cancels the operation id on behalf of tool, if accept allows it.
+func (r *operationRegistry) cancel(id, tool string, accept func(*operation) bool) (*operation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	op, ok := r.ops[id]
+	if !ok || !accept(op) {
+		return nil, toolErrorf(CodeNotFound, "no running operation %s (it may have finished already)", id)
+	}
+	op.cancelledBy = tool
+	op.cancel()
+	return op, nil
+}
+
+// list describes the running operations, oldest first, leaving out skip.
+func (r *operationRegistry) list(skip *operation) []operationInfo {
+	r.mu.Lock()
+	ops := make([]*operation, 0, len(r.ops))
+	for _, op := range r.ops {
+		if op != skip {
+			ops = append(ops, op)
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Started.Before(ops[j].Started) })
+	infos := make([]operationInfo, len(ops))
+	for i, op := range ops {
+		infos[i] = operationInfo{
+			ID:      op.ID,
+			Tool:    op.Tool,
+			Client:  op.Client,
+			Started: op.Started.UTC().Format(time.RFC3339),
+			Elapsed: time.Since(op.Started).Round(time.Millisecond).String(),
+		}
+	}
+	return infos
+}
+
+// middleware runs every tool call as an operation. Calls to
+// cancellableDockerTools send the operation id to the client in a
+// notice-level log message as they start, so the caller can pass it to
+// docker_cancel. A cancelled call ends with a CANCELLED error.
+func (r *operationRegistry) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		op, ctx := r.start(ctx, req.Params.Name)
+		if cancellableDockerTools[req.Params.Name] {
+			clientLog(ctx, mcp.LoggingLevelNotice, req.Params.Name, map[string]any{
+				"operation_id": op.ID,
+				"message":      fmt.Sprintf("Started %s; cancel it with docker_cancel", req.Params.Name),
+			})
+		}
+		res, err := next(ctx, req)
+		if by := r.finish(op); by != "" {
+			requestLog(ctx).Infof("Operation %s of tool '%s' was cancelled with %s after %s", op.ID, op.Tool, by, time.Since(op.Started).Round(time.Millisecond))
+			return toolErrorf(CodeCancelled, "operation %s was cancelled with %s", op.ID, by).Result(), nil
+		}
+		return res, err
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// pingTimeout keeps the connectivity checks fast when a backend is down.
+const pingTimeout = 5 * time.Second
+
+// pingResult reports the outcome of a connectivity check and how long it took.
+func pingResult(backend string, elapsed time.Duration, detail string, err error) *mcp.CallToolResult {
+	elapsed = elapsed.Round(time.Millisecond)
+	if err != nil {
+		return errorResult(fmt.Errorf("%s unreachable after %s: %w", backend, elapsed, err))
+	}
+	msg := fmt.Sprintf("%s reachable in %s", backend, elapsed)
+	if detail != "" {
+		msg += " (" + detail + ")"
+	}
+	return mcp.NewToolResultText(msg)
+}
+
+// pingDocker checks that the Docker daemon answers and returns its API version.
+func pingDocker(ctx context.Context) (string, error) {
+	cli, err := newDockerClient()
+	if err != nil {
+		return "", err
+	}
+	defer cli.Close()
+	ping, err := cli.Ping(ctx)
+	if err != nil {
+		return "", dockerError("daemon", err)
+	}
+	return "API version " + ping.APIVersion, nil
+}
+
+// pingSQLite checks that db opens as a SQLite database.
+func pingSQLite(ctx context.Context, db string, env []string) (string, error) {
+	cmd := toolCommand(ctx, env, "sqlite3", "-readonly", db, "SELECT count(*) FROM sqlite_master;")
+	out, err := combinedOutput(cmd)
+	if msg := sqliteError(string(out)); msg != "" {
+		return "", errors.New(msg)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)) + " schema objects", nil
+}
+
+// pingKubernetes checks that the cluster API server reports itself ready.
+func pingKubernetes(ctx context.Context, kubeFlags, env []string) (string, error) {
+	args := append(kubeFlags, "get", "--raw", "/readyz", "--request-timeout="+pingTimeout.String())
+	cmd := toolCommand(ctx, env, "kubectl", args...)
+	out, err := combinedOutput(cmd)
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return "readyz " + strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// maxPipelineStages bounds the number of commands in one pipeline.
+const maxPipelineStages = 8
+
+// defaultPipelineBinaries are the commands pipeline may run unless
+// MCP_PIPELINE_BINARIES says otherwise: text filters that only read their
+// input and the files named in their arguments. Commands that can run other
+// commands or write files (sh, xargs, find, awk, sed, yq) are left out; the
+// writing arguments of sort and uniq are refused by checkPipelineArgs.
+var defaultPipelineBinaries = []string{
+	"base64", "cat", "column", "comm", "cut", "diff", "grep", "head", "jq",
+	"ls", "nl", "paste", "rev", "sha256sum", "sort", "tac", "tail", "tr",
+	"uniq", "wc",
+}
+
+// pipelineForbiddenFlags are options that make an otherwise read-only
+// binary write files or run other programs.
+var pipelineForbiddenFlags = map[string][]string{
+	"sort": {"-o", "--output", "-T", "--temporary-directory", "--compress-program"},
+}
+
+// pipelineMaxOperands caps the non-option arguments of binaries whose later
+// operands are files they write, such as the OUTPUT of uniq.
+var pipelineMaxOperands = map[string]int{
+	"uniq": 1,
+}
+
+// allowedPipelineBinaries returns the commands pipeline may run, from the
+// comma-separated MCP_PIPELINE_BINARIES or, when unset, the defaults.
+func allowedPipelineBinaries() []string {
+	list := os.Getenv("MCP_PIPELINE_BINARIES")
+	if strings.TrimSpace(list) == "" {
+		return defaultPipelineBinaries
+	}
+	var allowed []string
+	for _, b := range strings.Split(list, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			allowed = append(allowed, b)
+		}
+	}
+	return allowed
+}
+
+// pipelineStage is one command of a pipeline.
+type pipelineStage struct {
+	Binary string
+	Args   []string
+}
+
+// stageResult reports how one command of a pipeline ended.
+type stageResult struct {
+	Binary   string `json:"binary"`
+	ExitCode int    `json:"exit_code"`
+	// Status is "exit status N" or, for a command killed by a signal (such
+	// as a broken pipe when a later stage stops reading), "signal: ...".
+	Status string `json:"status"`
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// pipelineResult is the result of the pipeline tool.
+type pipelineResult struct {
+	Output string        `json:"output"`
+	Stages []stageResult `json:"stages"`
+}
+
+// parsePipelineStages reads the stages argument: a list of objects with a
+// binary and optional args. Binaries must be bare names on the allowlist,
+// and arguments that look like paths must stay inside the workspace.
+func parsePipelineStages(raw any) ([]pipelineStage, error) {
+	list, ok := raw.([]any)
+	if !ok || len(list) == 0 {
+		return nil, toolErrorf(CodeInvalidParam, "invalid or missing stages parameter: expected a list of {binary, args} objects")
+	}
+	if len(list) > maxPipelineStages {
+		return nil, toolErrorf(CodeInvalidParam, "invalid stages parameter: at most %d stages", maxPipelineStages)
+	}
+	allowed := allowedPipelineBinaries()
+	stages := make([]pipelineStage, 0, len(list))
+	for i, item := range list {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			return nil, toolErrorf(CodeInvalidParam, "invalid stage %d: expected an object with binary and args", i+1)
+		}
+		binary, _ := obj["binary"].(string)
+		binary = strings.TrimSpace(binary)
+		switch {
+		case binary == "":
+			return nil, toolErrorf(CodeInvalidParam, "invalid stage %d: missing binary", i+1)
+		case strings.ContainsRune(binary, '/') || strings.ContainsRune(binary, filepath.Separator):
+			return nil, toolErrorf(CodeInvalidParam, "invalid stage %d: binary must be a command name, not a path", i+1)
+		case !slices.Contains(allowed, binary):
+			return nil, toolErrorf(CodePermissionDenied, "binary %q is not allowed in pipelines (allowed: %s; see MCP_PIPELINE_BINARIES)", binary, strings.Join(allowed, ", "))
+		}
+		if err := requireBinary(binary); err != nil {
+			return nil, err
+		}
+		stage := pipelineStage{Binary: binary}
+		rawArgs, _ := obj["args"].([]any)
+		if obj["args"] != nil && rawArgs == nil {
+			return nil, toolErrorf(CodeInvalidParam, "invalid stage %d: args must be a list of strings", i+1)
+		}
+		for _, a := range rawArgs {
+			s, ok := a.(string)
+			if !ok {
+				return nil, toolErrorf(CodeInvalidParam, "invalid stage %d: args must be a list of strings", i+1)
+			}
+			if err := checkPipelineArg(s); err != nil {
+				return nil, toolErrorf(CodeInvalidParam, "invalid stage %d: %v", i+1, err)
+			}
+			stage.Args = append(stage.Args, s)
+		}
+		if err := checkPipelineArgs(binary, stage.Args); err != nil {
+			return nil, toolErrorf(CodePermissionDenied, "invalid stage %d: %v", i+1, err)
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+// checkPipelineArg rejects an argument that is an absolute path or climbs
+// with .. out of the workspace. For a --flag=value argument the value is
+// checked; for a short option every suffix after the dash is, as the value
+// can be attached to any letter of a cluster (-o/etc/x, -rf/etc/x). That
+// also refuses -fdir/file; such values must be passed as the next argument.
+func checkPipelineArg(arg string) error {
+	values := []string{arg}
+	switch {
+	case strings.HasPrefix(arg, "--"):
+		_, v, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil
+		}
+		values = []string{v}
+	case strings.HasPrefix(arg, "-"):
+		values = nil
+		for i := 2; i < len(arg); i++ {
+			values = append(values, arg[i:])
+		}
+	}
+	for _, value := range values {
+		if !filepath.IsAbs(value) && !slices.Contains(strings.Split(filepath.ToSlash(value), "/"), "..") {
+			continue
+		}
+		if _, err := workspacePath(value); err != nil {
+			return fmt.Errorf("argument %q is outside the workspace", arg)
+		}
+	}
+	return nil
+}
+
+// checkPipelineArgs refuses the arguments with which binary would write
+// files or run programs: pipelineForbiddenFlags, also abbreviated (--out)
+// or inside a cluster of short options (-ro), and operands beyond
+// pipelineMaxOperands.
+func checkPipelineArgs(binary string, args []string) error {
+	operands := 0
+	options := true
+	for _, arg := range args {
+		if !options || arg == "-" || !strings.HasPrefix(arg, "-") {
+			operands++
+			if max, ok := pipelineMaxOperands[binary]; ok && operands > max {
+				return fmt.Errorf("%s may take at most %d file operand(s) in a pipeline; it writes to the next", binary, max)
+			}
+			continue
+		}
+		if arg == "--" {
+			options = false
+			continue
+		}
+		for _, flag := range pipelineForbiddenFlags[binary] {
+			long, _, _ := strings.Cut(arg, "=")
+			switch {
+			case strings.HasPrefix(flag, "--") && strings.HasPrefix(long, "--") && len(long) > 2 && strings.HasPrefix(flag, long),
+				!strings.HasPrefix(flag, "--") && !strings.HasPrefix(arg, "--") && strings.Contains(arg[1:], flag[1:]):
+				return fmt.Errorf("%s %s is not allowed in pipelines: it writes files or runs programs", binary, flag)
+			}
+		}
+	}
+	return nil
+}
+
+// runPipeline starts every stage in dir with the stdout of each connected to
+// the stdin of the next, feeds stdin to the first and waits for all of
+// them. A stage exiting non-zero is reported in its result, not as an
+// error, as a shell would; errors are for stages that could not run.
+func runPipeline(ctx context.Context, env []string, dir string, stages []pipelineStage, stdin string) (*pipelineResult, error) {
+	cmds := make([]*exec.Cmd, len(stages))
+	stderrs := make([]bytes.Buffer, len(stages))
+	var stdout bytes.Buffer
+	var outputs []*boundedOutput
+	for i, s := range stages {
+		cmd := toolCommand(ctx, env, s.Binary, s.Args...)
+		cmd.Dir = dir
+		out := &boundedOutput{cmd: cmd, limit: maxCommandOutput()}
+		outputs = append(outputs, out)
+		cmd.Stderr = boundedWriter{out: out, buf: &stderrs[i]}
+		cmds[i] = cmd
+	}
+	cmds[0].Stdin = strings.NewReader(stdin)
+	last := outputs[len(outputs)-1]
+	cmds[len(cmds)-1].Stdout = boundedWriter{out: last, buf: &stdout}
+
+	// The pipe ends are inherited by the children; the server's copies are
+	// closed once every stage has started, so each stage sees EOF when the
+	// one before it exits.
+	var pipeEnds []*os.File
+	closePipes := func() {
+		for _, f := range pipeEnds {
+			f.Close()
+		}
+		pipeEnds = nil
+	}
+	for i := 0; i < len(cmds)-1; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closePipes()
+			return nil, err
+		}
+		pipeEnds = append(pipeEnds, r, w)
+		cmds[i].Stdout = w
+		cmds[i+1].Stdin = r
+	}
+	for i, cmd := range cmds {
+		if err := cmd.Start(); err != nil {
+			for _, started := range cmds[:i] {
+				started.Process.Kill()
+				started.Wait()
+			}
+			closePipes()
+			return nil, fmt.Errorf("failed to start %s: %w", stages[i].Binary, err)
+		}
+	}
+	closePipes()
+
+	res := &pipelineResult{Stages: make([]stageResult, len(stages))}
+	for i, cmd := range cmds {
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("%s failed: %w", stages[i].Binary, err)
+		}
+		res.Stages[i] = stageResult{
+			Binary:   stages[i].Binary,
+			ExitCode: cmd.ProcessState.ExitCode(),
+			Status:   cmd.ProcessState.String(),
+			Stderr:   truncateOutput(stderrs[i].String()),
+		}
+		toolLogFromContext(ctx).Debugf("Stage %d (%s) finished: %s", i+1, stages[i].Binary, cmd.ProcessState)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for i, out := range outputs {
+		if out.exceeded {
+			return nil, toolErrorf(CodeResourceExhausted, "%s produced more than %d bytes of output and was stopped (see MCP_MAX_COMMAND_OUTPUT_BYTES)", stages[i].Binary, out.limit)
+		}
+	}
+	res.Output = truncateOutput(stdout.String())
+	return res, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPipelineArg(t *testing.T) {
+	ws := t.TempDir()
+	if err := os.Mkdir(filepath.Join(ws, "logs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MCP_WORKSPACE", ws)
+	tests := []struct {
+		arg string
+		ok  bool
+	}{
+		{"-i", true},
+		{"error", true},
+		{"logs/app.log", true},
+		{"logs/../app.log", true},
+		{filepath.Join(ws, "logs"), true},
+		{"--file=logs/patterns", true},
+		{"-n1", true},
+		{"/etc/passwd", false},
+		{"../secret", false},
+		{"--file=/root/.ssh/id_rsa", false},
+		{"-f/root/.ssh/id_rsa", false},
+		{"-o/etc/x", false},
+		{"-rf/etc/x", false},
+		{"-f../outside", false},
+		// "/patterns" could be the value of an -s option.
+		{"-flogs/patterns", false},
+		{"-f", true},
+	}
+	for _, tt := range tests {
+		if err := checkPipelineArg(tt.arg); (err == nil) != tt.ok {
+			t.Errorf("checkPipelineArg(%q) = %v, want allowed %v", tt.arg, err, tt.ok)
+		}
+	}
+}
+
+func TestCheckPipelineArgs(t *testing.T) {
+	tests := []struct {
+		binary string
+		args   []string
+		ok     bool
+	}{
+		{"sort", []string{"-rn"}, true},
+		{"sort", []string{"-k2,2", "-t,", "data.csv"}, true},
+		{"sort", []string{"--check"}, true},
+		{"sort", []string{"-o", "out.txt"}, false},
+		{"sort", []string{"-ro", "out.txt"}, false},
+		{"sort", []string{"--output=out.txt"}, false},
+		{"sort", []string{"--out=out.txt"}, false},
+		{"sort", []string{"--compress-program=sh"}, false},
+		{"sort", []string{"-T", "tmp"}, false},
+		{"uniq", []string{"-c"}, true},
+		{"uniq", []string{"-c", "in.txt"}, true},
+		{"uniq", []string{"in.txt", "out.txt"}, false},
+		{"uniq", []string{"--", "-in", "-out"}, false},
+		{"grep", []string{"-o", "error", "a.log", "b.log"}, true},
+	}
+	for _, tt := range tests {
+		if err := checkPipelineArgs(tt.binary, tt.args); (err == nil) != tt.ok {
+			t.Errorf("checkPipelineArgs(%s, %q) = %v, want allowed %v", tt.binary, tt.args, err, tt.ok)
+		}
+	}
+}
+
+func TestParsePipelineStages(t *testing.T) {
+	t.Setenv("MCP_WORKSPACE", t.TempDir())
+	t.Setenv("MCP_PIPELINE_BINARIES", "")
+	tests := []struct {
+		name  string
+		raw   any
+		code  ErrorCode
+		count int
+	}{
+		{"valid", []any{
+			map[string]any{"binary": "sort"},
+			map[string]any{"binary": "uniq", "args": []any{"-c"}},
+		}, "", 2},
+		{"empty", []any{}, CodeInvalidParam, 0},
+		{"not a list", "sort | uniq", CodeInvalidParam, 0},
+		{"binary path", []any{map[string]any{"binary": "/bin/sort"}}, CodeInvalidParam, 0},
+		{"not allowed", []any{map[string]any{"binary": "sh", "args": []any{"-c", "id"}}}, CodePermissionDenied, 0},
+		{"yq not allowed", []any{map[string]any{"binary": "yq", "args": []any{"-i", ".a = 1", "x.yaml"}}}, CodePermissionDenied, 0},
+		{"bad args", []any{map[string]any{"binary": "sort", "args": "-rn"}}, CodeInvalidParam, 0},
+		{"path outside", []any{map[string]any{"binary": "cat", "args": []any{"-A/etc/passwd"}}}, CodeInvalidParam, 0},
+		{"writing flag", []any{map[string]any{"binary": "sort", "args": []any{"-o", "out.txt"}}}, CodePermissionDenied, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := parsePipelineStages(tt.raw)
+			if tt.code == "" {
+				if err != nil || len(stages) != tt.count {
+					t.Fatalf("parsePipelineStages = %d stages, %v; want %d", len(stages), err, tt.count)
+				}
+				return
+			}
+			if code := classifyError(err); err == nil || code != tt.code {
+				t.Errorf("parsePipelineStages error = %v (%s), want %s", err, code, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// postgresDatabase is the database the Postgres tools connect to.
+const postgresDatabase = "postgres"
+
+// runPsql runs sql through psql and returns its unaligned, tuples-only output.
+// The script is fed on stdin so vars can be referenced safely as :'name'
+// (psql quotes them), which -c does not support.
+func runPsql(ctx context.Context, env []string, vars map[string]string, sql string) ([]byte, error) {
+	args := []string{"-X", "-A", "-t", "-d", postgresDatabase, "-v", "ON_ERROR_STOP=1"}
+	for k, v := range vars {
+		args = append(args, "-v", k+"="+v)
+	}
+	cmd := toolCommand(ctx, env, "psql", append(args, "-f", "-")...)
+	cmd.Stdin = strings.NewReader(sql)
+	var stdout, stderr bytes.Buffer
+	if err := runCommand(cmd, &stdout, &stderr); err != nil {
+		return nil, fmt.Errorf("psql failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return bytes.TrimSpace(stdout.Bytes()), nil
+}
+
+// tableStatsSQL lists user tables with their size and planner statistics as a
+// JSON array, largest first, optionally restricted to the :'schema' schema.
+const tableStatsSQL = `
+SELECT coalesce(json_agg(t ORDER BY t.total_bytes DESC), '[]'::json)
+FROM (
+	SELECT schemaname AS schema,
+	       relname AS table,
+	       n_live_tup AS row_estimate,
+	       pg_total_relation_size(relid) AS total_bytes,
+	       pg_size_pretty(pg_total_relation_size(relid)) AS total_size,
+	       pg_indexes_size(relid) AS index_bytes,
+	       pg_size_pretty(pg_indexes_size(relid)) AS index_size,
+	       greatest(last_analyze, last_autoanalyze) AS last_analyzed
+	FROM pg_stat_user_tables
+	WHERE :'schema' = '' OR schemaname = :'schema'
+) t;
+`
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// notifyProgress reports the progress of a long tool call. Clients that sent
+// a progress token get notifications/progress; others get a log message.
+func notifyProgress(ctx context.Context, req mcp.CallToolRequest, done, total int, message string) {
+	meta := req.Params.Meta
+	if meta == nil || meta.ProgressToken == nil {
+		clientLog(ctx, mcp.LoggingLevelInfo, req.Params.Name,
+			map[string]any{"progress": done, "total": total, "message": message})
+		return
+	}
+	err := mcpServer.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+		"progressToken": meta.ProgressToken,
+		"progress":      done,
+		"total":         total,
+		"message":       message,
+	})
+	if err != nil {
+		requestLog(ctx).Debugf("Failed to send progress notification: %v", err)
+	}
+}
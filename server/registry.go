@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/client"
+)
+
+// manifestMediaTypes are the manifest formats asked for when resolving a
+// digest; the first two are multi-platform indexes.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// digestResolution is the result of docker_resolve_digest.
+type digestResolution struct {
+	Reference string `json:"reference"`
+	// Pinned is the repository with the resolved digest, ready to use in
+	// place of the tag.
+	Pinned   string `json:"pinned"`
+	Digest   string `json:"digest"`
+	Platform string `json:"platform,omitempty"`
+	// IndexDigest is the digest of the multi-platform index the platform's
+	// manifest was picked from; pinning it keeps every platform.
+	IndexDigest string   `json:"index_digest,omitempty"`
+	Platforms   []string `json:"platforms,omitempty"`
+	Source      string   `json:"source"` // "registry" or "local"
+	Note        string   `json:"note,omitempty"`
+}
+
+// registryManifest holds the fields of a manifest or index needed to pick a
+// platform.
+type registryManifest struct {
+	MediaType string `json:"mediaType"`
+	Manifests []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Platform  *struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// registryClient reads manifests from a Docker registry (v2 API). Like
+// pull_image it sends no credentials, so only public images resolve; the
+// anonymous bearer token that registries such as Docker Hub require is
+// fetched on demand. Tool contexts are not cancelled when an SSE client goes
+// away, so http should carry a Timeout.
+type registryClient struct {
+	http *http.Client
+}
+
+// resolveDigest looks up the digest of image in its registry. For a
+// multi-platform image the digest is that of the manifest for platform
+// ("os/arch[/variant]").
+func (r *registryClient) resolveDigest(ctx context.Context, image, platform string) (*digestResolution, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil, toolErrorf(CodeInvalidParam, "invalid image reference %q: %v", image, err)
+	}
+	named = reference.TagNameOnly(named)
+	ref := ""
+	switch n := named.(type) {
+	case reference.Canonical:
+		ref = n.Digest().String()
+	case reference.Tagged:
+		ref = n.Tag()
+	}
+	digest, body, err := r.fetchManifest(ctx, named, ref)
+	if err != nil {
+		return nil, err
+	}
+	res := &digestResolution{Reference: named.String(), Digest: digest, Source: "registry"}
+	var m registryManifest
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, toolErrorf(CodeUpstreamUnavailable, "registry returned an invalid manifest for %s: %v", image, err)
+	}
+	if len(m.Manifests) > 0 {
+		res.IndexDigest = digest
+		res.Digest = ""
+		want := strings.Split(platform, "/")
+		for _, d := range m.Manifests {
+			if d.Platform == nil || d.Platform.OS == "unknown" {
+				// Attestations and other non-image entries.
+				continue
+			}
+			p := d.Platform.OS + "/" + d.Platform.Architecture
+			if d.Platform.Variant != "" {
+				p += "/" + d.Platform.Variant
+			}
+			res.Platforms = append(res.Platforms, p)
+			if res.Digest == "" && d.Platform.OS == want[0] && len(want) > 1 && d.Platform.Architecture == want[1] &&
+				(len(want) < 3 || d.Platform.Variant == want[2]) {
+				res.Digest, res.Platform = d.Digest, p
+			}
+		}
+		if res.Digest == "" {
+			return nil, toolErrorf(CodeNotFound, "image %s has no manifest for platform %s (available: %s)", image, platform, strings.Join(res.Platforms, ", "))
+		}
+	} else {
+		res.Note = "single-platform image; its platform is not checked against the requested one"
+	}
+	res.Pinned = named.Name() + "@" + res.Digest
+	return res, nil
+}
+
+// fetchManifest GETs a manifest by tag or digest and returns its digest and
+// body.
+func (r *registryClient) fetchManifest(ctx context.Context, named reference.Named, ref string) (string, []byte, error) {
+	host := reference.Domain(named)
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	scheme := "https"
+	if h, _, err := net.SplitHostPort(host); (err == nil && h == "localhost") || host == "localhost" || strings.HasPrefix(host, "127.") {
+		// Docker treats loopback registries as insecure too.
+		scheme = "http"
+	}
+	u := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, host, reference.Path(named), ref)
+
+	token := ""
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := r.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", nil, ctx.Err()
+			}
+			return "", nil, toolErrorf(CodeUpstreamUnavailable, "registry %s is not reachable: %w", host, err)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+		resp.Body.Close()
+		if err != nil {
+			return "", nil, toolErrorf(CodeUpstreamUnavailable, "failed to read manifest from %s: %w", host, err)
+		}
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized && attempt == 0:
+			token, err = r.anonymousToken(ctx, resp.Header.Get("WWW-Authenticate"), reference.Path(named))
+			if err != nil {
+				return "", nil, err
+			}
+			continue
+		case resp.StatusCode == http.StatusOK:
+			digest := resp.Header.Get("Docker-Content-Digest")
+			if digest == "" {
+				digest = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+			}
+			return digest, body, nil
+		case resp.StatusCode == http.StatusNotFound:
+			return "", nil, toolErrorf(CodeNotFound, "manifest unknown: %s not found in %s", named.Name()+":"+ref, host)
+		case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+			return "", nil, toolErrorf(CodePermissionDenied, "registry %s denied access to %s (authentication required; private images are not supported)", host, named.Name())
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			return "", nil, toolErrorf(CodeUpstreamUnavailable, "registry %s returned %s", host, strings.ToLower(resp.Status))
+		}
+		return "", nil, toolErrorf(CodeUpstreamUnavailable, "registry %s returned %s: %s", host, resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// anonymousToken fetches a pull token from the realm named in a Bearer
+// WWW-Authenticate challenge.
+func (r *registryClient) anonymousToken(ctx context.Context, challenge, repo string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", toolErrorf(CodePermissionDenied, "registry requires %s authentication, which is not supported", scheme)
+	}
+	fields := map[string]string{}
+	for _, p := range strings.Split(params, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok {
+			fields[strings.ToLower(k)] = strings.Trim(v, `"`)
+		}
+	}
+	realm, err := url.Parse(fields["realm"])
+	if err != nil || fields["realm"] == "" {
+		return "", toolErrorf(CodeUpstreamUnavailable, "registry sent an invalid authentication challenge: %q", challenge)
+	}
+	q := realm.Query()
+	if fields["service"] != "" {
+		q.Set("service", fields["service"])
+	}
+	q.Set("scope", "repository:"+repo+":pull")
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return "", toolErrorf(CodeUpstreamUnavailable, "registry token service is not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", toolErrorf(CodePermissionDenied, "registry token service returned %s", resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return "", toolErrorf(CodeUpstreamUnavailable, "invalid response from registry token service: %v", err)
+	}
+	if tok.Token == "" {
+		tok.Token = tok.AccessToken
+	}
+	return tok.Token, nil
+}
+
+// daemonPlatform returns the "os/arch" of the Docker daemon.
+func daemonPlatform(ctx context.Context, cli *client.Client) (string, error) {
+	v, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return "", dockerError("daemon", err)
+	}
+	return v.Os + "/" + v.Arch, nil
+}
+
+// localDigest resolves image from the repo digests of the local copy, i.e.
+// the digest the registry reported when it was pulled. For a multi-platform
+// image that is usually the index digest.
+func localDigest(ctx context.Context, cli *client.Client, image string) (*digestResolution, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil, toolErrorf(CodeInvalidParam, "invalid image reference %q: %v", image, err)
+	}
+	inspect, err := cli.ImageInspect(ctx, image)
+	if err != nil {
+		return nil, dockerError("image", err)
+	}
+	for _, rd := range inspect.RepoDigests {
+		c, err := reference.ParseNormalizedNamed(rd)
+		if err != nil || c.Name() != named.Name() {
+			continue
+		}
+		canonical, ok := c.(reference.Canonical)
+		if !ok {
+			continue
+		}
+		return &digestResolution{
+			Reference: reference.TagNameOnly(named).String(),
+			Pinned:    c.Name() + "@" + canonical.Digest().String(),
+			Digest:    canonical.Digest().String(),
+			Platform:  inspect.Os + "/" + inspect.Architecture,
+			Source:    "local",
+			Note:      "digest recorded when the image was pulled; the tag may have moved since, and for multi-platform images this is the index digest",
+		}, nil
+	}
+	return nil, toolErrorf(CodeNotFound, "local image %s has no digest for %s (it was built locally or never pulled)", image, named.Name())
+}
+
+// isUnreachable reports whether err means the registry could not be
+// contacted at all.
+func isUnreachable(err error) bool {
+	var te *ToolError
+	return errors.As(err, &te) && te.Code == CodeUpstreamUnavailable
+}
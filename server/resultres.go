@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// resultURIPrefix starts the URI of every stored result.
+	resultURIPrefix = "mcpserver://results/"
+	// asResourceArg asks for a tool's output as a resource.
+	asResourceArg = "as_resource"
+	// resultPreviewLines and resultPreviewBytes bound the preview returned
+	// in place of a stored result.
+	resultPreviewLines = 20
+	resultPreviewBytes = 2 << 10
+)
+
+// withAsResourceArg declares the as_resource argument on tools whose output
+// can be large.
+func withAsResourceArg() mcp.ToolOption {
+	return mcp.WithBoolean(asResourceArg,
+		mcp.Description("Store the output as an MCP resource and return its URI with a short preview instead of the full text; read it with resources/read when needed (default false)"),
+	)
+}
+
+// storedResult is the output of one tool call kept as a resource.
+type storedResult struct {
+	tool    string
+	session string
+	text    string
+	created time.Time
+	timer   *time.Timer
+}
+
+// resultStore keeps tool outputs requested with as_resource, readable through
+// resources/read by the session that made the call. Results are dropped
+// after ttl, when their session ends, or oldest first once together they
+// exceed maxBytes.
+type resultStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxBytes int
+	used     int
+	results  map[string]*storedResult
+}
+
+// newResultStoreFromEnv builds the store from MCP_RESULT_TTL (default 1h) and
+// MCP_RESULT_MAX_BYTES (default 64 MiB).
+func newResultStoreFromEnv() *resultStore {
+	return &resultStore{
+		ttl:      envDuration("MCP_RESULT_TTL", time.Hour),
+		maxBytes: envInt("MCP_RESULT_MAX_BYTES", 64<<20),
+		results:  make(map[string]*storedResult),
+	}
+}
+
+// save stores text and returns the URI of the new resource.
+func (s *resultStore) save(tool, session, text string) string {
+	id := uuid.New().String()
+	r := &storedResult{tool: tool, session: session, text: text, created: time.Now()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.maxBytes > 0 && s.used+len(text) > s.maxBytes && len(s.results) > 0 {
+		s.dropOldestLocked()
+	}
+	r.timer = time.AfterFunc(s.ttl, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.results[id] == r {
+			s.dropLocked(id)
+			log.Debugf("Dropped expired result resource %s", id)
+		}
+	})
+	s.results[id] = r
+	s.used += len(text)
+	return resultURIPrefix + id
+}
+
+// dropLocked removes one result; s.mu must be held.
+func (s *resultStore) dropLocked(id string) {
+	if r, ok := s.results[id]; ok {
+		r.timer.Stop()
+		s.used -= len(r.text)
+		delete(s.results, id)
+	}
+}
+
+// dropOldestLocked removes the oldest result; s.mu must be held.
+func (s *resultStore) dropOldestLocked() {
+	var oldest string
+	for id, r := range s.results {
+		if oldest == "" || r.created.Before(s.results[oldest].created) {
+			oldest = id
+		}
+	}
+	s.dropLocked(oldest)
+	log.Debugf("Dropped result resource %s to stay within MCP_RESULT_MAX_BYTES", oldest)
+}
+
+// read returns a stored result to the session that created it.
+func (s *resultStore) read(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id := strings.TrimPrefix(req.Params.URI, resultURIPrefix)
+	s.mu.Lock()
+	r, ok := s.results[id]
+	s.mu.Unlock()
+	session, _ := sessionID(ctx)
+	if !ok || r.session != session {
+		return nil, fmt.Errorf("no such result resource %s (results expire after %s)", req.Params.URI, s.ttl)
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: req.Params.URI, MIMEType: "text/plain", Text: r.text},
+	}, nil
+}
+
+// forget drops the results of a closed session.
+func (s *resultStore) forget(ctx context.Context, session server.ClientSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, r := range s.results {
+		if r.session == session.SessionID() {
+			s.dropLocked(id)
+		}
+	}
+}
+
+// middleware handles as_resource: the argument is removed before the tool,
+// or the result cache, sees it, and a successful text result is stored and
+// replaced by its URI and a preview.
+func (s *resultStore) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		raw, present := req.Params.Arguments[asResourceArg]
+		if !present {
+			return next(ctx, req)
+		}
+		args := maps.Clone(req.Params.Arguments)
+		delete(args, asResourceArg)
+		req.Params.Arguments = args
+		res, err := next(ctx, req)
+		if asResource, _ := raw.(bool); !asResource || err != nil || res == nil || res.IsError {
+			return res, err
+		}
+		var parts []string
+		for _, c := range res.Content {
+			text, ok := c.(mcp.TextContent)
+			if !ok {
+				// Images and embedded resources are returned as they are.
+				return res, nil
+			}
+			parts = append(parts, text.Text)
+		}
+		text := strings.Join(parts, "\n\n")
+		session, _ := sessionID(ctx)
+		uri := s.save(req.Params.Name, session, text)
+		requestLog(ctx).Infof("Stored result of tool '%s' as %s (%d bytes)", req.Params.Name, uri, len(text))
+		return mcp.NewToolResultText(fmt.Sprintf("Output stored as resource %s (%d bytes, %d lines); read it with resources/read. Preview:\n%s",
+			uri, len(text), strings.Count(text, "\n")+1, resultPreview(text))), nil
+	}
+}
+
+// resultPreview returns the first lines of text.
+func resultPreview(text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	preview := strings.Join(lines[:min(len(lines), resultPreviewLines)], "")
+	if len(preview) > resultPreviewBytes {
+		preview = preview[:resultPreviewBytes]
+	}
+	if len(preview) < len(text) {
+		preview = strings.TrimRight(preview, "\n") + "\n..."
+	}
+	return preview
+}
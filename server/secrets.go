@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// redactedResultTools return results that must never be written to the log.
+var redactedResultTools = map[string]bool{
+	"get_secret": true,
+}
+
+// secretKeyPattern is the form of a secret key: letters, digits, '_', '-'
+// and '.', which separates the levels of a nested vault file.
+var secretKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// secretRefScheme prefixes the references get_secret returns in place of
+// secret values, e.g. "secret://db_password". They are expanded on the
+// server when passed in a tool's env argument.
+const secretRefScheme = "secret://"
+
+// serverSecrets is the secret store shared by get_secret and the expansion
+// of secret references.
+var serverSecrets = sync.OnceValue(newSecretStoreFromEnv)
+
+// secretStore reads the secrets served by get_secret from one backend:
+// "env" reads MCP_SECRET_<KEY> style variables of the server's environment,
+// "file" reads a JSON, YAML or TOML vault file. Only keys matching one of
+// the allowed patterns can be read.
+type secretStore struct {
+	backend string
+	prefix  string
+	file    string
+	allowed []string
+}
+
+// newSecretStoreFromEnv configures the store from MCP_SECRET_KEYS (comma-
+// separated key names or patterns such as "db_*"; nothing is readable when
+// unset), MCP_SECRET_FILE (the vault file; selects the file backend) and
+// MCP_SECRET_ENV_PREFIX (default "MCP_SECRET_") for the env backend.
+func newSecretStoreFromEnv() *secretStore {
+	s := &secretStore{backend: "env", prefix: os.Getenv("MCP_SECRET_ENV_PREFIX")}
+	if s.prefix == "" {
+		s.prefix = "MCP_SECRET_"
+	}
+	for _, k := range strings.Split(os.Getenv("MCP_SECRET_KEYS"), ",") {
+		if k = strings.TrimSpace(k); k == "" {
+			continue
+		}
+		if _, err := path.Match(k, ""); err != nil {
+			log.Warnf("Ignoring invalid MCP_SECRET_KEYS pattern %q: %v", k, err)
+			continue
+		}
+		s.allowed = append(s.allowed, k)
+	}
+	if s.file = os.Getenv("MCP_SECRET_FILE"); s.file != "" {
+		s.backend = "file"
+		if fi, err := os.Stat(s.file); err != nil {
+			log.Warnf("Secret file %s is not readable: %v", s.file, err)
+		} else if fi.Mode().Perm()&0o077 != 0 {
+			log.Warnf("Secret file %s is accessible to other users (mode %s); consider chmod 600", s.file, fi.Mode().Perm())
+		}
+	}
+	return s
+}
+
+// readable reports whether key matches one of the allowed patterns.
+func (s *secretStore) readable(key string) bool {
+	for _, pattern := range s.allowed {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the value of key. The value must not be logged.
+func (s *secretStore) get(key string) (string, error) {
+	if !secretKeyPattern.MatchString(key) {
+		return "", toolErrorf(CodeInvalidParam, "invalid secret key %q: use letters, digits, '_', '-' and '.'", key)
+	}
+	if len(s.allowed) == 0 {
+		return "", toolErrorf(CodePermissionDenied, "no secrets are readable; list the keys in MCP_SECRET_KEYS")
+	}
+	if !s.readable(key) {
+		return "", toolErrorf(CodePermissionDenied, "secret %q is not readable (see MCP_SECRET_KEYS)", key)
+	}
+	if s.backend == "env" {
+		name := secretEnvName(s.prefix, key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", toolErrorf(CodeNotFound, "secret %q is not set (expected in %s)", key, name)
+		}
+		return value, nil
+	}
+	return s.fromFile(key)
+}
+
+// expand returns value with a secret reference replaced by the secret it
+// names; other values are returned as they are.
+func (s *secretStore) expand(value string) (string, error) {
+	key, ok := strings.CutPrefix(value, secretRefScheme)
+	if !ok {
+		return value, nil
+	}
+	return s.get(key)
+}
+
+// secretEnvName maps a key to its variable name: db.password with prefix
+// MCP_SECRET_ is MCP_SECRET_DB_PASSWORD.
+func secretEnvName(prefix, key string) string {
+	return prefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// fromFile reads key from the vault file, which is read on every call so
+// rotated secrets are picked up. A key is looked up as a whole first, then
+// as a dotted path into nested tables.
+func (s *secretStore) fromFile(key string) (string, error) {
+	format := formatFromExt(s.file)
+	if format == "" {
+		return "", toolErrorf(CodeInternal, "cannot tell the format of secret file %s from its extension (use .json, .yaml or .toml)", s.file)
+	}
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	v, err := decodeData(data, format)
+	if err != nil {
+		// Parse errors can quote the file's content, so they are not passed on.
+		return "", toolErrorf(CodeInternal, "secret file %s is not valid %s", s.file, strings.ToUpper(format))
+	}
+	vault, _ := v.(map[string]any)
+	value, ok := vault[key]
+	if !ok {
+		value = nestedValue(vault, strings.Split(key, "."))
+	}
+	switch value := value.(type) {
+	case nil:
+		return "", toolErrorf(CodeNotFound, "secret %q is not in %s", key, s.file)
+	case string:
+		return value, nil
+	case map[string]any, []any:
+		return "", toolErrorf(CodeInvalidParam, "secret %q is a table, not a value", key)
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// nestedValue follows keys through nested tables, returning nil when they
+// lead nowhere.
+func nestedValue(v any, keys []string) any {
+	for _, part := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	return v
+}
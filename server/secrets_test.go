@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestSecretStoreExpand(t *testing.T) {
+	t.Setenv("TEST_SECRET_DB_PASSWORD", "hunter2")
+	s := &secretStore{backend: "env", prefix: "TEST_SECRET_", allowed: []string{"db_*"}}
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"secret://db_password", "hunter2", false},
+		{"/path/to/config", "/path/to/config", false},
+		{"secret://api_token", "", true},
+		{"secret://db_missing", "", true},
+	}
+	for _, tt := range tests {
+		got, err := s.expand(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("expand(%q) = %q, %v; want %q, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
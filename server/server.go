@@ -1,19 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"time"
 
-	img "github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/client"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/go-units"
 	"github.com/google/uuid"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/santoshkal/mcpserver/internal/httpgzip"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -69,7 +81,10 @@ func (s sseSession) Initialized() bool {
 }
 
 func main() {
-	log.SetLevel(log.TraceLevel)
+	stdio := flag.Bool("stdio", os.Getenv("MCP_TRANSPORT") == "stdio",
+		"serve MCP over stdin/stdout instead of HTTP/SSE (also MCP_TRANSPORT=stdio)")
+	flag.Parse()
+	setupLogging(*stdio)
 	hooks := &server.Hooks{}
 
 	hooks.AddAfterCallTool(func(
@@ -78,7 +93,11 @@ func main() {
 		req *mcp.CallToolRequest,
 		res *mcp.CallToolResult,
 	) {
-		log.Infof("✅ Tool '%v' completed: %v",
+		if redactedResultTools[req.Params.Name] {
+			requestLog(ctx).Infof("✅ Tool '%v' completed (result redacted)", req.Params.Name)
+			return
+		}
+		requestLog(ctx).Infof("✅ Tool '%v' completed: %v",
 			req.Params.Name,
 			res,
 		)
@@ -86,24 +105,80 @@ func main() {
 
 	// 2) log *every* MCP method (initialize, list_tools, tools/call, etc.)
 	hooks.AddBeforeAny(func(ctx context.Context, id any, method mcp.MCPMethod, message any) {
-		log.Debugf("⮑ Incoming RPC: %s  payload=%#v", method, message)
-	}) // :contentReference[oaicite:0]{index=0}
+		// The payload is not logged: tool arguments can hold secrets, and
+		// tools/call requests are logged below with them masked.
+		requestLog(ctx).Debugf("⮑ Incoming RPC: %s", method)
+	})
 
 	// 3) narrow in on tool‐calls if you like
 	hooks.AddBeforeCallTool(func(ctx context.Context, id any, req *mcp.CallToolRequest) {
-		log.Infof("🔧 Calling tool: %s  args=%v", req.Params.Name, req.Params.Arguments)
+		requestLog(ctx).Infof("🔧 Calling tool: %s  args=%v", req.Params.Name, redactArguments(req.Params.Arguments))
 	})
 
+	// Attribute tool calls to the client that made them.
+	hooks.AddAfterInitialize(rememberClient)
+	hooks.AddOnUnregisterSession(forgetClient)
+	hooks.AddAfterSetLevel(rememberLogLevel)
+	hooks.AddOnUnregisterSession(forgetLogLevel)
+	metrics := newToolMetrics()
+
+	// Roll back SQLite transactions left open by closed sessions.
+	transactions := newTxManagerFromEnv()
+	hooks.AddOnUnregisterSession(transactions.forget)
+
+	// Original file contents of ast-grep rewrites, for ast-grep-undo.
+	snapshots := newSnapshotStoreFromEnv()
+
+	// Outputs of tool calls made with as_resource, read back as resources.
+	results := newResultStoreFromEnv()
+	hooks.AddOnUnregisterSession(results.forget)
+
+	// Tool calls in flight, for docker_cancel, ops_list and ops_kill.
+	operations := newOperationRegistry()
+
 	// Create and configure the MCP server.
-	mcpServer = server.NewMCPServer(
-		"MCP Tool STDIO Server",
-		"v1.0.0",
+	serverOpts := []server.ServerOption{
 		server.WithResourceCapabilities(true, true),
 		server.WithLogging(),
 		server.WithHooks(hooks),
 		server.WithPromptCapabilities(false),
+		server.WithToolHandlerMiddleware(requestLogMiddleware),
+		server.WithToolHandlerMiddleware(metrics.middleware),
+		server.WithToolHandlerMiddleware(toolErrorMiddleware),
+		server.WithToolHandlerMiddleware(recoverMiddleware),
+		server.WithToolHandlerMiddleware(newArgLimitsFromEnv().middleware),
+		server.WithToolHandlerMiddleware(normalizeMiddleware),
+		server.WithToolHandlerMiddleware((&argValidator{}).middleware),
+		server.WithToolHandlerMiddleware(results.middleware),
+		server.WithToolHandlerMiddleware(tempDirMiddleware),
+		server.WithToolHandlerMiddleware(operations.middleware),
+		server.WithToolHandlerMiddleware(newApprovalGateFromEnv().middleware),
+	}
+	if cache := newResultCacheFromEnv(transactions); cache != nil {
+		serverOpts = append(serverOpts, server.WithToolHandlerMiddleware(cache.middleware))
+	}
+	if breakers := newBreakersFromEnv(); breakers != nil {
+		metrics.gauges = append(metrics.gauges, breakers)
+		serverOpts = append(serverOpts, server.WithToolHandlerMiddleware(breakers.middleware))
+	}
+	serverOpts = append(serverOpts, server.WithToolHandlerMiddleware(newDockerLimiterFromEnv().middleware))
+	if queue := newToolQueueFromEnv(); queue != nil {
+		metrics.gauges = append(metrics.gauges, queue)
+		serverOpts = append(serverOpts, server.WithToolHandlerMiddleware(queue.middleware))
+	}
+	mcpServer = server.NewMCPServer(
+		"MCP Tool STDIO Server",
+		"v1.0.0",
+		serverOpts...,
 	)
 	mcpServer.AddNotificationHandler("notifications/error", handleNotification)
+	mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(resultURIPrefix+"{id}", "Tool result",
+			mcp.WithTemplateDescription("Output of a tool call made with as_resource: true"),
+			mcp.WithTemplateMIMEType("text/plain"),
+		),
+		results.read,
+	)
 
 	hooks.AddAfterInitialize(func(ctx context.Context, id any, msg *mcp.InitializeRequest, res *mcp.InitializeResult) {
 		// We need to send UserAgent details as well
@@ -139,22 +214,27 @@ func main() {
 			mcp.Required(),
 			mcp.Description("The path to the output file"),
 		),
+		withEnvArg(),
 	)
 	MarkItDownHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// Validate the "input" and "output" arguments.
 		input, ok := req.Params.Arguments["input"].(string)
 		if !ok || input == "" {
-			return mcp.NewToolResultText("invalid or missing input parameter"), nil
+			return invalidParam("invalid or missing input parameter"), nil
 		}
 		output, ok := req.Params.Arguments["output"].(string)
 		if !ok || output == "" {
-			return mcp.NewToolResultText("invalid or missing output parameter"), nil
+			return invalidParam("invalid or missing output parameter"), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
 		}
 		// TODO: Implememt the MarkitDown CLI Command using exec.Command() to run the tool
-		cmd := exec.Command("markitdown", input, "-o", output)
-		outBytes, err := cmd.CombinedOutput()
+		cmd := toolCommand(ctx, env, "markitdown", input, "-o", output)
+		outBytes, err := combinedOutput(cmd)
 		if err != nil {
-			return mcp.NewToolResultText(fmt.Sprintf("failed to run markitdown: %v\nOutput: %s", err, string(outBytes))), nil
+			return commandError("failed to run markitdown", err, string(outBytes)), nil
 		}
 
 		return mcp.NewToolResultText(fmt.Sprintf("Conversion successful. Output:\n%s", output)), nil
@@ -182,24 +262,25 @@ func main() {
 			mcp.Required(),
 			mcp.Description("The path to the file/directory to search in"),
 		),
+		withEnvArg(),
 	)
 	searchCodeHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// 1. Validate
 		pattern, ok := req.Params.Arguments["pattern"].(string)
 		if !ok || pattern == "" {
-			return mcp.NewToolResultText("invalid or missing 'pattern' parameter"), nil
+			return invalidParam("invalid or missing 'pattern' parameter"), nil
 		}
 		newPattern, ok := req.Params.Arguments["new-pattern"].(string)
 		if !ok || newPattern == "" {
-			return mcp.NewToolResultText("invalid or missing 'new-pattern' parameter"), nil
+			return invalidParam("invalid or missing 'new-pattern' parameter"), nil
 		}
 		lang, ok := req.Params.Arguments["language"].(string)
 		if !ok || lang == "" {
-			return mcp.NewToolResultText("invalid or missing 'language' parameter"), nil
+			return invalidParam("invalid or missing 'language' parameter"), nil
 		}
 		pathParam, ok := req.Params.Arguments["path"].(string)
 		if !ok || pathParam == "" {
-			return mcp.NewToolResultText("invalid or missing 'path' parameter"), nil
+			return invalidParam("invalid or missing 'path' parameter"), nil
 		}
 
 		// 2. Split paths (comma or space)
@@ -219,12 +300,40 @@ func main() {
 			"--pattern", pattern,
 			"--rewrite", newPattern,
 			"--lang", lang,
-			"-U",
 		}
-		args = append(args, paths...)
+
+		if err := requireBinary("ast-grep"); err != nil {
+			return errorResult(err), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+
+		// Rewrite file by file from ast-grep's JSON matches so progress can
+		// be reported per file; unusable JSON falls back to one plain run.
+		plan, err := planAstGrepRewrite(ctx, args, paths, env)
+		if err == nil {
+			if len(plan.files) == 0 {
+				return mcp.NewToolResultText(fmt.Sprintf("No occurrences of '%s' found in %v", pattern, paths)), nil
+			}
+			snap, err := snapshots.capture(plan.files)
+			if err != nil {
+				return errorResult(fmt.Errorf("failed to snapshot files before rewriting: %w", err)), nil
+			}
+			summary, err := applyAstGrepPlan(ctx, req, args, plan, env)
+			summary += fmt.Sprintf("Undo with ast-grep-undo, operation_id %s (kept for %s).\n", snapshots.save(snap), snapshots.ttl)
+			if err != nil {
+				return commandError("ast-grep error", err, summary), nil
+			}
+			return mcp.NewToolResultText(summary), nil
+		}
+		requestLog(ctx).Debugf("Falling back to a plain ast-grep rewrite: %v", err)
+		args = append(append(args, "-U"), paths...)
 
 		//  Run ast-grep
-		outBytes, err := exec.Command("ast-grep", args...).CombinedOutput()
+		cmd := toolCommand(ctx, env, "ast-grep", args...)
+		outBytes, err := combinedOutput(cmd)
 		out := strings.TrimSpace(string(outBytes))
 
 		// If the CLI itself errored *and* produced no output, treat as “no matches”
@@ -234,7 +343,7 @@ func main() {
 		}
 		// If the CLI errored *with* some output, return that as the text
 		if err != nil {
-			return mcp.NewToolResultText(fmt.Sprintf("ast-grep error: %v\n\n%s", err, out)), nil
+			return commandError("ast-grep error", err, out), nil
 		}
 		// If CLI succeeded but no matches, still say so
 		if out == "" {
@@ -248,6 +357,122 @@ func main() {
 	mcpServer.AddTool(searchCodeTool, searchCodeHandler)
 	toolHandlers["ast-grep"] = searchCodeHandler
 
+	// --- Register the ast-grep-undo tool ---
+	astGrepUndoTool := mcp.NewTool("ast-grep-undo",
+		mcp.WithDescription("Restore the files changed by an earlier ast-grep rewrite to their original content"),
+		mcp.WithString("operation_id",
+			mcp.Required(),
+			mcp.Description("The operation id reported by the ast-grep rewrite"),
+		),
+		mcp.WithBoolean("force",
+			mcp.Description("Restore even files edited since the rewrite, discarding those edits"),
+		),
+	)
+	astGrepUndoHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, ok := req.Params.Arguments["operation_id"].(string)
+		if !ok || id == "" {
+			return invalidParam("invalid or missing 'operation_id' parameter"), nil
+		}
+		force, _ := req.Params.Arguments["force"].(bool)
+		restored, conflicts, err := snapshots.restore(id, force)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if len(restored) == 0 && len(conflicts) > 0 {
+			te := toolErrorf(CodeInvalidParam, "%d files changed since the rewrite; pass force: true to restore anyway", len(conflicts))
+			te.Details = map[string]any{"conflicts": conflicts}
+			return te.Result(), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Restored %d files:\n  %s\n", len(restored), strings.Join(restored, "\n  "))), nil
+	}
+	mcpServer.AddTool(astGrepUndoTool, astGrepUndoHandler)
+	toolHandlers["ast-grep-undo"] = astGrepUndoHandler
+
+	// --- Register the ast-grep-diff tool ---
+	astGrepDiffTool := mcp.NewTool("ast-grep-diff",
+		mcp.WithDescription("Search, or rewrite, code with ast-grep only in the files changed in a git working tree or commit range"),
+		mcp.WithString("pattern",
+			mcp.Required(),
+			mcp.Description("The pattern to search for"),
+		),
+		mcp.WithString("new-pattern",
+			mcp.Description("The pattern to replace with; without it matches are only reported"),
+		),
+		mcp.WithString("language",
+			mcp.Required(),
+			mcp.Description("The language to search in"),
+		),
+		mcp.WithString("directory",
+			mcp.Description("Directory inside the git repository (default the workspace root)"),
+		),
+		mcp.WithString("range",
+			mcp.Description("Git range whose changed files are searched (e.g., 'main..HEAD'); default is uncommitted changes, including untracked files"),
+		),
+		withEnvArg(),
+		withAsResourceArg(),
+	)
+	astGrepDiffHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		pattern, ok := req.Params.Arguments["pattern"].(string)
+		if !ok || pattern == "" {
+			return invalidParam("invalid or missing 'pattern' parameter"), nil
+		}
+		lang, ok := req.Params.Arguments["language"].(string)
+		if !ok || lang == "" {
+			return invalidParam("invalid or missing 'language' parameter"), nil
+		}
+		newPattern, _ := req.Params.Arguments["new-pattern"].(string)
+		rng, _ := req.Params.Arguments["range"].(string)
+		dir, _ := req.Params.Arguments["directory"].(string)
+		if dir == "" {
+			dir = "."
+		}
+		dir, err := workspacePath(dir)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := requireBinary("ast-grep"); err != nil {
+			return errorResult(err), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+
+		root, err := gitRepoRoot(ctx, dir, env)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		files, err := changedFiles(ctx, root, rng, env)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if len(files) == 0 {
+			if rng != "" {
+				return mcp.NewToolResultText(fmt.Sprintf("No files changed in %s.", rng)), nil
+			}
+			return mcp.NewToolResultText("No changed files in the working tree."), nil
+		}
+
+		args := []string{"--pattern", pattern, "--lang", lang}
+		if newPattern != "" {
+			args = append(args, "--rewrite", newPattern, "-U")
+		}
+		cmd := toolCommand(ctx, env, "ast-grep", append(append(args, "--"), files...)...)
+		cmd.Dir = root
+		outBytes, err := combinedOutput(cmd)
+		out := strings.TrimSpace(string(outBytes))
+		// ast-grep exits non-zero without output when nothing matches.
+		if err != nil && out != "" {
+			return commandError("ast-grep error", err, out), nil
+		}
+		if out == "" {
+			return mcp.NewToolResultText(fmt.Sprintf("No occurrences of '%s' in %d changed files.", pattern, len(files))), nil
+		}
+		return mcp.NewToolResultText(truncateOutput(out)), nil
+	}
+	mcpServer.AddTool(astGrepDiffTool, astGrepDiffHandler)
+	toolHandlers["ast-grep-diff"] = astGrepDiffHandler
+
 	// Add Mirrord tool
 	mirrordTool := mcp.NewTool("mirrord-exec",
 		mcp.WithDescription("Run `mirrord exec` using a given config file"),
@@ -255,26 +480,27 @@ func main() {
 			mcp.Required(),
 			mcp.Description("Path to the mirrord JSON config file"),
 		),
+		withEnvArg(),
 	)
 
 	mirrordHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// Validate config param
 		cfg, ok := req.Params.Arguments["config"].(string)
 		if !ok || cfg == "" {
-			return mcp.NewToolResultText("invalid or missing 'config' parameter"), nil
+			return invalidParam("invalid or missing 'config' parameter"), nil
 		}
 
-		// Build and run: mirrord exec --config=<cfg>
-		cmd := exec.Command("mirrord", "exec", "--config="+cfg)
-		out, err := cmd.CombinedOutput()
-		text := string(out)
-
+		env, err := commandEnv(req)
 		if err != nil {
-			return mcp.NewToolResultText(
-				fmt.Sprintf("mirrord exec failed: %v\n\n%s", err, text),
-			), nil
+			return errorResult(err), nil
 		}
-		return mcp.NewToolResultText(text), nil
+		// Build and run: mirrord exec --config=<cfg>
+		cmd := toolCommand(ctx, env, "mirrord", "exec", "--config="+cfg)
+		var stdout, stderr bytes.Buffer
+		if err := runCommand(cmd, &stdout, &stderr); err != nil {
+			return commandError("mirrord exec failed", err, stdout.String()+stderr.String()), nil
+		}
+		return outputResult(stdout.String(), stderr.String()), nil
 	}
 
 	mcpServer.AddTool(mirrordTool, mirrordHandler)
@@ -291,232 +517,2631 @@ func main() {
 		// Validate the "image" argument.
 		image, ok := req.Params.Arguments["image"].(string)
 		if !ok || image == "" {
-			return mcp.NewToolResultText("invalid or missing image parameter"), nil
+			return invalidParam("invalid or missing image parameter"), nil
 		}
-		fmt.Fprintf(os.Stderr, "[DEBUG] Invoking tool 'pull_image' with image: %s\n", image)
+		requestLog(ctx).Debugf("Invoking tool 'pull_image' with image: %s", image)
 
-		// Use the Docker client to pull the image.
-		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-		if err != nil {
-			return nil, fmt.Errorf("failed to create Docker client: %v", err)
-		}
-		out, err := cli.ImagePull(ctx, image, img.PullOptions{})
+		// Use the Docker client to pull the image, retrying transient registry errors.
+		cli, err := newDockerClient()
 		if err != nil {
-			return nil, fmt.Errorf("failed to pull image: %v", err)
+			return errorResult(err), nil
 		}
-		defer out.Close()
-		// Log the Docker pull progress.
-		_, err = io.Copy(os.Stderr, out)
+		defer cli.Close()
+		tl := newToolLog(ctx, req)
+		tl.Infof("Pulling image %s", image)
+		err = retryRegistry(ctx, fmt.Sprintf("Pull of image '%s'", image), func() error {
+			return pullImage(ctx, cli, image)
+		})
 		if err != nil {
-			return nil, fmt.Errorf("error reading Docker pull response: %v", err)
+			return errorResult(fmt.Errorf("failed to pull image: %w", err)), nil
 		}
+		tl.Infof("Pulled image %s", image)
 		return mcp.NewToolResultText(fmt.Sprintf("Image '%s' pulled successfully", image)), nil
 	}
 	mcpServer.AddTool(PullImageTool, PullImageHandler)
 	toolHandlers["pull_image"] = PullImageHandler
 
-	// --- Register the get_pods tool ---
-	getPodsTool := mcp.NewTool("get_pods",
-		mcp.WithDescription("Get Kubernetes Pods from the cluster"),
+	// --- Register the docker_resolve_digest tool ---
+	resolveDigestTool := mcp.NewTool("docker_resolve_digest",
+		mcp.WithDescription("Resolve an image tag to its immutable digest (repo@sha256:...) for pinning. Asks the registry, picking the manifest for the Docker daemon's platform from multi-platform images, and falls back to the local image when the registry is unreachable"),
+		mcp.WithString("image",
+			mcp.Required(),
+			mcp.Description("Image reference to resolve (e.g., 'nginx:1.27')"),
+		),
+		mcp.WithString("platform",
+			mcp.Description("Platform as os/arch[/variant] (e.g., 'linux/arm64'); defaults to the Docker daemon's platform"),
+		),
+		mcp.WithBoolean("local",
+			mcp.Description("Only use the digest recorded on the local image instead of asking the registry (default false)"),
+		),
 	)
-	getPodsHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		fmt.Fprintln(os.Stderr, "[DEBUG] Invoking tool 'get_pods'")
-		cmd := exec.Command("kubectl", "get", "pods")
-		output, err := cmd.CombinedOutput()
+	resolveDigestHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		image, ok := req.Params.Arguments["image"].(string)
+		if !ok || image == "" {
+			return invalidParam("invalid or missing image parameter"), nil
+		}
+		platform, _ := req.Params.Arguments["platform"].(string)
+		local, _ := req.Params.Arguments["local"].(bool)
+		if platform != "" && len(strings.Split(platform, "/")) < 2 {
+			return invalidParam("invalid platform %q (use os/arch[/variant], e.g. linux/amd64)", platform), nil
+		}
+		cli, err := newDockerClient()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get pods: %v, output: %s", err, string(output))
+			return errorResult(err), nil
 		}
-		return mcp.NewToolResultText(string(output)), nil
+		defer cli.Close()
+
+		var res *digestResolution
+		if !local {
+			if platform == "" {
+				if platform, err = daemonPlatform(ctx, cli); err != nil {
+					platform = runtime.GOOS + "/" + runtime.GOARCH
+					requestLog(ctx).Warnf("Cannot read the Docker daemon's platform, using %s: %v", platform, err)
+				}
+			}
+			registry := &registryClient{http: &http.Client{Timeout: envDuration("MCP_REGISTRY_TIMEOUT", 30*time.Second)}}
+			err = retryRegistry(ctx, fmt.Sprintf("Digest lookup of image '%s'", image), func() error {
+				var err error
+				res, err = registry.resolveDigest(ctx, image, platform)
+				return err
+			})
+			if err != nil && !isUnreachable(err) {
+				return errorResult(err), nil
+			}
+			if err != nil {
+				requestLog(ctx).Warnf("Registry lookup of '%s' failed, using the local image: %v", image, err)
+				newToolLog(ctx, req).Warnf("Registry unreachable, using the local image's digest: %v", err)
+			}
+		}
+		if res == nil {
+			res, err = localDigest(ctx, cli, image)
+			if err != nil {
+				return errorResult(err), nil
+			}
+		}
+		out, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
 	}
-	mcpServer.AddTool(getPodsTool, getPodsHandler)
-	toolHandlers["get_pods"] = getPodsHandler
+	mcpServer.AddTool(resolveDigestTool, resolveDigestHandler)
+	toolHandlers["docker_resolve_digest"] = resolveDigestHandler
 
-	// --- Register the git_init tool ---
-	gitInitTool := mcp.NewTool("git_init",
-		mcp.WithDescription("Initialize a Git repository in the provided project directory"),
-		mcp.WithString("directory",
-			mcp.Description("Path to the project directory"),
+	// --- Register the docker_image_history tool ---
+	imageHistoryTool := mcp.NewTool("docker_image_history",
+		mcp.WithDescription("Show the layer history of a local Docker image (created-by command, size, creation time)"),
+		mcp.WithString("image",
+			mcp.Required(),
+			mcp.Description("Name or ID of the Docker image (e.g., 'nginx:latest')"),
 		),
+		withAsResourceArg(),
 	)
-	gitInitHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		directory, ok := req.Params.Arguments["directory"].(string)
-		if !ok || directory == "" {
-			return nil, fmt.Errorf("invalid or missing directory parameter")
+	imageHistoryHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		image, ok := req.Params.Arguments["image"].(string)
+		if !ok || image == "" {
+			return invalidParam("invalid or missing image parameter"), nil
 		}
-		fmt.Fprintf(os.Stderr, "[DEBUG] Invoking tool 'git_init' with directory: %s\n", directory)
-		cmd := exec.Command("git", "init", directory)
-		output, err := cmd.CombinedOutput()
+		cli, err := newDockerClient()
 		if err != nil {
-			return nil, fmt.Errorf("failed to initialize git repository: %v, output: %s", err, string(output))
+			return errorResult(err), nil
 		}
-		return mcp.NewToolResultText(string(output)), nil
+		defer cli.Close()
+		items, err := cli.ImageHistory(ctx, image)
+		if err != nil {
+			return dockerError("image", err).Result(), nil
+		}
+		return mcp.NewToolResultText(formatImageHistory(items)), nil
 	}
-	mcpServer.AddTool(gitInitTool, gitInitHandler)
-	toolHandlers["git_init"] = gitInitHandler
+	mcpServer.AddTool(imageHistoryTool, imageHistoryHandler)
+	toolHandlers["docker_image_history"] = imageHistoryHandler
 
-	// --- Register the create_table in Postgres tool ---
-	createTableTool := mcp.NewTool("create_table",
-		mcp.WithDescription("Create a database table in a local Postgres DB instance"),
-		mcp.WithString("table_name",
-			mcp.Description("Name of the table to create"),
-		),
-		mcp.WithString("headers",
-			mcp.Required(),
-			mcp.Description("Comma separated column definitions (e.g., 'id SERIAL PRIMARY KEY, name TEXT')"),
+	// --- Register the docker_build_cache_info tool ---
+	buildCacheTool := mcp.NewTool("docker_build_cache_info",
+		mcp.WithDescription("Report which steps of a docker build were cache hits and which were rebuilt, from the build's output, with hints on where the cache stopped being used"),
+		mcp.WithString("build_output",
+			mcp.Description("Output of `docker build --progress=plain` (or of the legacy builder); give this or output_file"),
 		),
-		mcp.WithString("values",
-			mcp.Description("Comma separated list of values to insert (e.g., '1, \"John\"')"),
+		mcp.WithString("output_file",
+			mcp.Description("Path of a file holding the build output, relative to the workspace root; give this or build_output"),
 		),
 	)
-	createTableHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		tableName, ok := req.Params.Arguments["table_name"].(string)
-		if !ok || tableName == "" {
-			return nil, fmt.Errorf("invalid or missing table_name parameter")
+	buildCacheHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		output, _ := req.Params.Arguments["build_output"].(string)
+		file, _ := req.Params.Arguments["output_file"].(string)
+		if (output == "") == (file == "") {
+			return invalidParam("give exactly one of build_output or output_file"), nil
 		}
-		headers, ok := req.Params.Arguments["headers"].(string)
-		if !ok || headers == "" {
-			return nil, fmt.Errorf("invalid or missing headers parameter")
+		if file != "" {
+			path, err := workspacePath(file)
+			if err != nil {
+				return errorResult(err), nil
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return errorResult(fmt.Errorf("failed to read build output: %w", err)), nil
+			}
+			output = string(b)
 		}
-		values, ok := req.Params.Arguments["values"].(string)
-		if !ok {
-			return nil, fmt.Errorf("invalid or missing values parameter")
+		rep := parseBuildCache(output)
+		if len(rep.Steps) == 0 {
+			return invalidParam("no build steps found; pass the output of `docker build --progress=plain`"), nil
 		}
-		fmt.Fprintf(os.Stderr, "[DEBUG] Invoking tool 'create_table' with table: %s\n", tableName)
-		sqlCmd := fmt.Sprintf("CREATE TABLE %s (%s); INSERT INTO %s VALUES (%s);", tableName, headers, tableName, values)
-		cmd := exec.Command("psql", "-d", "postgres", "-c", sqlCmd)
-		output, err := cmd.CombinedOutput()
+		out, err := json.MarshalIndent(rep, "", "  ")
 		if err != nil {
-			return nil, fmt.Errorf("failed to create table: %v, output: %s", err, string(output))
+			return errorResult(err), nil
 		}
-		return mcp.NewToolResultText(string(output)), nil
+		return mcp.NewToolResultText(string(out)), nil
 	}
-	mcpServer.AddTool(createTableTool, createTableHandler)
-	toolHandlers["create_table"] = createTableHandler
-
-	// Register read query using SELECT tool in Sqlite
+	mcpServer.AddTool(buildCacheTool, buildCacheHandler)
+	toolHandlers["docker_build_cache_info"] = buildCacheHandler
 
-	readQueryTool := mcp.NewTool("read-query",
-		mcp.WithDescription("Execute a SELECT query on a SQLite DB (returns CSV)"),
-		mcp.WithString("db",
+	// --- Register the docker_image_inspect tool ---
+	imageInspectTool := mcp.NewTool("docker_image_inspect",
+		mcp.WithDescription("Show metadata of a local Docker image: id, tags, size, platform, ports, env and entrypoint/cmd"),
+		mcp.WithString("image",
 			mcp.Required(),
-			mcp.Description("Path to the .db file"),
+			mcp.Description("Name or ID of the Docker image (e.g., 'nginx:latest')"),
 		),
-		mcp.WithString("query",
-			mcp.Required(),
-			mcp.Description("The SELECT SQL to run"),
+		mcp.WithString("format",
+			mcp.Description("Optional Go template applied to the full inspect output (e.g., '{{.Os}}/{{.Architecture}}')"),
 		),
 	)
-	readQueryHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		db, _ := req.Params.Arguments["db"].(string)
-		q, _ := req.Params.Arguments["query"].(string)
-		cmd := exec.Command("sqlite3", "-csv", db, q)
-		out, err := cmd.CombinedOutput()
+	imageInspectHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		image, ok := req.Params.Arguments["image"].(string)
+		if !ok || image == "" {
+			return invalidParam("invalid or missing image parameter"), nil
+		}
+		format, _ := req.Params.Arguments["format"].(string)
+		cli, err := newDockerClient()
+		if err != nil {
+			return errorResult(err), nil
+		}
+		defer cli.Close()
+		info, _, err := cli.ImageInspectWithRaw(ctx, image)
+		if err != nil {
+			return dockerError("image", err).Result(), nil
+		}
+		if format != "" {
+			out, err := renderFormat(format, info)
+			if err != nil {
+				return errorResult(err), nil
+			}
+			return mcp.NewToolResultText(out), nil
+		}
+		out, err := json.MarshalIndent(summarizeImage(info), "", "  ")
 		if err != nil {
-			return mcp.NewToolResultText(
-				fmt.Sprintf("read-query failed: %v\n\n%s", err, string(out)),
-			), nil
+			return errorResult(fmt.Errorf("failed to encode image summary: %w", err)), nil
 		}
 		return mcp.NewToolResultText(string(out)), nil
 	}
-	mcpServer.AddTool(readQueryTool, readQueryHandler)
-	toolHandlers["read-query"] = readQueryHandler
+	mcpServer.AddTool(imageInspectTool, imageInspectHandler)
+	toolHandlers["docker_image_inspect"] = imageInspectHandler
 
-	// write-query too in Sqlite using INSERT/UPDATE/DELETE
-	writeQueryTool := mcp.NewTool("write-query",
-		mcp.WithDescription("Execute INSERT/UPDATE/DELETE on a SQLite DB"),
-		mcp.WithString("db",
-			mcp.Required(),
-			mcp.Description("Path to the .db file"),
-		),
-		mcp.WithString("query",
-			mcp.Required(),
-			mcp.Description("The non-SELECT SQL to run"),
-		),
+	// --- Register the docker_info tool ---
+	dockerInfoTool := mcp.NewTool("docker_info",
+		mcp.WithDescription("Summarize the Docker daemon: version, container and image counts, storage driver, CPUs, memory and OS"),
 	)
-	writeQueryHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		db, _ := req.Params.Arguments["db"].(string)
-		q, _ := req.Params.Arguments["query"].(string)
-		cmd := exec.Command("sqlite3", db, q)
-		out, err := cmd.CombinedOutput()
+	dockerInfoHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		cli, err := newDockerClient()
 		if err != nil {
-			return mcp.NewToolResultText(
-				fmt.Sprintf("write-query failed: %v\n\n%s", err, string(out)),
-			), nil
+			return errorResult(err), nil
 		}
-		return mcp.NewToolResultText("OK"), nil
+		defer cli.Close()
+		info, err := cli.Info(ctx)
+		if err != nil {
+			return dockerError("daemon", err).Result(), nil
+		}
+		out, err := json.MarshalIndent(summarizeDaemon(info), "", "  ")
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to encode daemon summary: %w", err)), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
 	}
-	mcpServer.AddTool(writeQueryTool, writeQueryHandler)
-	toolHandlers["write-query"] = writeQueryHandler
+	mcpServer.AddTool(dockerInfoTool, dockerInfoHandler)
+	toolHandlers["docker_info"] = dockerInfoHandler
 
-	//  create-table tool in Sqlite wraps write-query for a CREATE TABLE statement
-	createSQLTableTool := mcp.NewTool("create-SQLtable",
-		mcp.WithDescription("Create a new table in the SQLite DB"),
-		mcp.WithString("db",
-			mcp.Required(),
-			mcp.Description("Path to the .db file"),
-		),
-		mcp.WithString("definition",
-			mcp.Required(),
-			mcp.Description("SQL table definition, e.g. `CREATE TABLE users(id INTEGER PRIMARY KEY, name TEXT);`"),
+	// --- Register the docker_list_volumes tool ---
+	listVolumesTool := mcp.NewTool("docker_list_volumes",
+		mcp.WithDescription("List Docker volumes with their driver, scope, creation time and the containers using them"),
+		mcp.WithArray("filter",
+			mcp.Description("Docker filters as key=value, as for `docker volume ls --filter` (e.g., ['dangling=true'], ['driver=local', 'label=app=web'])"),
+			mcp.Items(map[string]any{"type": "string"}),
 		),
 	)
-	createSQLTableHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		db, _ := req.Params.Arguments["db"].(string)
-		def, _ := req.Params.Arguments["definition"].(string)
-		cmd := exec.Command("sqlite3", db, def)
-		out, err := cmd.CombinedOutput()
+	listVolumesHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		f, err := dockerFilters(req.Params.Arguments["filter"])
 		if err != nil {
-			return mcp.NewToolResultText(
-				fmt.Sprintf("create-table failed: %v\n\n%s", err, string(out)),
-			), nil
+			return invalidParam("%v", err), nil
 		}
-		return mcp.NewToolResultText("Table created"), nil
+		cli, err := newDockerClient()
+		if err != nil {
+			return errorResult(err), nil
+		}
+		defer cli.Close()
+		vols, err := cli.VolumeList(ctx, volume.ListOptions{Filters: f})
+		if err != nil {
+			return dockerError("volume", err).Result(), nil
+		}
+		if len(vols.Volumes) == 0 {
+			return mcp.NewToolResultText("No volumes found."), nil
+		}
+		containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+		if err != nil {
+			return dockerError("container", err).Result(), nil
+		}
+		return mcp.NewToolResultText(formatVolumes(vols.Volumes, volumeUsers(containers))), nil
 	}
-	mcpServer.AddTool(createSQLTableTool, createSQLTableHandler)
-	toolHandlers["create-table"] = createSQLTableHandler
+	mcpServer.AddTool(listVolumesTool, listVolumesHandler)
+	toolHandlers["docker_list_volumes"] = listVolumesHandler
 
-	// list-tables tool in Sqlite query
-	listTablesTool := mcp.NewTool("list-tables",
-		mcp.WithDescription("List all tables in the SQLite DB"),
-		mcp.WithString("db",
-			mcp.Required(),
-			mcp.Description("Path to the .db file"),
+	// --- Register the docker_list_networks tool ---
+	listNetworksTool := mcp.NewTool("docker_list_networks",
+		mcp.WithDescription("List Docker networks with their ID, driver, scope, subnets and the containers attached to them"),
+		mcp.WithArray("filter",
+			mcp.Description("Docker filters as key=value, as for `docker network ls --filter` (e.g., ['driver=bridge'], ['name=web'])"),
+			mcp.Items(map[string]any{"type": "string"}),
 		),
 	)
-	listTablesHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		db, _ := req.Params.Arguments["db"].(string)
-		sql := `SELECT name FROM sqlite_master WHERE type='table' ORDER BY name;`
-		cmd := exec.Command("sqlite3", "-csv", db, sql)
-		out, err := cmd.CombinedOutput()
+	listNetworksHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		f, err := dockerFilters(req.Params.Arguments["filter"])
 		if err != nil {
-			return mcp.NewToolResultText(
-				fmt.Sprintf("list-tables failed: %v\n\n%s", err, string(out)),
-			), nil
+			return invalidParam("%v", err), nil
 		}
-		return mcp.NewToolResultText(fmt.Sprintf("'%v' DB contains '%v' table.", db, string(out))), nil
+		cli, err := newDockerClient()
+		if err != nil {
+			return errorResult(err), nil
+		}
+		defer cli.Close()
+		nets, err := cli.NetworkList(ctx, network.ListOptions{Filters: f})
+		if err != nil {
+			return dockerError("network", err).Result(), nil
+		}
+		if len(nets) == 0 {
+			return mcp.NewToolResultText("No networks found."), nil
+		}
+		containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+		if err != nil {
+			return dockerError("container", err).Result(), nil
+		}
+		return mcp.NewToolResultText(formatNetworks(nets, networkUsers(containers))), nil
 	}
-	mcpServer.AddTool(listTablesTool, listTablesHandler)
-	toolHandlers["list-tables"] = listTablesHandler
-
-	// Setup the Server
+	mcpServer.AddTool(listNetworksTool, listNetworksHandler)
+	toolHandlers["docker_list_networks"] = listNetworksHandler
 
-	addr := ":1234"
-	// sse := server.NewSSEServer(mcpServer, server.WithMessageEndpoint("/rpc"), server.WithSSEEndpoint("/sse"))
-	sseServer := server.NewSSEServer(mcpServer, server.WithBaseURL("http://localhost:1234"), server.WithMessageEndpoint("/rpc"), server.WithSSEEndpoint("/sse"), server.WithHTTPServer(&http.Server{
-		Addr: addr,
-	}),
+	// --- Register the disk_usage tool ---
+	diskUsageTool := mcp.NewTool("disk_usage",
+		mcp.WithDescription("Report free space on the workspace and Docker data directory filesystems, flagging those below the server's minimum (checked before builds)"),
 	)
+	diskUsageHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		report := diskReport(ctx)
+		out, err := json.MarshalIndent(map[string]any{
+			"min_free": units.BytesSize(float64(minFreeDisk())),
+			"disks":    report,
+		}, "", "  ")
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to encode disk usage: %w", err)), nil
+		}
+		text := string(out)
+		if err := checkDiskSpace(report); err != nil {
+			text = "WARNING: " + err.Error() + "\n\n" + text
+		}
+		return mcp.NewToolResultText(text), nil
+	}
+	mcpServer.AddTool(diskUsageTool, diskUsageHandler)
+	toolHandlers["disk_usage"] = diskUsageHandler
 
-	// mux := http.NewServeMux()
-	// mux.Handle("/sse", sse.SSEHandler())
-	// mux.Handle("/rpc", sse.MessageHandler())
-	//
-	log.Printf("▶️  Starting MCP HTTP/SSE server 1 on %s ...", addr)
-	if err := http.ListenAndServe(addr, sseServer); err != nil {
-		log.Fatalf("❌  Failed to start server1: %v", err)
+	// --- Register the docker_image_save tool ---
+	imageSaveTool := mcp.NewTool("docker_image_save",
+		mcp.WithDescription("Save a local Docker image to a tarball inside the workspace"),
+		mcp.WithString("image",
+			mcp.Required(),
+			mcp.Description("Name or ID of the Docker image to save (e.g., 'nginx:latest')"),
+		),
+		mcp.WithString("output_path",
+			mcp.Required(),
+			mcp.Description("Path of the tarball to write, relative to the workspace root"),
+		),
+	)
+	imageSaveHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		image, ok := req.Params.Arguments["image"].(string)
+		if !ok || image == "" {
+			return invalidParam("invalid or missing image parameter"), nil
+		}
+		outputPath, ok := req.Params.Arguments["output_path"].(string)
+		if !ok || outputPath == "" {
+			return invalidParam("invalid or missing output_path parameter"), nil
+		}
+		dest, err := workspacePath(outputPath)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cli, err := newDockerClient()
+		if err != nil {
+			return errorResult(err), nil
+		}
+		defer cli.Close()
+		rc, err := cli.ImageSave(ctx, []string{image})
+		if err != nil {
+			return dockerError("image", err).Result(), nil
+		}
+		defer rc.Close()
+
+		f, err := os.Create(dest)
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to create %s: %w", dest, err)), nil
+		}
+		// Stream straight to disk; image tarballs can be several gigabytes.
+		n, err := io.Copy(f, rc)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dest)
+			return errorResult(fmt.Errorf("failed to write image tarball: %w", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Image '%s' saved to %s (%s)", image, dest, units.HumanSize(float64(n)))), nil
+	}
+	mcpServer.AddTool(imageSaveTool, imageSaveHandler)
+	toolHandlers["docker_image_save"] = imageSaveHandler
+
+	// --- Register the docker_image_load tool ---
+	imageLoadTool := mcp.NewTool("docker_image_load",
+		mcp.WithDescription("Load Docker images from a tarball inside the workspace"),
+		mcp.WithString("input_path",
+			mcp.Required(),
+			mcp.Description("Path of the tarball to load, relative to the workspace root"),
+		),
+	)
+	imageLoadHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		inputPath, ok := req.Params.Arguments["input_path"].(string)
+		if !ok || inputPath == "" {
+			return invalidParam("invalid or missing input_path parameter"), nil
+		}
+		src, err := workspacePath(inputPath)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		f, err := os.Open(src)
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to open %s: %w", src, err)), nil
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to stat %s: %w", src, err)), nil
+		}
+
+		cli, err := newDockerClient()
+		if err != nil {
+			return errorResult(err), nil
+		}
+		defer cli.Close()
+		newToolLog(ctx, req).Infof("Loading images from %s (%s)", inputPath, units.HumanSize(float64(fi.Size())))
+		resp, err := cli.ImageLoad(ctx, f)
+		if err != nil {
+			return dockerError("image", err).Result(), nil
+		}
+		defer resp.Body.Close()
+		loaded, err := loadedImages(resp.Body)
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to load %s: %w", src, err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Loaded %s from %s (%s)",
+			strings.Join(loaded, ", "), src, units.HumanSize(float64(fi.Size())))), nil
+	}
+	mcpServer.AddTool(imageLoadTool, imageLoadHandler)
+	toolHandlers["docker_image_load"] = imageLoadHandler
+
+	// --- Register the docker_logs_multi tool ---
+	logsMultiTool := mcp.NewTool("docker_logs_multi",
+		mcp.WithDescription("Follow the logs of several containers at once for a bounded time, returning the interleaved lines prefixed with each container's name"),
+		mcp.WithArray("containers",
+			mcp.Required(),
+			mcp.Description("Names or IDs of the containers to follow"),
+			mcp.Items(map[string]any{"type": "string"}),
+			mcp.MaxItems(16),
+		),
+		mcp.WithNumber("duration",
+			mcp.Description("How long to follow the logs, in seconds (default 10, max 120)"),
+		),
+		mcp.WithNumber("tail",
+			mcp.Description("Number of existing lines to show per container before following (default 50)"),
+		),
+		withAsResourceArg(),
+	)
+	// Lines are streamed to the client as they arrive; the result holds them all.
+	logsMultiHandler := streamingHandler(func(ctx context.Context, req mcp.CallToolRequest, w io.Writer) (*mcp.CallToolResult, error) {
+		raw, _ := req.Params.Arguments["containers"].([]any)
+		var names []string
+		for _, v := range raw {
+			if name, ok := v.(string); ok && strings.TrimSpace(name) != "" {
+				names = append(names, strings.TrimSpace(name))
+			}
+		}
+		if len(names) == 0 || len(names) != len(raw) {
+			return invalidParam("invalid or missing containers parameter: expected a list of container names"), nil
+		}
+		if len(names) > 16 {
+			return invalidParam("invalid containers parameter: at most 16 containers"), nil
+		}
+		duration, err := logsDuration(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		tail, hasTail, err := nonNegativeIntArg(req.Params.Arguments, "tail")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if !hasTail {
+			tail = 50
+		}
+
+		cli, err := newDockerClient()
+		if err != nil {
+			return errorResult(err), nil
+		}
+		defer cli.Close()
+		ctx, cancel := context.WithTimeout(ctx, duration)
+		defer cancel()
+		out, truncated := tailContainerLogs(ctx, cli, names, tail, maxOutputBytes(), w)
+		if truncated {
+			out += "... [output limit reached; stopped following]\n"
+		}
+		if out == "" {
+			out = fmt.Sprintf("No log output in %s.", duration)
+		}
+		return mcp.NewToolResultText(out), nil
+	})
+	mcpServer.AddTool(logsMultiTool, logsMultiHandler)
+	toolHandlers["docker_logs_multi"] = ToolHandler(logsMultiHandler)
+
+	// --- Register the dockerfile_lint tool ---
+	dockerfileLintTool := mcp.NewTool("dockerfile_lint",
+		mcp.WithDescription("Check a Dockerfile for errors and bad practices with hadolint, or with built-in checks when hadolint is not installed; reports findings with line numbers"),
+		mcp.WithString("path",
+			mcp.Description("Path of the Dockerfile, relative to the workspace root; give this or content"),
+		),
+		mcp.WithString("content",
+			mcp.Description("Inline Dockerfile content; give this or path"),
+		),
+		withEnvArg(),
+	)
+	dockerfileLintHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, _ := req.Params.Arguments["path"].(string)
+		content, _ := req.Params.Arguments["content"].(string)
+		if (path == "") == (content == "") {
+			return invalidParam("give exactly one of path or content"), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if path != "" {
+			src, err := workspacePath(path)
+			if err != nil {
+				return errorResult(err), nil
+			}
+			b, err := os.ReadFile(src)
+			if err != nil {
+				return errorResult(fmt.Errorf("failed to read Dockerfile: %w", err)), nil
+			}
+			content = string(b)
+		}
+		out, err := json.MarshalIndent(lintDockerfile(ctx, content, env), "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(dockerfileLintTool, dockerfileLintHandler)
+	toolHandlers["dockerfile_lint"] = dockerfileLintHandler
+
+	// --- Register the docker_exec tool ---
+	dockerExecTool := mcp.NewTool("docker_exec",
+		mcp.WithDescription("Run a command inside a running container and return its exit code, stdout and stderr"),
+		mcp.WithString("container",
+			mcp.Required(),
+			mcp.Description("Name or ID of the running container"),
+		),
+		mcp.WithString("command",
+			mcp.Required(),
+			mcp.Description("Executable to run (e.g., 'ls'); it is not run through a shell"),
+		),
+		mcp.WithArray("args",
+			mcp.Description("Arguments passed to the command (e.g., ['-la', '/app'])"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("How long to wait for the command, in seconds (default 60, max 600)"),
+		),
+	)
+	dockerExecHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, ok := req.Params.Arguments["container"].(string)
+		if !ok || name == "" {
+			return invalidParam("invalid or missing container parameter"), nil
+		}
+		command, ok := req.Params.Arguments["command"].(string)
+		if !ok || strings.TrimSpace(command) == "" {
+			return invalidParam("invalid or missing command parameter"), nil
+		}
+		cmd := []string{command}
+		raw, _ := req.Params.Arguments["args"].([]any)
+		for _, v := range raw {
+			arg, ok := v.(string)
+			if !ok {
+				return invalidParam("invalid args parameter: expected a list of strings"), nil
+			}
+			cmd = append(cmd, arg)
+		}
+		timeout := 60 * time.Second
+		if secs, ok := req.Params.Arguments["timeout"].(float64); ok {
+			if secs <= 0 || secs > 600 {
+				return invalidParam("invalid timeout parameter: must be between 1 and 600 seconds"), nil
+			}
+			timeout = time.Duration(secs * float64(time.Second))
+		}
+
+		cli, err := newDockerClient()
+		if err != nil {
+			return errorResult(err), nil
+		}
+		defer cli.Close()
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		res, err := execInContainer(ctx, cli, name, cmd, maxOutputBytes())
+		if err != nil {
+			return dockerError("container", err).Result(), nil
+		}
+		out, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to encode exec result: %w", err)), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(dockerExecTool, dockerExecHandler)
+	toolHandlers["docker_exec"] = dockerExecHandler
+
+	// --- Register the compose_up and compose_down tools ---
+	composeUpTool := mcp.NewTool("compose_up",
+		mcp.WithDescription("Start a Docker Compose project in the background (`docker compose up -d`) and return the services' status"),
+		mcp.WithString("project_dir",
+			mcp.Required(),
+			mcp.Description("Project directory in the workspace"),
+		),
+		mcp.WithString("file",
+			mcp.Description("Compose file, relative to project_dir (defaults to compose.yaml / docker-compose.yml)"),
+		),
+		mcp.WithBoolean("disk_check",
+			mcp.Description("Refuse to start when the workspace or Docker data directory is low on space, since images may be pulled or built (default true)"),
+		),
+		withEnvArg(),
+	)
+	composeUpHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		projectDir, ok := req.Params.Arguments["project_dir"].(string)
+		if !ok || projectDir == "" {
+			return invalidParam("invalid or missing project_dir parameter"), nil
+		}
+		file, _ := req.Params.Arguments["file"].(string)
+		dir, composeFile, err := composeProject(projectDir, file)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := validateCompose(ctx, dir, composeFile, env); err != nil {
+			return errorResult(err), nil
+		}
+		if check, ok := req.Params.Arguments["disk_check"].(bool); !ok || check {
+			if err := checkDiskSpace(diskReport(ctx)); err != nil {
+				return errorResult(err), nil
+			}
+		}
+		tl := newToolLog(ctx, req)
+		tl.Infof("Starting project %s", composeFile)
+		if _, err := runCompose(ctx, dir, composeFile, env, "up", "--detach"); err != nil {
+			return errorResult(err), nil
+		}
+		tl.Infof("Project started; collecting service status")
+		status, err := runCompose(ctx, dir, composeFile, env, "ps", "--all")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText("Project started.\n\n" + status), nil
+	}
+	mcpServer.AddTool(composeUpTool, composeUpHandler)
+	toolHandlers["compose_up"] = composeUpHandler
+
+	composeDownTool := mcp.NewTool("compose_down",
+		mcp.WithDescription("Stop and remove the containers of a Docker Compose project (`docker compose down`)"),
+		mcp.WithString("project_dir",
+			mcp.Required(),
+			mcp.Description("Project directory in the workspace"),
+		),
+		mcp.WithString("file",
+			mcp.Description("Compose file, relative to project_dir (defaults to compose.yaml / docker-compose.yml)"),
+		),
+		withEnvArg(),
+	)
+	composeDownHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		projectDir, ok := req.Params.Arguments["project_dir"].(string)
+		if !ok || projectDir == "" {
+			return invalidParam("invalid or missing project_dir parameter"), nil
+		}
+		file, _ := req.Params.Arguments["file"].(string)
+		dir, composeFile, err := composeProject(projectDir, file)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := validateCompose(ctx, dir, composeFile, env); err != nil {
+			return errorResult(err), nil
+		}
+		if _, err := runCompose(ctx, dir, composeFile, env, "down"); err != nil {
+			return errorResult(err), nil
+		}
+		status, err := runCompose(ctx, dir, composeFile, env, "ps", "--all")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText("Project stopped.\n\n" + status), nil
+	}
+	mcpServer.AddTool(composeDownTool, composeDownHandler)
+	toolHandlers["compose_down"] = composeDownHandler
+
+	// --- Register the docker_cancel tool ---
+	dockerCancelTool := mcp.NewTool("docker_cancel",
+		mcp.WithDescription("Cancel a running Docker operation (pull_image, docker_image_save, docker_image_load, docker_logs_multi, docker_exec, compose_up, compose_down) started by this session. Each of those calls announces its operation_id in a log message when it starts"),
+		mcp.WithString("operation_id",
+			mcp.Required(),
+			mcp.Description("Id of the operation to cancel"),
+		),
+	)
+	dockerCancelHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, ok := req.Params.Arguments["operation_id"].(string)
+		if !ok || id == "" {
+			return invalidParam("invalid or missing operation_id parameter"), nil
+		}
+		session, _ := sessionID(ctx)
+		op, err := operations.cancel(id, "docker_cancel", func(op *operation) bool {
+			return op.Session == session && cancellableDockerTools[op.Tool]
+		})
+		if err != nil {
+			return errorResult(err), nil
+		}
+		requestLog(ctx).Infof("Cancelled operation %s of tool '%s'", op.ID, op.Tool)
+		return mcp.NewToolResultText(fmt.Sprintf("Cancelled operation %s (%s, running for %s)",
+			op.ID, op.Tool, time.Since(op.Started).Round(time.Millisecond))), nil
+	}
+	mcpServer.AddTool(dockerCancelTool, dockerCancelHandler)
+	toolHandlers["docker_cancel"] = dockerCancelHandler
+
+	// --- Register the ops_list and ops_kill tools ---
+	opsListTool := mcp.NewTool("ops_list",
+		mcp.WithDescription("List the tool calls the server is running right now, from all clients: operation id, tool, client, start time and elapsed time"),
+	)
+	opsListHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		out, err := json.MarshalIndent(operations.list(operationFromContext(ctx)), "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(opsListTool, opsListHandler)
+	toolHandlers["ops_list"] = opsListHandler
+
+	opsKillTool := mcp.NewTool("ops_kill",
+		mcp.WithDescription("Cancel a running tool call of any client by the operation id from ops_list. Every call needs approval through the server's MCP_APPROVAL_WEBHOOK and is refused when none is set"),
+		mcp.WithString("operation_id",
+			mcp.Required(),
+			mcp.Description("Id of the operation to cancel, from ops_list"),
+		),
+	)
+	opsKillHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, ok := req.Params.Arguments["operation_id"].(string)
+		if !ok || id == "" {
+			return invalidParam("invalid or missing operation_id parameter"), nil
+		}
+		self := operationFromContext(ctx)
+		op, err := operations.cancel(id, "ops_kill", func(op *operation) bool { return op != self })
+		if err != nil {
+			return errorResult(err), nil
+		}
+		requestLog(ctx).Warnf("Killed operation %s of tool '%s' started by %s", op.ID, op.Tool, op.Client)
+		return mcp.NewToolResultText(fmt.Sprintf("Cancelled operation %s (%s from %s, running for %s)",
+			op.ID, op.Tool, op.Client, time.Since(op.Started).Round(time.Millisecond))), nil
+	}
+	mcpServer.AddTool(opsKillTool, opsKillHandler)
+	toolHandlers["ops_kill"] = opsKillHandler
+
+	// --- Register the get_pods tool ---
+	getPodsTool := mcp.NewTool("get_pods",
+		mcp.WithDescription("Get Kubernetes Pods from the cluster"),
+		withKubeconfigArg(),
+		withContextArg(),
+		mcp.WithBoolean("no_cache",
+			mcp.Description("Bypass the result cache for this call"),
+		),
+		withEnvArg(),
+	)
+	getPodsHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		requestLog(ctx).Debug("Invoking tool 'get_pods'")
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		kubeFlags, err := kubectlFlags(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "kubectl", append(kubeFlags, "get", "pods")...)
+		output, err := combinedOutput(cmd)
+		if err != nil {
+			return commandError("failed to get pods", err, string(output)), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	}
+	mcpServer.AddTool(getPodsTool, getPodsHandler)
+	toolHandlers["get_pods"] = getPodsHandler
+
+	// --- Register the k8s_events tool ---
+	k8sEventsTool := mcp.NewTool("k8s_events",
+		mcp.WithDescription("List recent Kubernetes events, newest first, across all namespaces or one, optionally only warnings or those about one object"),
+		mcp.WithString("namespace",
+			mcp.Description("Only events in this namespace (default: all namespaces)"),
+		),
+		mcp.WithString("type",
+			mcp.Description("Only events of this type"),
+			mcp.Enum("Normal", "Warning"),
+		),
+		mcp.WithString("kind",
+			mcp.Description("Only events about objects of this kind, e.g. 'Pod' or 'Deployment'"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Only events about the object with this name"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of events to return (default 50, max 500)"),
+		),
+		withKubeconfigArg(),
+		withContextArg(),
+		withEnvArg(),
+		withAsResourceArg(),
+	)
+	k8sEventsHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		limit, hasLimit, err := nonNegativeIntArg(req.Params.Arguments, "limit")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if !hasLimit {
+			limit = 50
+		}
+		if limit < 1 || limit > 500 {
+			return invalidParam("invalid limit parameter: must be between 1 and 500"), nil
+		}
+		eventType, _ := req.Params.Arguments["type"].(string)
+		if eventType != "" && eventType != "Normal" && eventType != "Warning" {
+			return invalidParam("invalid type parameter: must be 'Normal' or 'Warning'"), nil
+		}
+		namespace, _ := req.Params.Arguments["namespace"].(string)
+		kind, _ := req.Params.Arguments["kind"].(string)
+		name, _ := req.Params.Arguments["name"].(string)
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		kubeFlags, err := kubectlFlags(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := requireBinary("kubectl"); err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "kubectl", append(kubeFlags, eventsArgs(namespace, eventType, kind, name)...)...)
+		var stdout, stderr bytes.Buffer
+		if err := runCommand(cmd, &stdout, &stderr); err != nil {
+			return kubectlError("failed to get events", err, stderr.String()), nil
+		}
+		out, err := formatEvents(stdout.Bytes(), limit, time.Now())
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(truncateOutput(out)), nil
+	}
+	mcpServer.AddTool(k8sEventsTool, k8sEventsHandler)
+	toolHandlers["k8s_events"] = k8sEventsHandler
+
+	// --- Register the k8s_top_pods tool ---
+	k8sTopPodsTool := mcp.NewTool("k8s_top_pods",
+		mcp.WithDescription("Show CPU and memory usage per pod (`kubectl top pods`); needs metrics-server in the cluster"),
+		mcp.WithString("namespace",
+			mcp.Description("Namespace to report on (defaults to the context's namespace)"),
+		),
+		mcp.WithBoolean("all_namespaces",
+			mcp.Description("Report on pods in all namespaces"),
+		),
+		mcp.WithString("sort_by",
+			mcp.Description("Sort pods by usage, highest first"),
+			mcp.Enum("cpu", "memory"),
+		),
+		withKubeconfigArg(),
+		withContextArg(),
+		withEnvArg(),
+	)
+	k8sTopPodsHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := []string{"top", "pods"}
+		namespace, _ := req.Params.Arguments["namespace"].(string)
+		if all, _ := req.Params.Arguments["all_namespaces"].(bool); all {
+			if namespace != "" {
+				return invalidParam("namespace and all_namespaces are mutually exclusive"), nil
+			}
+			args = append(args, "--all-namespaces")
+		} else if namespace != "" {
+			args = append(args, "--namespace", namespace)
+		}
+		switch sortBy, _ := req.Params.Arguments["sort_by"].(string); sortBy {
+		case "":
+		case "cpu", "memory":
+			args = append(args, "--sort-by", sortBy)
+		default:
+			return invalidParam("invalid sort_by parameter: must be 'cpu' or 'memory'"), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		kubeFlags, err := kubectlFlags(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := requireBinary("kubectl"); err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "kubectl", append(kubeFlags, args...)...)
+		var stdout, stderr bytes.Buffer
+		if err := runCommand(cmd, &stdout, &stderr); err != nil {
+			if metricsUnavailable.MatchString(stderr.String()) {
+				return toolErrorf(CodeUpstreamUnavailable, "pod metrics are not available: metrics-server is not installed in the cluster or not ready yet").Result(), nil
+			}
+			return kubectlError("kubectl top pods failed", err, stderr.String()), nil
+		}
+		out := stdout.String()
+		if strings.TrimSpace(out) == "" {
+			out = "No pods found."
+		}
+		return mcp.NewToolResultText(truncateOutput(out)), nil
+	}
+	mcpServer.AddTool(k8sTopPodsTool, k8sTopPodsHandler)
+	toolHandlers["k8s_top_pods"] = k8sTopPodsHandler
+
+	// --- Register the k8s_watch_pods tool ---
+	watchPodsTool := mcp.NewTool("k8s_watch_pods",
+		mcp.WithDescription("Watch Kubernetes pods for a bounded time, streaming state changes as notifications and returning a summary of the transitions observed"),
+		mcp.WithNumber("timeout",
+			mcp.Description("How long to watch, in seconds (default 60, max 600)"),
+		),
+		mcp.WithString("namespace",
+			mcp.Description("Namespace to watch (defaults to the context's namespace)"),
+		),
+		mcp.WithString("selector",
+			mcp.Description("Label selector to filter pods, e.g. 'app=web'"),
+		),
+		withKubeconfigArg(),
+		withContextArg(),
+		withEnvArg(),
+	)
+	watchPodsHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		timeout := 60 * time.Second
+		if secs, ok := req.Params.Arguments["timeout"].(float64); ok {
+			if secs <= 0 || secs > 600 {
+				return invalidParam("invalid timeout parameter: must be between 1 and 600 seconds"), nil
+			}
+			timeout = time.Duration(secs * float64(time.Second))
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		kubeFlags, err := kubectlFlags(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if ns, _ := req.Params.Arguments["namespace"].(string); ns != "" {
+			kubeFlags = append(kubeFlags, "--namespace", ns)
+		}
+		if sel, _ := req.Params.Arguments["selector"].(string); sel != "" {
+			kubeFlags = append(kubeFlags, "--selector", sel)
+		}
+
+		watchCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		transitions, err := watchPods(watchCtx, kubeFlags, env, func(t podTransition) {
+			clientLog(ctx, mcp.LoggingLevelInfo, "k8s_watch_pods", t)
+		})
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to watch pods: %w", err)), nil
+		}
+
+		if len(transitions) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No pod state changes observed in %s.", timeout)), nil
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "Observed %d pod state change(s) in %s:\n", len(transitions), timeout)
+		for _, t := range transitions {
+			fmt.Fprintln(&b, t)
+		}
+		return mcp.NewToolResultText(b.String()), nil
+	}
+	mcpServer.AddTool(watchPodsTool, watchPodsHandler)
+	toolHandlers["k8s_watch_pods"] = watchPodsHandler
+
+	// --- Register the helm_template tool ---
+	helmTemplateTool := mcp.NewTool("helm_template",
+		mcp.WithDescription("Render a Helm chart locally with `helm template` and return the manifests"),
+		mcp.WithString("chart",
+			mcp.Required(),
+			mcp.Description("Chart directory or archive in the workspace, or a repository reference (e.g., 'bitnami/nginx', 'oci://...')"),
+		),
+		mcp.WithString("values",
+			mcp.Description("Inline values YAML, or the path of a values .yaml file in the workspace"),
+		),
+		mcp.WithString("release_name",
+			mcp.Description("Release name used in the rendered manifests (default 'release')"),
+		),
+		mcp.WithString("namespace",
+			mcp.Description("Namespace to render the release into"),
+		),
+		withEnvArg(),
+		withAsResourceArg(),
+	)
+	helmTemplateHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		chart, ok := req.Params.Arguments["chart"].(string)
+		if !ok || chart == "" {
+			return invalidParam("invalid or missing chart parameter"), nil
+		}
+		release, _ := req.Params.Arguments["release_name"].(string)
+		if release == "" {
+			release = "release"
+		}
+		if err := requireBinary("helm"); err != nil {
+			return errorResult(err), nil
+		}
+		if isLocalChart(chart) {
+			path, err := workspacePath(chart)
+			if err != nil {
+				return errorResult(err), nil
+			}
+			if _, err := os.Stat(path); err != nil {
+				return errorResult(fmt.Errorf("chart not found: %w", err)), nil
+			}
+			chart = path
+		}
+
+		args := []string{"template", release, chart}
+		if ns, _ := req.Params.Arguments["namespace"].(string); ns != "" {
+			args = append(args, "--namespace", ns)
+		}
+		if values, _ := req.Params.Arguments["values"].(string); values != "" {
+			valuesFile, err := helmValuesFile(ctx, values)
+			if err != nil {
+				return errorResult(fmt.Errorf("invalid values: %w", err)), nil
+			}
+			args = append(args, "--values", valuesFile)
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+
+		cmd := toolCommand(ctx, env, "helm", args...)
+		var stdout, stderr bytes.Buffer
+		if err := runCommand(cmd, &stdout, &stderr); err != nil {
+			return commandError("helm template failed", err, stderr.String()), nil
+		}
+		return outputResult(stdout.String(), stderr.String()), nil
+	}
+	mcpServer.AddTool(helmTemplateTool, helmTemplateHandler)
+	toolHandlers["helm_template"] = helmTemplateHandler
+
+	// --- Register the kustomize_build tool ---
+	kustomizeBuildTool := mcp.NewTool("kustomize_build",
+		mcp.WithDescription("Render a kustomization with `kustomize build` (or `kubectl kustomize`) and return the manifests"),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Directory in the workspace containing a kustomization.yaml"),
+		),
+		withEnvArg(),
+		withAsResourceArg(),
+	)
+	kustomizeBuildHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, ok := req.Params.Arguments["path"].(string)
+		if !ok || path == "" {
+			return invalidParam("invalid or missing path parameter"), nil
+		}
+		dir, err := workspacePath(path)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if !hasKustomization(dir) {
+			return toolErrorf(CodeNotFound, "no kustomization.yaml, kustomization.yml or Kustomization in %s", path).Result(), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cmd, err := kustomizeCommand(ctx, dir, env)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		var stdout, stderr bytes.Buffer
+		if err := runCommand(cmd, &stdout, &stderr); err != nil {
+			return commandError("kustomize build failed", err, stderr.String()), nil
+		}
+		return outputResult(stdout.String(), stderr.String()), nil
+	}
+	mcpServer.AddTool(kustomizeBuildTool, kustomizeBuildHandler)
+	toolHandlers["kustomize_build"] = kustomizeBuildHandler
+
+	// --- Register the k8s_diff tool ---
+	k8sDiffTool := mcp.NewTool("k8s_diff",
+		mcp.WithDescription("Show what applying manifests would change in the cluster, using `kubectl diff`"),
+		mcp.WithString("manifest",
+			mcp.Description("Inline YAML manifests to compare against the cluster"),
+		),
+		mcp.WithString("path",
+			mcp.Description("Manifest file or directory in the workspace (used when manifest is not given)"),
+		),
+		withKubeconfigArg(),
+		withContextArg(),
+		withEnvArg(),
+		withAsResourceArg(),
+	)
+	k8sDiffHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		manifest, _ := req.Params.Arguments["manifest"].(string)
+		path, _ := req.Params.Arguments["path"].(string)
+		if (manifest == "") == (path == "") {
+			return invalidParam("exactly one of manifest or path is required"), nil
+		}
+		kubeFlags, err := kubectlFlags(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+
+		args := append(kubeFlags, "diff", "-f")
+		if manifest != "" {
+			args = append(args, "-")
+		} else {
+			src, err := workspacePath(path)
+			if err != nil {
+				return errorResult(err), nil
+			}
+			if _, err := os.Stat(src); err != nil {
+				return errorResult(fmt.Errorf("manifest path not found: %w", err)), nil
+			}
+			args = append(args, src)
+		}
+		cmd := toolCommand(ctx, env, "kubectl", args...)
+		if manifest != "" {
+			cmd.Stdin = strings.NewReader(manifest)
+		}
+		var stdout, stderr bytes.Buffer
+		err = runCommand(cmd, &stdout, &stderr)
+
+		// kubectl diff exits 1 when there are differences and >1 on failure.
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			return mcp.NewToolResultText("No differences: the cluster already matches the manifests."), nil
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+			return mcp.NewToolResultText("Differences found:\n\n" + truncateOutput(stdout.String())), nil
+		default:
+			return commandError("kubectl diff failed", err, stderr.String()), nil
+		}
+	}
+	mcpServer.AddTool(k8sDiffTool, k8sDiffHandler)
+	toolHandlers["k8s_diff"] = k8sDiffHandler
+
+	// --- Register the kubectl tool ---
+	kubectlTool := mcp.NewTool("kubectl",
+		mcp.WithDescription("Run a kubectl command (no shell). Verbs are limited to the server's allowlist, by default only those that do not change the cluster"),
+		mcp.WithString("verb",
+			mcp.Required(),
+			mcp.Description("kubectl subcommand, e.g. 'get', 'describe', 'logs', 'apply', 'delete'"),
+		),
+		mcp.WithArray("args",
+			mcp.Description("Arguments after the verb (e.g., ['pods', '-n', 'default', '-o', 'wide'])"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("stdin",
+			mcp.Description("Text passed to kubectl on stdin, e.g. manifests for ['-f', '-']"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("How long to wait for kubectl, in seconds (default 60, max 600)"),
+		),
+		withKubeconfigArg(),
+		withContextArg(),
+		withEnvArg(),
+		withAsResourceArg(),
+	)
+	kubectlHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		verb, ok := req.Params.Arguments["verb"].(string)
+		if !ok || verb == "" {
+			return invalidParam("invalid or missing verb parameter"), nil
+		}
+		var args []string
+		raw, _ := req.Params.Arguments["args"].([]any)
+		for _, v := range raw {
+			arg, ok := v.(string)
+			if !ok {
+				return invalidParam("invalid args parameter: expected a list of strings"), nil
+			}
+			args = append(args, arg)
+		}
+		timeout := 60 * time.Second
+		if secs, ok := req.Params.Arguments["timeout"].(float64); ok {
+			if secs <= 0 || secs > 600 {
+				return invalidParam("invalid timeout parameter: must be between 1 and 600 seconds"), nil
+			}
+			timeout = time.Duration(secs * float64(time.Second))
+		}
+		kubeArgs, err := kubectlCommand(verb, args)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		kubeFlags, err := kubectlFlags(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := requireBinary("kubectl"); err != nil {
+			return errorResult(err), nil
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		cmd := toolCommand(ctx, env, "kubectl", append(kubeFlags, kubeArgs...)...)
+		if stdin, _ := req.Params.Arguments["stdin"].(string); stdin != "" {
+			cmd.Stdin = strings.NewReader(stdin)
+		}
+		var stdout, stderr bytes.Buffer
+		if err := runCommand(cmd, &stdout, &stderr); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return toolErrorf(CodeTimeout, "kubectl %s did not finish within %s", verb, timeout).Result(), nil
+			}
+			return kubectlError("kubectl "+verb+" failed", err, stderr.String()), nil
+		}
+		if stdout.Len() == 0 && stderr.Len() == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("kubectl %s completed with no output.", verb)), nil
+		}
+		return outputResult(stdout.String(), stderr.String()), nil
+	}
+	mcpServer.AddTool(kubectlTool, kubectlHandler)
+	toolHandlers["kubectl"] = kubectlHandler
+
+	// --- Register the pipeline tool ---
+	pipelineTool := mcp.NewTool("pipeline",
+		mcp.WithDescription("Run commands connected like a shell pipeline (stdout of each to stdin of the next) without a shell, in the workspace root. Binaries are limited to the server's allowlist; returns the last command's output and every command's exit code"),
+		mcp.WithArray("stages",
+			mcp.Required(),
+			mcp.Description("Commands in order, each {\"binary\": \"grep\", \"args\": [\"-i\", \"error\", \"app.log\"]}; arguments are passed as is, with no globbing or variable expansion"),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"binary": map[string]any{"type": "string"},
+					"args":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				},
+				"required": []string{"binary"},
+			}),
+			mcp.MaxItems(maxPipelineStages),
+		),
+		mcp.WithString("stdin",
+			mcp.Description("Text passed on stdin to the first command"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("How long to wait for the pipeline, in seconds (default 60, max 600)"),
+		),
+		withEnvArg(),
+		withAsResourceArg(),
+	)
+	pipelineHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		stages, err := parsePipelineStages(req.Params.Arguments["stages"])
+		if err != nil {
+			return errorResult(err), nil
+		}
+		timeout := 60 * time.Second
+		if secs, ok := req.Params.Arguments["timeout"].(float64); ok {
+			if secs <= 0 || secs > 600 {
+				return invalidParam("invalid timeout parameter: must be between 1 and 600 seconds"), nil
+			}
+			timeout = time.Duration(secs * float64(time.Second))
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		dir, err := workspaceRoot()
+		if err != nil {
+			return errorResult(err), nil
+		}
+		stdin, _ := req.Params.Arguments["stdin"].(string)
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		res, err := runPipeline(ctx, env, dir, stages, stdin)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return toolErrorf(CodeTimeout, "pipeline did not finish within %s", timeout).Result(), nil
+			}
+			return errorResult(err), nil
+		}
+		out, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(pipelineTool, pipelineHandler)
+	toolHandlers["pipeline"] = pipelineHandler
+
+	// --- Register the checksum tool ---
+	checksumTool := mcp.NewTool("checksum",
+		mcp.WithDescription("Compute the checksum of a file in the workspace"),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Path of the file, relative to the workspace root"),
+		),
+		mcp.WithString("algorithm",
+			mcp.Description("Hash algorithm (default sha256)"),
+			mcp.Enum("sha256", "sha1", "md5"),
+		),
+	)
+	checksumHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		p, ok := req.Params.Arguments["path"].(string)
+		if !ok || p == "" {
+			return invalidParam("invalid or missing path parameter"), nil
+		}
+		algorithm, _ := req.Params.Arguments["algorithm"].(string)
+		if algorithm == "" {
+			algorithm = "sha256"
+		}
+		path, err := workspacePath(p)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		sum, size, err := fileChecksum(path, strings.ToLower(algorithm))
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("%s  %s\n(%s, %d bytes)", sum, p, algorithm, size)), nil
+	}
+	mcpServer.AddTool(checksumTool, checksumHandler)
+	toolHandlers["checksum"] = checksumHandler
+
+	// --- Register the watch_file tool ---
+	watchFileTool := mcp.NewTool("watch_file",
+		mcp.WithDescription("Watch a file or directory in the workspace and report the changes (create, write, remove, rename, chmod) seen before the timeout, e.g. to wait for a build artifact or follow a log being written"),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("File or directory to watch, relative to the workspace root; a file need not exist yet. Directories are not watched recursively"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("How long to watch, in seconds (default 30, max 600)"),
+		),
+	)
+	// Events are streamed to the client as they happen; the result holds them all.
+	watchFileHandler := streamingHandler(func(ctx context.Context, req mcp.CallToolRequest, w io.Writer) (*mcp.CallToolResult, error) {
+		p, ok := req.Params.Arguments["path"].(string)
+		if !ok || p == "" {
+			return invalidParam("invalid or missing path parameter"), nil
+		}
+		timeout := 30 * time.Second
+		if secs, ok := req.Params.Arguments["timeout"].(float64); ok {
+			if secs <= 0 || secs > 600 {
+				return invalidParam("invalid timeout parameter: must be between 1 and 600 seconds"), nil
+			}
+			timeout = time.Duration(secs * float64(time.Second))
+		}
+		root, err := workspaceRoot()
+		if err != nil {
+			return errorResult(err), nil
+		}
+		target, err := workspacePath(p)
+		if err != nil {
+			return errorResult(err), nil
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		rep, err := watchFile(ctx, root, target, w)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if len(rep.Events) == 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No changes to %s in %s.", rep.Path, timeout)), nil
+		}
+		out, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	})
+	mcpServer.AddTool(watchFileTool, watchFileHandler)
+	toolHandlers["watch_file"] = ToolHandler(watchFileHandler)
+
+	// --- Register the create_archive and extract_archive tools ---
+	createArchiveTool := mcp.NewTool("create_archive",
+		mcp.WithDescription("Pack a file or directory in the workspace into a .tar.gz archive"),
+		mcp.WithString("source",
+			mcp.Required(),
+			mcp.Description("File or directory to archive, relative to the workspace root"),
+		),
+		mcp.WithString("output",
+			mcp.Required(),
+			mcp.Description("Path of the .tar.gz to write, relative to the workspace root"),
+		),
+	)
+	createArchiveHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		source, ok := req.Params.Arguments["source"].(string)
+		if !ok || source == "" {
+			return invalidParam("invalid or missing source parameter"), nil
+		}
+		output, ok := req.Params.Arguments["output"].(string)
+		if !ok || output == "" {
+			return invalidParam("invalid or missing output parameter"), nil
+		}
+		src, err := workspacePath(source)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		dest, err := workspacePath(output)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		names, err := createArchive(src, dest)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(truncateOutput(fmt.Sprintf("Archived %d entries into %s:\n%s\n", len(names), output, strings.Join(names, "\n")))), nil
+	}
+	mcpServer.AddTool(createArchiveTool, createArchiveHandler)
+	toolHandlers["create_archive"] = createArchiveHandler
+
+	extractArchiveTool := mcp.NewTool("extract_archive",
+		mcp.WithDescription("Unpack a .tar.gz archive in the workspace; entries escaping the destination are rejected"),
+		mcp.WithString("archive",
+			mcp.Required(),
+			mcp.Description("Path of the .tar.gz archive, relative to the workspace root"),
+		),
+		mcp.WithString("destination",
+			mcp.Required(),
+			mcp.Description("Directory to unpack into, relative to the workspace root; created if missing"),
+		),
+	)
+	extractArchiveHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		archive, ok := req.Params.Arguments["archive"].(string)
+		if !ok || archive == "" {
+			return invalidParam("invalid or missing archive parameter"), nil
+		}
+		destination, ok := req.Params.Arguments["destination"].(string)
+		if !ok || destination == "" {
+			return invalidParam("invalid or missing destination parameter"), nil
+		}
+		src, err := workspacePath(archive)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		dest, err := workspacePath(destination)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		names, err := extractArchive(src, dest)
+		if err != nil {
+			return errorResult(fmt.Errorf("extracted %d entries before failing: %w", len(names), err)), nil
+		}
+		return mcp.NewToolResultText(truncateOutput(fmt.Sprintf("Extracted %d entries into %s:\n%s\n", len(names), destination, strings.Join(names, "\n")))), nil
+	}
+	mcpServer.AddTool(extractArchiveTool, extractArchiveHandler)
+	toolHandlers["extract_archive"] = extractArchiveHandler
+
+	// --- Register the render_template tool ---
+	renderTemplateTool := mcp.NewTool("render_template",
+		mcp.WithDescription("Render a Go text/template with variables, e.g. to generate manifests, configs or SQL, returning the result or writing it to a file"),
+		mcp.WithString("template",
+			mcp.Description("Inline template text (e.g., 'replicas: {{.replicas}}'); give this or template_file"),
+		),
+		mcp.WithString("template_file",
+			mcp.Description("Path of a template file, relative to the workspace root; give this or template"),
+		),
+		mcp.WithObject("data",
+			mcp.Description("Variables available to the template as {{.name}}"),
+		),
+		mcp.WithString("output",
+			mcp.Description("Write the result to this path, relative to the workspace root, instead of returning it"),
+		),
+		withAsResourceArg(),
+	)
+	renderTemplateHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		text, _ := req.Params.Arguments["template"].(string)
+		file, _ := req.Params.Arguments["template_file"].(string)
+		if (text == "") == (file == "") {
+			return invalidParam("give exactly one of template or template_file"), nil
+		}
+		data := map[string]any{}
+		if raw, ok := req.Params.Arguments["data"]; ok && raw != nil {
+			if data, ok = raw.(map[string]any); !ok {
+				return invalidParam("invalid data parameter: expected an object"), nil
+			}
+		}
+		name := "template"
+		if file != "" {
+			path, err := workspacePath(file)
+			if err != nil {
+				return errorResult(err), nil
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return errorResult(fmt.Errorf("failed to read template: %w", err)), nil
+			}
+			text, name = string(b), filepath.Base(file)
+		}
+		out, err := renderTemplate(name, text, data)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		output, _ := req.Params.Arguments["output"].(string)
+		if output == "" {
+			return mcp.NewToolResultText(truncateOutput(out)), nil
+		}
+		dest, err := workspacePath(output)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return errorResult(err), nil
+		}
+		if err := os.WriteFile(dest, []byte(out), 0o644); err != nil {
+			return errorResult(fmt.Errorf("failed to write output: %w", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Rendered %d bytes to %s", len(out), output)), nil
+	}
+	mcpServer.AddTool(renderTemplateTool, renderTemplateHandler)
+	toolHandlers["render_template"] = renderTemplateHandler
+
+	// --- Register the convert_format tool ---
+	convertFormatTool := mcp.NewTool("convert_format",
+		mcp.WithDescription("Convert data between JSON, YAML and TOML, e.g. to turn a JSON config into a Kubernetes-style YAML manifest"),
+		mcp.WithString("content",
+			mcp.Description("Inline content to convert; give this or input_file"),
+		),
+		mcp.WithString("input_file",
+			mcp.Description("Path of the file to convert, relative to the workspace root; give this or content"),
+		),
+		mcp.WithString("from",
+			mcp.Description("Format of the input (defaults to the extension of input_file)"),
+			mcp.Enum(dataFormats...),
+		),
+		mcp.WithString("to",
+			mcp.Required(),
+			mcp.Description("Format to convert to"),
+			mcp.Enum(dataFormats...),
+		),
+		mcp.WithString("output",
+			mcp.Description("Write the result to this path, relative to the workspace root, instead of returning it"),
+		),
+		withAsResourceArg(),
+	)
+	convertFormatHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		input, _ := req.Params.Arguments["content"].(string)
+		file, _ := req.Params.Arguments["input_file"].(string)
+		if (input == "") == (file == "") {
+			return invalidParam("give exactly one of content or input_file"), nil
+		}
+		to, _ := req.Params.Arguments["to"].(string)
+		if to == "" {
+			return invalidParam("invalid or missing to parameter"), nil
+		}
+		from, _ := req.Params.Arguments["from"].(string)
+		data := []byte(input)
+		if file != "" {
+			if from == "" {
+				if from = formatFromExt(file); from == "" {
+					return toolErrorf(CodeInvalidParam, "cannot tell the format of %s from its extension; give from", file).Result(), nil
+				}
+			}
+			path, err := workspacePath(file)
+			if err != nil {
+				return errorResult(err), nil
+			}
+			if data, err = os.ReadFile(path); err != nil {
+				return errorResult(fmt.Errorf("failed to read input: %w", err)), nil
+			}
+		}
+		if from == "" {
+			return invalidParam("invalid or missing from parameter"), nil
+		}
+		out, err := convertFormat(data, from, to)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		output, _ := req.Params.Arguments["output"].(string)
+		if output == "" {
+			return mcp.NewToolResultText(truncateOutput(out)), nil
+		}
+		dest, err := workspacePath(output)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return errorResult(err), nil
+		}
+		if err := os.WriteFile(dest, []byte(out), 0o644); err != nil {
+			return errorResult(fmt.Errorf("failed to write output: %w", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Converted %s to %s: wrote %d bytes to %s", from, to, len(out), output)), nil
+	}
+	mcpServer.AddTool(convertFormatTool, convertFormatHandler)
+	toolHandlers["convert_format"] = convertFormatHandler
+
+	// --- Register the validate_manifest tool ---
+	validateManifestTool := mcp.NewTool("validate_manifest",
+		mcp.WithDescription("Check a YAML or JSON manifest before applying it: reports syntax errors and, for Kubernetes, objects missing apiVersion, kind or metadata.name, with the line and column of each problem. Multi-document YAML streams are checked document by document"),
+		mcp.WithString("content",
+			mcp.Description("Inline manifest to check; give this or path"),
+		),
+		mcp.WithString("path",
+			mcp.Description("Path of the manifest, relative to the workspace root; give this or content"),
+		),
+		mcp.WithString("kind",
+			mcp.Description("What the manifest is: kubernetes checks the required fields of every object, yaml and json check syntax only (default kubernetes)"),
+			mcp.Enum(manifestKinds...),
+		),
+	)
+	validateManifestHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, _ := req.Params.Arguments["path"].(string)
+		content, _ := req.Params.Arguments["content"].(string)
+		if (path == "") == (content == "") {
+			return invalidParam("give exactly one of path or content"), nil
+		}
+		kind, _ := req.Params.Arguments["kind"].(string)
+		if kind == "" {
+			kind = "kubernetes"
+		}
+		if !slices.Contains(manifestKinds, kind) {
+			return invalidParam("invalid kind %q: must be one of %s", kind, strings.Join(manifestKinds, ", ")), nil
+		}
+		data := []byte(content)
+		if path != "" {
+			src, err := workspacePath(path)
+			if err != nil {
+				return errorResult(err), nil
+			}
+			if data, err = os.ReadFile(src); err != nil {
+				return errorResult(fmt.Errorf("failed to read manifest: %w", err)), nil
+			}
+		}
+		rep := validateManifest(data, manifestFormat(kind, path, data), kind)
+		out, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(validateManifestTool, validateManifestHandler)
+	toolHandlers["validate_manifest"] = validateManifestHandler
+
+	// --- Register the git_init tool ---
+	gitInitTool := mcp.NewTool("git_init",
+		mcp.WithDescription("Initialize a Git repository in the provided project directory"),
+		mcp.WithString("directory",
+			mcp.Description("Path to the project directory"),
+		),
+		withEnvArg(),
+	)
+	gitInitHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		directory, ok := req.Params.Arguments["directory"].(string)
+		if !ok || directory == "" {
+			return invalidParam("invalid or missing directory parameter"), nil
+		}
+		requestLog(ctx).Debugf("Invoking tool 'git_init' with directory: %s", directory)
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "git", "init", directory)
+		output, err := combinedOutput(cmd)
+		if err != nil {
+			return commandError("failed to initialize git repository", err, string(output)), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	}
+	mcpServer.AddTool(gitInitTool, gitInitHandler)
+	toolHandlers["git_init"] = gitInitHandler
+
+	// --- Register the create_table in Postgres tool ---
+	createTableTool := mcp.NewTool("create_table",
+		mcp.WithDescription("Create a database table in a local Postgres DB instance"),
+		mcp.WithString("table_name",
+			mcp.Description("Name of the table to create"),
+		),
+		mcp.WithString("headers",
+			mcp.Required(),
+			mcp.Description("Comma separated column definitions (e.g., 'id SERIAL PRIMARY KEY, name TEXT')"),
+		),
+		mcp.WithString("values",
+			mcp.Description("Comma separated list of values to insert (e.g., '1, \"John\"')"),
+		),
+		withEnvArg(),
+	)
+	createTableHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tableName, ok := req.Params.Arguments["table_name"].(string)
+		if !ok || tableName == "" {
+			return invalidParam("invalid or missing table_name parameter"), nil
+		}
+		headers, ok := req.Params.Arguments["headers"].(string)
+		if !ok || headers == "" {
+			return invalidParam("invalid or missing headers parameter"), nil
+		}
+		values, ok := req.Params.Arguments["values"].(string)
+		if !ok {
+			return invalidParam("invalid or missing values parameter"), nil
+		}
+		requestLog(ctx).Debugf("Invoking tool 'create_table' with table: %s", tableName)
+		sqlCmd := fmt.Sprintf("CREATE TABLE %s (%s); INSERT INTO %s VALUES (%s);", tableName, headers, tableName, values)
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "psql", "-d", "postgres", "-c", sqlCmd)
+		output, err := combinedOutput(cmd)
+		if err != nil {
+			return commandError("failed to create table", err, string(output)), nil
+		}
+		return mcp.NewToolResultText(string(output)), nil
+	}
+	mcpServer.AddTool(createTableTool, createTableHandler)
+	toolHandlers["create_table"] = createTableHandler
+
+	// --- Register the postgres_table_stats tool ---
+	tableStatsTool := mcp.NewTool("postgres_table_stats",
+		mcp.WithDescription("Report per-table row estimates, total and index size, and last-analyzed time for the local Postgres DB as JSON"),
+		mcp.WithString("schema",
+			mcp.Description("Only report tables in this schema (e.g., 'public')"),
+		),
+		withEnvArg(),
+	)
+	tableStatsHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		schema, _ := req.Params.Arguments["schema"].(string)
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		out, err := runPsql(ctx, env, map[string]string{"schema": schema}, tableStatsSQL)
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to read table stats: %w", err)), nil
+		}
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, out, "", "  "); err != nil {
+			return errorResult(fmt.Errorf("unexpected psql output: %w", err)), nil
+		}
+		return mcp.NewToolResultText(pretty.String()), nil
+	}
+	mcpServer.AddTool(tableStatsTool, tableStatsHandler)
+	toolHandlers["postgres_table_stats"] = tableStatsHandler
+
+	// Register read query using SELECT tool in Sqlite
+
+	readQueryTool := mcp.NewTool("read-query",
+		mcp.WithDescription("Execute a SELECT query on a SQLite DB (returns CSV)"),
+		withDBArg(),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The SELECT SQL to run"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Return at most this many rows"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Skip this many rows before the first one returned"),
+		),
+		mcp.WithBoolean("no_cache",
+			mcp.Description("Bypass the result cache for this call"),
+		),
+		withEnvArg(),
+		withAsResourceArg(),
+	)
+	readQueryHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		db, err := sqliteDB(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		q, _ := req.Params.Arguments["query"].(string)
+		limit, hasLimit, err := nonNegativeIntArg(req.Params.Arguments, "limit")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		offset, hasOffset, err := nonNegativeIntArg(req.Params.Arguments, "offset")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		paged := hasLimit || hasOffset
+		if !hasLimit {
+			limit = -1
+		}
+		if paged {
+			q = pageQuery(q, limit, offset)
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "sqlite3", "-csv", db, q)
+		out, err := combinedOutput(cmd)
+		if err != nil {
+			return commandError("read-query failed", err, string(out)), nil
+		}
+		if !paged {
+			return mcp.NewToolResultText(string(out)), nil
+		}
+		page, hasMore, err := trimPage(string(out), limit)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if hasMore {
+			page += fmt.Sprintf("-- has_more: true (next offset %d)\n", offset+limit)
+		} else {
+			page += "-- has_more: false\n"
+		}
+		return mcp.NewToolResultText(page), nil
+	}
+	mcpServer.AddTool(readQueryTool, readQueryHandler)
+	toolHandlers["read-query"] = readQueryHandler
+
+	// write-query too in Sqlite using INSERT/UPDATE/DELETE
+	writeQueryTool := mcp.NewTool("write-query",
+		mcp.WithDescription("Execute INSERT/UPDATE/DELETE on a SQLite DB"),
+		withDBArg(),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The non-SELECT SQL to run"),
+		),
+		mcp.WithBoolean(allowFullTableArg,
+			mcp.Description("Allow UPDATE or DELETE without a WHERE clause, which affects every row"),
+		),
+		withEnvArg(),
+	)
+	writeQueryHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		db, err := sqliteDB(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		q, _ := req.Params.Arguments["query"].(string)
+		if allow, _ := req.Params.Arguments[allowFullTableArg].(bool); !allow {
+			if err := checkFullTableWrites(q); err != nil {
+				return errorResult(err), nil
+			}
+		}
+		// Run inside the session's transaction when one is open.
+		if session, err := sessionID(ctx); err == nil {
+			if tx := transactions.lookup(session); tx != nil {
+				if abs, _ := filepath.Abs(db); abs != tx.db {
+					return invalidParam("a transaction on %s is open; commit or roll it back before writing to %s", tx.db, db), nil
+				}
+				if out, err := tx.exec(q); err != nil {
+					return commandError("write-query failed", err, out), nil
+				}
+				return mcp.NewToolResultText("OK (in transaction)"), nil
+			}
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "sqlite3", db, q)
+		out, err := combinedOutput(cmd)
+		if err != nil {
+			return commandError("write-query failed", err, string(out)), nil
+		}
+		return mcp.NewToolResultText("OK"), nil
+	}
+	mcpServer.AddTool(writeQueryTool, writeQueryHandler)
+	toolHandlers["write-query"] = writeQueryHandler
+
+	// --- Register the bulk_insert tool ---
+	bulkInsertTool := mcp.NewTool("bulk_insert",
+		mcp.WithDescription("Insert many rows into a SQLite table in one call, atomically"),
+		withDBArg(),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table to insert into"),
+		),
+		mcp.WithArray("columns",
+			mcp.Required(),
+			mcp.Description("Column names, in the order of each row's values"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithArray("rows",
+			mcp.Required(),
+			mcp.Description("Rows to insert, each an array of values (string, number, boolean or null) matching columns"),
+			mcp.Items(map[string]any{"type": "array"}),
+			mcp.MaxItems(maxBulkRows),
+		),
+		withEnvArg(),
+	)
+	bulkInsertHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		db, err := sqliteDB(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		table, ok := req.Params.Arguments["table"].(string)
+		if !ok || table == "" {
+			return invalidParam("invalid or missing table parameter"), nil
+		}
+		rawCols, _ := req.Params.Arguments["columns"].([]any)
+		var columns []string
+		for _, v := range rawCols {
+			col, ok := v.(string)
+			if !ok || strings.TrimSpace(col) == "" {
+				return invalidParam("invalid columns parameter: expected a list of column names"), nil
+			}
+			columns = append(columns, strings.TrimSpace(col))
+		}
+		if len(columns) == 0 {
+			return invalidParam("invalid or missing columns parameter"), nil
+		}
+		rawRows, _ := req.Params.Arguments["rows"].([]any)
+		if len(rawRows) == 0 {
+			return invalidParam("invalid or missing rows parameter"), nil
+		}
+		if len(rawRows) > maxBulkRows {
+			return invalidParam("too many rows: %d (at most %d per call)", len(rawRows), maxBulkRows), nil
+		}
+		rows := make([][]any, len(rawRows))
+		for i, r := range rawRows {
+			if rows[i], ok = r.([]any); !ok {
+				return invalidParam("invalid rows parameter: row %d is not an array", i+1), nil
+			}
+		}
+		stmts, err := bulkInsertSQL(table, columns, rows)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		done := mcp.NewToolResultText(fmt.Sprintf("Inserted %d rows into '%s'.", len(rows), table))
+
+		// Inside an open transaction the rows commit or roll back with it.
+		if session, err := sessionID(ctx); err == nil {
+			if tx := transactions.lookup(session); tx != nil {
+				if abs, _ := filepath.Abs(db); abs != tx.db {
+					return invalidParam("a transaction on %s is open; commit or roll it back before writing to %s", tx.db, db), nil
+				}
+				if out, err := tx.exec(stmts); err != nil {
+					return commandError("bulk_insert failed", err, out), nil
+				}
+				return done, nil
+			}
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		// -bail stops at the first error, leaving the transaction to be
+		// rolled back when sqlite3 exits.
+		cmd := toolCommand(ctx, env, "sqlite3", "-bail", "-batch", db)
+		cmd.Stdin = strings.NewReader("BEGIN;\n" + stmts + "COMMIT;\n")
+		out, err := combinedOutput(cmd)
+		if err != nil {
+			return commandError("bulk_insert failed; no rows were inserted", err, string(out)), nil
+		}
+		return done, nil
+	}
+	mcpServer.AddTool(bulkInsertTool, bulkInsertHandler)
+	toolHandlers["bulk_insert"] = bulkInsertHandler
+
+	// --- Register the run_sql_file tool ---
+	runSQLFileTool := mcp.NewTool("run_sql_file",
+		mcp.WithDescription("Run every statement of a .sql file in the workspace in one transaction, on a SQLite DB (db) or a Postgres database (dsn). Returns the statements executed and rows affected; if a statement fails the transaction is rolled back and the error names the failing statement"),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Path of the .sql file, relative to the workspace root"),
+		),
+		mcp.WithString("db",
+			mcp.Description("Path to the SQLite .db file (default MCP_SQLITE_DB); give this or dsn"),
+		),
+		mcp.WithString("dsn",
+			mcp.Description("Postgres database name or connection string, e.g. postgres://user@host:5432/app; give this or db"),
+		),
+		mcp.WithBoolean(allowFullTableArg,
+			mcp.Description("Allow UPDATE or DELETE without a WHERE clause, which affects every row"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Seconds to wait for the script (default 60, max 600); on timeout nothing is committed"),
+		),
+		withEnvArg(),
+	)
+	runSQLFileHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path, ok := req.Params.Arguments["path"].(string)
+		if !ok || path == "" {
+			return invalidParam("invalid or missing path parameter"), nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".sql") {
+			return invalidParam("invalid path parameter: %s is not a .sql file", path), nil
+		}
+		dsn, _ := req.Params.Arguments["dsn"].(string)
+		if db, _ := req.Params.Arguments["db"].(string); db != "" && dsn != "" {
+			return invalidParam("give either db or dsn, not both"), nil
+		}
+		dialect, target := "postgres", dsn
+		if dsn == "" {
+			db, err := sqliteDB(req.Params.Arguments)
+			if err != nil {
+				return invalidParam("give db (a SQLite file) or dsn (a Postgres database); no MCP_SQLITE_DB default is set"), nil
+			}
+			dialect, target = "sqlite", db
+		}
+		timeout := 60 * time.Second
+		if secs, ok := req.Params.Arguments["timeout"].(float64); ok {
+			if secs <= 0 || secs > 600 {
+				return invalidParam("invalid timeout parameter: must be between 1 and 600 seconds"), nil
+			}
+			timeout = time.Duration(secs * float64(time.Second))
+		}
+		src, err := workspacePath(path)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to read SQL file: %w", err)), nil
+		}
+		stmts, err := splitSQLScript(string(data), dialect)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if len(stmts) == 0 {
+			return invalidParam("%s holds no SQL statements", path), nil
+		}
+		if allow, _ := req.Params.Arguments[allowFullTableArg].(bool); !allow {
+			if err := checkFullTableWrites(string(data)); err != nil {
+				return errorResult(err), nil
+			}
+		}
+		// The script brings its own transaction, which cannot nest in the
+		// session's.
+		if session, err := sessionID(ctx); err == nil && dialect == "sqlite" {
+			if tx := transactions.lookup(session); tx != nil {
+				return invalidParam("a transaction on %s is open; commit or roll it back before running %s", tx.db, path), nil
+			}
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		newToolLog(ctx, req).Infof("Running %d statements from %s on %s", len(stmts), path, dialect)
+		run := runSQLiteScript
+		if dialect == "postgres" {
+			run = runPostgresScript
+		}
+		rows, failed, err := run(ctx, env, target, stmts)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return toolErrorf(CodeTimeout, "%s did not finish within %s; the transaction was rolled back", path, timeout).Result(), nil
+		}
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if failed != nil {
+			te := toolErrorf(CodeCommandFailed, "statement %d (line %d) of %s failed: %s; the transaction was rolled back", failed.Index, failed.Line, path, failed.Message)
+			if failed.Index == 0 {
+				te = toolErrorf(CodeCommandFailed, "committing %s failed: %s; the transaction was rolled back", path, failed.Message)
+			}
+			te.Details = map[string]any{
+				"failed_statement": failed.Index,
+				"line":             failed.Line,
+				"executed":         failed.Executed,
+				"statements":       len(stmts),
+			}
+			return te.Result(), nil
+		}
+		res := sqlScriptResult{Dialect: dialect, Path: path, Statements: len(stmts), Executed: len(rows), Rows: rows}
+		for _, n := range rows {
+			res.RowsAffected += n
+		}
+		out, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(runSQLFileTool, runSQLFileHandler)
+	toolHandlers["run_sql_file"] = runSQLFileHandler
+
+	// --- Register the SQLite transaction tools ---
+	beginTxTool := mcp.NewTool("begin_transaction",
+		mcp.WithDescription("Open a transaction on a SQLite DB for this session; write-query calls run inside it until commit_transaction or rollback_transaction"),
+		withDBArg(),
+		withEnvArg(),
+	)
+	beginTxHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		db, err := sqliteDB(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		session, err := sessionID(ctx)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		abs, err := filepath.Abs(db)
+		if err != nil {
+			return errorResult(fmt.Errorf("invalid db path: %w", err)), nil
+		}
+		if err := transactions.begin(session, abs, env); err != nil {
+			return errorResult(fmt.Errorf("failed to begin transaction: %w", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Transaction open on %s; it is rolled back after %s without activity.", abs, transactions.timeout)), nil
+	}
+	mcpServer.AddTool(beginTxTool, beginTxHandler)
+	toolHandlers["begin_transaction"] = beginTxHandler
+
+	commitTxTool := mcp.NewTool("commit_transaction",
+		mcp.WithDescription("Commit this session's open SQLite transaction"),
+	)
+	commitTxHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		session, err := sessionID(ctx)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := transactions.finish(session, true); err != nil {
+			return errorResult(fmt.Errorf("failed to commit transaction: %w", err)), nil
+		}
+		return mcp.NewToolResultText("Transaction committed"), nil
+	}
+	mcpServer.AddTool(commitTxTool, commitTxHandler)
+	toolHandlers["commit_transaction"] = commitTxHandler
+
+	rollbackTxTool := mcp.NewTool("rollback_transaction",
+		mcp.WithDescription("Roll back this session's open SQLite transaction"),
+	)
+	rollbackTxHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		session, err := sessionID(ctx)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if err := transactions.finish(session, false); err != nil {
+			return errorResult(fmt.Errorf("failed to roll back transaction: %w", err)), nil
+		}
+		return mcp.NewToolResultText("Transaction rolled back"), nil
+	}
+	mcpServer.AddTool(rollbackTxTool, rollbackTxHandler)
+	toolHandlers["rollback_transaction"] = rollbackTxHandler
+
+	//  create-table tool in Sqlite wraps write-query for a CREATE TABLE statement
+	createSQLTableTool := mcp.NewTool("create-SQLtable",
+		mcp.WithDescription("Create a new table in the SQLite DB"),
+		withDBArg(),
+		mcp.WithString("definition",
+			mcp.Required(),
+			mcp.Description("SQL table definition, e.g. `CREATE TABLE users(id INTEGER PRIMARY KEY, name TEXT);`"),
+		),
+		withEnvArg(),
+	)
+	createSQLTableHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		db, err := sqliteDB(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		def, _ := req.Params.Arguments["definition"].(string)
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "sqlite3", db, def)
+		out, err := combinedOutput(cmd)
+		if err != nil {
+			return commandError("create-table failed", err, string(out)), nil
+		}
+		return mcp.NewToolResultText("Table created"), nil
+	}
+	mcpServer.AddTool(createSQLTableTool, createSQLTableHandler)
+	toolHandlers["create-table"] = createSQLTableHandler
+
+	// list-tables tool in Sqlite query
+	listTablesTool := mcp.NewTool("list-tables",
+		mcp.WithDescription("List all tables in the SQLite DB"),
+		withDBArg(),
+		mcp.WithBoolean("no_cache",
+			mcp.Description("Bypass the result cache for this call"),
+		),
+		withEnvArg(),
+	)
+	listTablesHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		db, err := sqliteDB(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		sql := `SELECT name FROM sqlite_master WHERE type='table' ORDER BY name;`
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "sqlite3", "-csv", db, sql)
+		out, err := combinedOutput(cmd)
+		if err != nil {
+			return commandError("list-tables failed", err, string(out)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("'%v' DB contains '%v' table.", db, string(out))), nil
+	}
+	mcpServer.AddTool(listTablesTool, listTablesHandler)
+	toolHandlers["list-tables"] = listTablesHandler
+
+	// sqlite_schema tool returns the CREATE DDL stored in sqlite_master
+	sqliteSchemaTool := mcp.NewTool("sqlite_schema",
+		mcp.WithDescription("Show the CREATE statements of the tables, indexes, views and triggers in the SQLite DB"),
+		withDBArg(),
+		mcp.WithString("table",
+			mcp.Description("Only show the table with this name and its indexes and triggers"),
+		),
+		withEnvArg(),
+	)
+	sqliteSchemaHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		db, err := sqliteDB(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		table, _ := req.Params.Arguments["table"].(string)
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		cmd := toolCommand(ctx, env, "sqlite3", "-readonly", db, schemaQuery(table))
+		out, err := combinedOutput(cmd)
+		if err != nil {
+			return commandError("sqlite_schema failed", err, string(out)), nil
+		}
+		if strings.TrimSpace(string(out)) == "" {
+			if table != "" {
+				return mcp.NewToolResultText(fmt.Sprintf("No table named '%s' in '%s'.", table, db)), nil
+			}
+			return mcp.NewToolResultText(fmt.Sprintf("'%s' contains no schema objects.", db)), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(sqliteSchemaTool, sqliteSchemaHandler)
+	toolHandlers["sqlite_schema"] = sqliteSchemaHandler
+
+	// --- Register the validate_sql tool ---
+	validateSQLTool := mcp.NewTool("validate_sql",
+		mcp.WithDescription("Check a SQL statement without executing it: reports whether the syntax is valid, the statement type and the tables it references"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The SQL statement to check"),
+		),
+		mcp.WithString("dialect",
+			mcp.Required(),
+			mcp.Description("SQL dialect of the statement"),
+			mcp.Enum("sqlite", "postgres"),
+		),
+		mcp.WithString("db",
+			mcp.Description("Path to the SQLite .db file to check against, so missing tables and columns are reported (sqlite only)"),
+		),
+		withEnvArg(),
+	)
+	validateSQLHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		q, ok := req.Params.Arguments["query"].(string)
+		if !ok || strings.TrimSpace(q) == "" {
+			return invalidParam("invalid or missing query parameter"), nil
+		}
+		dialect, _ := req.Params.Arguments["dialect"].(string)
+		if dialect != "sqlite" && dialect != "postgres" {
+			return invalidParam("invalid or missing dialect parameter: must be 'sqlite' or 'postgres'"), nil
+		}
+		if multipleStatements(q) {
+			return invalidParam("validate_sql checks one statement at a time"), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+
+		check := sqlCheck{Dialect: dialect}
+		check.StatementType, check.Tables = describeSQL(q)
+		if dialect == "sqlite" {
+			db, _ := sqliteDB(req.Params.Arguments)
+			check.Error, err = checkSQLite(ctx, db, q, env)
+		} else {
+			check.Error, err = checkPostgres(ctx, check.StatementType, q, env)
+		}
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to validate SQL: %w", err)), nil
+		}
+		check.SyntaxValid = !isSyntaxError(check.Error)
+
+		out, err := json.MarshalIndent(check, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(validateSQLTool, validateSQLHandler)
+	toolHandlers["validate_sql"] = validateSQLHandler
+
+	// --- Register the format_sql tool ---
+	formatSQLTool := mcp.NewTool("format_sql",
+		mcp.WithDescription("Pretty-print SQL and report risky patterns such as SELECT * or UPDATE/DELETE without WHERE; nothing is executed"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The SQL to format; may hold several statements"),
+		),
+		mcp.WithString("dialect",
+			mcp.Description("SQL dialect of the query (default sqlite)"),
+			mcp.Enum("sqlite", "postgres"),
+		),
+		mcp.WithBoolean("lint",
+			mcp.Description("Report lint warnings (default true)"),
+		),
+	)
+	formatSQLHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		q, ok := req.Params.Arguments["query"].(string)
+		if !ok || strings.TrimSpace(q) == "" {
+			return invalidParam("invalid or missing query parameter"), nil
+		}
+		dialect, _ := req.Params.Arguments["dialect"].(string)
+		if dialect == "" {
+			dialect = "sqlite"
+		}
+		if dialect != "sqlite" && dialect != "postgres" {
+			return invalidParam("invalid dialect parameter: must be 'sqlite' or 'postgres'"), nil
+		}
+		res := sqlFormat{Dialect: dialect, Formatted: formatSQL(q, dialect), Warnings: []string{}}
+		if lint, ok := req.Params.Arguments["lint"].(bool); !ok || lint {
+			res.Warnings = append(res.Warnings, lintSQL(q)...)
+		}
+		out, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(formatSQLTool, formatSQLHandler)
+	toolHandlers["format_sql"] = formatSQLHandler
+
+	// --- Register the create_index tool ---
+	createIndexTool := mcp.NewTool("create_index",
+		mcp.WithDescription("Create an index on a table in a SQLite DB or the local Postgres DB"),
+		mcp.WithString("dialect",
+			mcp.Required(),
+			mcp.Description("Database to create the index in"),
+			mcp.Enum("sqlite", "postgres"),
+		),
+		mcp.WithString("db",
+			mcp.Description("Path to the SQLite .db file (sqlite only; defaults to MCP_SQLITE_DB)"),
+		),
+		mcp.WithString("table",
+			mcp.Required(),
+			mcp.Description("Table to index, optionally schema-qualified for Postgres (e.g., 'public.users')"),
+		),
+		mcp.WithArray("columns",
+			mcp.Required(),
+			mcp.Description("Columns to index, in order, each optionally followed by ASC or DESC"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithBoolean("unique",
+			mcp.Description("Create a UNIQUE index"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Name of the index (default idx_<table>_<columns>)"),
+		),
+		withEnvArg(),
+	)
+	createIndexHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		dialect, _ := req.Params.Arguments["dialect"].(string)
+		if dialect != "sqlite" && dialect != "postgres" {
+			return invalidParam("invalid or missing dialect parameter: must be 'sqlite' or 'postgres'"), nil
+		}
+		table, ok := req.Params.Arguments["table"].(string)
+		if !ok || table == "" {
+			return invalidParam("invalid or missing table parameter"), nil
+		}
+		raw, _ := req.Params.Arguments["columns"].([]any)
+		var columns []string
+		for _, v := range raw {
+			col, ok := v.(string)
+			if !ok || strings.TrimSpace(col) == "" {
+				return invalidParam("invalid columns parameter: expected a list of column names"), nil
+			}
+			columns = append(columns, strings.TrimSpace(col))
+		}
+		if len(columns) == 0 {
+			return invalidParam("invalid or missing columns parameter"), nil
+		}
+		unique, _ := req.Params.Arguments["unique"].(bool)
+		name, _ := req.Params.Arguments["name"].(string)
+		if name == "" {
+			name = defaultIndexName(table, columns)
+		}
+		var db string
+		if dialect == "sqlite" {
+			var err error
+			if db, err = sqliteDB(req.Params.Arguments); err != nil {
+				return errorResult(err), nil
+			}
+		}
+		stmt, err := createIndexSQL(table, name, columns, unique)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		requestLog(ctx).Debugf("Invoking tool 'create_index': %s", stmt)
+		if err := createIndex(ctx, dialect, db, stmt, env); err != nil {
+			if err == errIndexExists {
+				return mcp.NewToolResultText(fmt.Sprintf("Index '%s' already exists; nothing to do.", name)), nil
+			}
+			return errorResult(err), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Created index '%s' on '%s'.", name, table)), nil
+	}
+	mcpServer.AddTool(createIndexTool, createIndexHandler)
+	toolHandlers["create_index"] = createIndexHandler
+
+	// --- Register the get_secret tool ---
+	secrets := serverSecrets()
+	getSecretTool := mcp.NewTool("get_secret",
+		mcp.WithDescription("Check that a credential, such as a database password or registry token, is in the server's secret store and return a reference to it (secret://<key>). Pass the reference as a value of another tool's env argument; the server replaces it with the secret, whose value is never returned or logged. Only keys allowed by the server's MCP_SECRET_KEYS can be read"),
+		mcp.WithString("key",
+			mcp.Required(),
+			mcp.Description("Key of the secret, e.g. db_password or registry.token"),
+		),
+	)
+	getSecretHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		key, ok := req.Params.Arguments["key"].(string)
+		if !ok || key == "" {
+			return invalidParam("invalid or missing key parameter"), nil
+		}
+		if _, err := secrets.get(key); err != nil {
+			requestLog(ctx).Warnf("Refused to read a secret: %v", err)
+			return errorResult(err), nil
+		}
+		requestLog(ctx).Infof("Handed out a reference to secret %q from the %s backend", key, secrets.backend)
+		return mcp.NewToolResultText(secretRefScheme + key), nil
+	}
+	mcpServer.AddTool(getSecretTool, getSecretHandler)
+	toolHandlers["get_secret"] = getSecretHandler
+
+	// --- Register the backend ping tools ---
+	dockerPingTool := mcp.NewTool("docker_ping",
+		mcp.WithDescription("Check that the Docker daemon is reachable and report the latency"),
+	)
+	dockerPingHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		start := time.Now()
+		detail, err := pingDocker(ctx)
+		return pingResult("Docker daemon", time.Since(start), detail, err), nil
+	}
+	mcpServer.AddTool(dockerPingTool, dockerPingHandler)
+	toolHandlers["docker_ping"] = dockerPingHandler
+
+	postgresPingTool := mcp.NewTool("postgres_ping",
+		mcp.WithDescription("Check that the local Postgres DB accepts connections and report the latency"),
+		withEnvArg(),
+	)
+	postgresPingHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		start := time.Now()
+		version, err := runPsql(ctx, env, nil, "SHOW server_version;")
+		return pingResult("Postgres", time.Since(start), "server version "+string(version), err), nil
+	}
+	mcpServer.AddTool(postgresPingTool, postgresPingHandler)
+	toolHandlers["postgres_ping"] = postgresPingHandler
+
+	sqlitePingTool := mcp.NewTool("sqlite_ping",
+		mcp.WithDescription("Check that a SQLite DB file opens and report the latency"),
+		withDBArg(),
+		withEnvArg(),
+	)
+	sqlitePingHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		db, err := sqliteDB(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if _, err := os.Stat(db); err != nil {
+			return pingResult("SQLite DB", 0, "", err), nil
+		}
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		start := time.Now()
+		detail, err := pingSQLite(ctx, db, env)
+		return pingResult("SQLite DB", time.Since(start), detail, err), nil
+	}
+	mcpServer.AddTool(sqlitePingTool, sqlitePingHandler)
+	toolHandlers["sqlite_ping"] = sqlitePingHandler
+
+	k8sPingTool := mcp.NewTool("k8s_ping",
+		mcp.WithDescription("Check that the Kubernetes API server is responsive and report the latency"),
+		withKubeconfigArg(),
+		withContextArg(),
+		withEnvArg(),
+	)
+	k8sPingHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		env, err := commandEnv(req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		kubeFlags, err := kubectlFlags(req.Params.Arguments)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		start := time.Now()
+		detail, err := pingKubernetes(ctx, kubeFlags, env)
+		return pingResult("Kubernetes API server", time.Since(start), detail, err), nil
+	}
+	mcpServer.AddTool(k8sPingTool, k8sPingHandler)
+	toolHandlers["k8s_ping"] = k8sPingHandler
+
+	// --- Register the list_tools tool ---
+	listToolsTool := mcp.NewTool("list_tools",
+		mcp.WithDescription("List this server's tools with their descriptions and input schemas, for clients that do not use MCP discovery"),
+		mcp.WithString("name",
+			mcp.Description("Only describe the tool with this name"),
+		),
+	)
+	listToolsHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tools, err := registeredTools(ctx, mcpServer)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		if name, _ := req.Params.Arguments["name"].(string); name != "" {
+			i := slices.IndexFunc(tools, func(t mcp.Tool) bool { return t.Name == name })
+			if i < 0 {
+				return toolErrorf(CodeNotFound, "no tool named %q", name).Result(), nil
+			}
+			tools = tools[i : i+1]
+		}
+		out, err := json.MarshalIndent(map[string]any{"tools": tools}, "", "  ")
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to encode tools: %w", err)), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(listToolsTool, listToolsHandler)
+	toolHandlers["list_tools"] = listToolsHandler
+
+	// --- Register the describe_tool tool ---
+	describeToolTool := mcp.NewTool("describe_tool",
+		mcp.WithDescription("Describe one tool in full: its description, each argument's type, whether it is required, allowed values, and example calls"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the tool to describe (e.g., 'read-query')"),
+		),
+	)
+	describeToolHandler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, ok := req.Params.Arguments["name"].(string)
+		if !ok || name == "" {
+			return invalidParam("invalid or missing name parameter"), nil
+		}
+		tools, err := registeredTools(ctx, mcpServer)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		i := slices.IndexFunc(tools, func(t mcp.Tool) bool { return t.Name == name })
+		if i < 0 {
+			return toolErrorf(CodeNotFound, "no tool named %q", name).Result(), nil
+		}
+		out, err := json.MarshalIndent(describeTool(tools[i]), "", "  ")
+		if err != nil {
+			return errorResult(fmt.Errorf("failed to encode tool description: %w", err)), nil
+		}
+		return mcp.NewToolResultText(string(out)), nil
+	}
+	mcpServer.AddTool(describeToolTool, describeToolHandler)
+	toolHandlers["describe_tool"] = describeToolHandler
+
+	// Setup the Server
+
+	if *stdio {
+		// The host that spawned us talks over stdin/stdout; logs go to stderr.
+		log.Infof("▶️  Starting MCP server on stdio ...")
+		if err := server.ServeStdio(mcpServer); err != nil {
+			log.Fatalf("❌  Stdio server failed: %v", err)
+		}
+		return
+	}
+
+	addr := ":1234"
+	baseURL := "http://localhost:1234"
+	socketPath := os.Getenv("MCP_SOCKET")
+	if socketPath != "" {
+		// Over a Unix socket the host is never dialed; clients only need the path.
+		baseURL = "http://unix"
+	}
+	// sse := server.NewSSEServer(mcpServer, server.WithMessageEndpoint("/rpc"), server.WithSSEEndpoint("/sse"))
+	sseServer := server.NewSSEServer(mcpServer, server.WithBaseURL(baseURL), server.WithMessageEndpoint("/rpc"), server.WithSSEEndpoint("/sse"), server.WithHTTPServer(&http.Server{
+		Addr: addr,
+	}),
+		// Periodic pings let clients tell an idle stream from a dead one.
+		server.WithKeepAliveInterval(envDuration("MCP_SSE_KEEPALIVE", 15*time.Second)),
+		server.WithHTTPContextFunc(httpContext),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.Handle("/ws", wsHandler(mcpServer))
+	mux.Handle("/", sseServer)
+
+	// mux := http.NewServeMux()
+	// mux.Handle("/sse", sse.SSEHandler())
+	// mux.Handle("/rpc", sse.MessageHandler())
+	//
+	if socketPath != "" {
+		ln, err := listenUnix(socketPath)
+		if err != nil {
+			log.Fatalf("❌  Failed to listen on %s: %v", socketPath, err)
+		}
+		log.Printf("▶️  Starting MCP HTTP/SSE server 1 on unix://%s ...", socketPath)
+		if err := http.Serve(ln, httpgzip.Handler(mux)); err != nil {
+			log.Fatalf("❌  Failed to start server1: %v", err)
+		}
+		return
+	}
+
+	log.Printf("▶️  Starting MCP HTTP/SSE server 1 on %s ...", addr)
+	if err := http.ListenAndServe(addr, httpgzip.Handler(mux)); err != nil {
+		log.Fatalf("❌  Failed to start server1: %v", err)
+	}
+}
+
+// listenUnix listens on a Unix domain socket, replacing a stale socket file
+// left behind by a previous run. The socket is only accessible to its owner.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %v", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %v", err)
 	}
+	return ln, nil
 }
 
 func handleNotification(ctx context.Context, notification mcp.JSONRPCNotification) {
-	fmt.Printf("Received notification from client: %s\n", notification.Method)
+	requestLog(ctx).Debugf("Received notification from client: %s", notification.Method)
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	log "github.com/sirupsen/logrus"
+)
+
+// envArg is the per-call argument carrying extra environment variables for
+// shell-based tools.
+const envArg = "env"
+
+// withEnvArg declares the optional env argument on a shell-based tool.
+func withEnvArg() mcp.ToolOption {
+	return mcp.WithObject(envArg,
+		mcp.Description("Extra environment variables for the command, e.g. {\"KUBECONFIG\": \"/path/to/config\"}. Only names listed in the server's MCP_TOOL_ENV_ALLOW are accepted. A secret:// reference returned by get_secret is replaced by the secret's value."),
+		mcp.AdditionalProperties(map[string]any{"type": "string"}),
+	)
+}
+
+// allowedEnvKeys returns the variable names tools may set through the env
+// argument, read from the comma-separated MCP_TOOL_ENV_ALLOW. When it is
+// unset no passthrough is allowed.
+func allowedEnvKeys() map[string]bool {
+	allowed := make(map[string]bool)
+	for _, k := range strings.Split(os.Getenv("MCP_TOOL_ENV_ALLOW"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			allowed[k] = true
+		}
+	}
+	return allowed
+}
+
+// commandEnv returns the environment for the child process of a tool call:
+// the server's own environment with the call's env argument merged on top,
+// secret references expanded. It returns nil, meaning inherit, when the call sets no variables.
+func commandEnv(req mcp.CallToolRequest) ([]string, error) {
+	raw, ok := req.Params.Arguments[envArg]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	vars, ok := raw.(map[string]any)
+	if !ok {
+		return nil, toolErrorf(CodeInvalidParam, "invalid %s parameter: expected an object of string values", envArg)
+	}
+	if len(vars) == 0 {
+		return nil, nil
+	}
+
+	allowed := allowedEnvKeys()
+	keys := make([]string, 0, len(vars))
+	for k, v := range vars {
+		if !allowed[k] {
+			return nil, toolErrorf(CodePermissionDenied, "environment variable %q is not allowed (see MCP_TOOL_ENV_ALLOW)", k)
+		}
+		if _, ok := v.(string); !ok {
+			return nil, toolErrorf(CodeInvalidParam, "invalid value for environment variable %q: expected a string", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Values often hold credentials (PGPASSWORD, tokens), so only names are logged.
+	log.WithFields(log.Fields{
+		"tool": req.Params.Name,
+		"env":  strings.Join(keys, ","),
+	}).Info("Passing extra environment to tool (values redacted)")
+
+	env := os.Environ()
+	for _, k := range keys {
+		v, err := serverSecrets().expand(vars[k].(string))
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, k+"="+v)
+	}
+	return env, nil
+}
+
+// toolCommand builds the child process of a tool call, run with env (nil
+// inherits the server's environment) and killed when ctx is done. With
+// MCP_DEBUG_COMMANDS set, the command line is logged first, secrets
+// redacted, so a failing call can be reproduced by hand.
+func toolCommand(ctx context.Context, env []string, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	if os.Getenv("MCP_DEBUG_COMMANDS") != "" {
+		requestLog(ctx).WithField("command", shellJoin(redactArgs(cmd.Args))).Info("Running command")
+	}
+	return cmd
+}
+
+// maxCommandOutput is the most output, stdout and stderr together, read
+// from one child process, from MCP_MAX_COMMAND_OUTPUT_BYTES (default 64
+// MiB). It guards the server's memory; results are capped further by
+// truncateOutput.
+func maxCommandOutput() int {
+	return envInt("MCP_MAX_COMMAND_OUTPUT_BYTES", 64<<20)
+}
+
+// boundedOutput collects the output of one command. Once more than limit
+// bytes have been written the process is killed and further output dropped.
+type boundedOutput struct {
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	limit    int
+	written  int
+	exceeded bool
+}
+
+// boundedWriter is one stream of a boundedOutput.
+type boundedWriter struct {
+	out *boundedOutput
+	buf *bytes.Buffer
+}
+
+func (w boundedWriter) Write(p []byte) (int, error) {
+	o := w.out
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.exceeded {
+		return len(p), nil
+	}
+	if room := o.limit - o.written; o.limit > 0 && len(p) > room {
+		w.buf.Write(p[:room])
+		o.written = o.limit
+		o.exceeded = true
+		if o.cmd.Process != nil {
+			o.cmd.Process.Kill()
+		}
+		return len(p), nil
+	}
+	w.buf.Write(p)
+	o.written += len(p)
+	return len(p), nil
+}
+
+// runCommand runs cmd, collecting its stdout and stderr (which may be the
+// same buffer) up to maxCommandOutput. A command producing more is killed
+// and a RESOURCE_EXHAUSTED error returned, with the output read so far left
+// in the buffers.
+func runCommand(cmd *exec.Cmd, stdout, stderr *bytes.Buffer) error {
+	out := &boundedOutput{cmd: cmd, limit: maxCommandOutput()}
+	cmd.Stdout = boundedWriter{out: out, buf: stdout}
+	cmd.Stderr = boundedWriter{out: out, buf: stderr}
+	err := cmd.Run()
+	if out.exceeded {
+		return toolErrorf(CodeResourceExhausted, "%s produced more than %d bytes of output and was stopped (see MCP_MAX_COMMAND_OUTPUT_BYTES)", filepath.Base(cmd.Path), out.limit)
+	}
+	return err
+}
+
+// combinedOutput is cmd.CombinedOutput with the output bounded as by
+// runCommand.
+func combinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	var b bytes.Buffer
+	err := runCommand(cmd, &b, &b)
+	return b.Bytes(), err
+}
+
+// outputResult is the result of a command that succeeded. Tools whose
+// commands report warnings on stderr use it instead of returning stdout
+// alone: when stderr is not empty the result holds two content blocks, one
+// headed "stdout:" and one "stderr:", so callers can tell them apart.
+// Otherwise it is stdout as a single block, as before.
+func outputResult(stdout, stderr string) *mcp.CallToolResult {
+	if strings.TrimSpace(stderr) == "" {
+		return mcp.NewToolResultText(truncateOutput(stdout))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent("stdout:\n" + truncateOutput(stdout)),
+			mcp.NewTextContent("stderr:\n" + truncateOutput(stderr)),
+		},
+	}
+}
+
+// secretName matches option and variable names whose values are secrets.
+var secretName = regexp.MustCompile(`(?i)(pass(word|wd)?|secret|token|api[-_]?key|credential|authorization)`)
+
+// redactArgs masks secrets in argv: the value of a --password style option
+// (as "--password=x" or "--password x"), NAME=value pairs with a secret
+// name, and the password of URLs such as postgres://user:pw@host/db.
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		switch {
+		case i > 0 && strings.HasPrefix(args[i-1], "-") && !strings.Contains(args[i-1], "=") && secretName.MatchString(args[i-1]):
+			a = "***"
+		case strings.Contains(a, "://"):
+			a = urlPassword.ReplaceAllString(a, "$1:***@")
+		default:
+			if k, _, ok := strings.Cut(a, "="); ok && secretName.MatchString(k) {
+				a = k + "=***"
+			}
+		}
+		out[i] = a
+	}
+	return out
+}
+
+// redactArguments masks secrets in tool arguments: every value of the env
+// argument, arguments with a secret name and the password of URLs.
+func redactArguments(args map[string]any) map[string]any {
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		switch {
+		case k == envArg:
+			if vars, ok := v.(map[string]any); ok {
+				masked := make(map[string]any, len(vars))
+				for name := range vars {
+					masked[name] = "***"
+				}
+				v = masked
+			}
+		case secretName.MatchString(k):
+			v = "***"
+		default:
+			if s, ok := v.(string); ok && strings.Contains(s, "://") {
+				v = urlPassword.ReplaceAllString(s, "$1:***@")
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+var urlPassword = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]+):[^@/\s]*@`)
+
+// shellJoin quotes args for a POSIX shell, for pasting into a terminal.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a != "" && strings.Trim(a, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+			quoted[i] = a
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// requireBinary checks that an external tool is installed before running it.
+func requireBinary(name string) error {
+	if _, err := exec.LookPath(name); err != nil {
+		return toolErrorf(CodeUpstreamUnavailable, "%s is not installed or not on PATH", name)
+	}
+	return nil
+}
+
+// maxOutputBytes is the most tool output returned in one result, from
+// MCP_MAX_OUTPUT_BYTES (default 256 KiB).
+func maxOutputBytes() int {
+	return envInt("MCP_MAX_OUTPUT_BYTES", 256<<10)
+}
+
+// truncateOutput caps command output at maxOutputBytes, noting how much was
+// cut.
+func truncateOutput(out string) string {
+	max := maxOutputBytes()
+	if max <= 0 || len(out) <= max {
+		return out
+	}
+	return out[:max] + fmt.Sprintf("\n... [output truncated: %d of %d bytes shown]\n", max, len(out))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// fileSnapshot is the content of one file before a rewrite, plus a hash of
+// its content right after, to notice later edits.
+type fileSnapshot struct {
+	path      string
+	mode      os.FileMode
+	original  []byte
+	rewritten [sha256.Size]byte
+}
+
+// rewriteSnapshot holds the files touched by one rewrite operation.
+type rewriteSnapshot struct {
+	files []fileSnapshot
+	timer *time.Timer
+}
+
+// snapshotStore keeps the original content of files changed by rewrites so
+// they can be undone. Snapshots are dropped after ttl.
+type snapshotStore struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	ops map[string]*rewriteSnapshot
+}
+
+// newSnapshotStoreFromEnv builds the store with the TTL taken from
+// MCP_UNDO_TTL (default 1h).
+func newSnapshotStoreFromEnv() *snapshotStore {
+	return &snapshotStore{
+		ttl: envDuration("MCP_UNDO_TTL", time.Hour),
+		ops: make(map[string]*rewriteSnapshot),
+	}
+}
+
+// capture reads the files a rewrite is about to modify.
+func (s *snapshotStore) capture(paths []string) (*rewriteSnapshot, error) {
+	snap := &rewriteSnapshot{}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := os.Stat(abs)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			return nil, err
+		}
+		snap.files = append(snap.files, fileSnapshot{path: abs, mode: fi.Mode().Perm(), original: data})
+	}
+	return snap, nil
+}
+
+// save records the content the files have after the rewrite and stores the
+// snapshot, returning its operation id.
+func (s *snapshotStore) save(snap *rewriteSnapshot) string {
+	for i := range snap.files {
+		if data, err := os.ReadFile(snap.files[i].path); err == nil {
+			snap.files[i].rewritten = sha256.Sum256(data)
+		}
+	}
+	id := uuid.New().String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap.timer = time.AfterFunc(s.ttl, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.ops[id] == snap {
+			delete(s.ops, id)
+			log.Debugf("Dropped expired rewrite snapshot %s", id)
+		}
+	})
+	s.ops[id] = snap
+	return id
+}
+
+// restore writes back the original content of the files of operation id.
+// Files edited since the rewrite are conflicts: unless force is set nothing
+// is restored and they are returned, and the snapshot is kept.
+func (s *snapshotStore) restore(id string, force bool) (restored, conflicts []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap, ok := s.ops[id]
+	if !ok {
+		return nil, nil, toolErrorf(CodeNotFound, "no snapshot for operation %s (unknown id, already undone, or older than %s)", id, s.ttl)
+	}
+
+	for _, f := range snap.files {
+		data, err := os.ReadFile(f.path)
+		if err != nil || sha256.Sum256(data) != f.rewritten {
+			conflicts = append(conflicts, f.path)
+		}
+	}
+	if len(conflicts) > 0 && !force {
+		return nil, conflicts, nil
+	}
+
+	for _, f := range snap.files {
+		if err := os.WriteFile(f.path, f.original, f.mode); err != nil {
+			return restored, conflicts, fmt.Errorf("failed to restore %s: %w", f.path, err)
+		}
+		restored = append(restored, f.path)
+	}
+	snap.timer.Stop()
+	delete(s.ops, id)
+	return restored, conflicts, nil
+}
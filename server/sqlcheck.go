@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+// sqlCheck is the result of the validate_sql tool.
+type sqlCheck struct {
+	Dialect       string   `json:"dialect"`
+	StatementType string   `json:"statement_type"`
+	Tables        []string `json:"tables"`
+	SyntaxValid   bool     `json:"syntax_valid"`
+	// Error is the engine's complaint, if any. It is set for semantic
+	// problems such as a missing table even when the syntax is valid.
+	Error string `json:"error,omitempty"`
+}
+
+// sqlFormat is the result of the format_sql tool.
+type sqlFormat struct {
+	Dialect   string   `json:"dialect"`
+	Formatted string   `json:"formatted"`
+	Warnings  []string `json:"warnings"`
+}
+
+// sqlTokens splits a statement into keywords, identifiers and punctuation,
+// dropping comments and string literals. Quoted identifiers keep their name
+// without the quotes.
+func sqlTokens(stmt string) []string {
+	var tokens []string
+	r := []rune(stmt)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < len(r) && r[i+1] == '-':
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			for i += 2; i+1 < len(r) && !(r[i] == '*' && r[i+1] == '/'); i++ {
+			}
+			i += 2
+		case c == '\'':
+			// String literal; '' is an escaped quote.
+			for i++; i < len(r); i++ {
+				if r[i] == '\'' {
+					if i+1 < len(r) && r[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+			tokens = append(tokens, "'")
+		case c == '"' || c == '`' || c == '[':
+			closing := map[rune]rune{'"': '"', '`': '`', '[': ']'}[c]
+			j := i + 1
+			for j < len(r) && r[j] != closing {
+				j++
+			}
+			tokens = append(tokens, string(r[i+1:min(j, len(r))]))
+			i = j + 1
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(r) && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || r[j] == '_' || r[j] == '$' || r[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, string(r[i:j]))
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens
+}
+
+// describeSQL returns the statement type (SELECT, INSERT, ...) and the tables
+// a statement references, from a lexical scan. CTE names are not reported.
+func describeSQL(stmt string) (string, []string) {
+	return describeTokens(sqlTokens(stmt))
+}
+
+// describeTokens is describeSQL for a statement already split by sqlTokens.
+// Tokens must not be joined and lexed again: a string literal is reduced to
+// a lone "'", which would open a new literal on the second pass.
+func describeTokens(tokens []string) (string, []string) {
+	if len(tokens) == 0 {
+		return "", nil
+	}
+	typ := strings.ToUpper(tokens[0])
+
+	var tables []string
+	seen := map[string]bool{}
+	ctes := map[string]bool{}
+	depth := 0
+	for i, tok := range tokens {
+		switch tok {
+		case "(":
+			depth++
+			continue
+		case ")":
+			depth--
+			continue
+		}
+		kw := strings.ToUpper(tok)
+		// WITH name AS (...): the statement type is the first top-level
+		// keyword after the CTEs.
+		if typ == "WITH" && depth == 0 && i > 0 {
+			switch kw {
+			case "SELECT", "INSERT", "UPDATE", "DELETE":
+				typ = kw
+			}
+		}
+		if kw == "AS" && i > 0 && i+1 < len(tokens) && tokens[i+1] == "(" {
+			ctes[strings.ToLower(tokens[i-1])] = true
+		}
+
+		var name string
+		switch kw {
+		case "FROM", "JOIN", "INTO", "UPDATE", "TABLE":
+			j := i + 1
+			for j < len(tokens) && isSQLModifier(tokens[j]) {
+				j++
+			}
+			if j < len(tokens) && isSQLIdentifier(tokens[j]) {
+				name = tokens[j]
+			}
+		}
+		if name == "" || ctes[strings.ToLower(name)] || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		tables = append(tables, name)
+	}
+	if typ == "WITH" {
+		typ = "SELECT"
+	}
+	return typ, tables
+}
+
+// multipleStatements reports whether anything but a trailing semicolon
+// follows the first statement.
+func multipleStatements(stmt string) bool {
+	tokens := sqlTokens(stmt)
+	for i, tok := range tokens {
+		if tok == ";" && i < len(tokens)-1 {
+			for _, rest := range tokens[i+1:] {
+				if rest != ";" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// allowFullTableArg lets write-query run UPDATE or DELETE without WHERE.
+const allowFullTableArg = "allow_full_table"
+
+// checkFullTableWrites refuses a script holding an UPDATE or DELETE without
+// a WHERE clause, which would touch every row of its table.
+func checkFullTableWrites(sql string) error {
+	for _, tokens := range sqlStatements(sqlTokens(sql)) {
+		if typ, ok := unboundedWrite(tokens); ok {
+			return toolErrorf(CodeInvalidParam,
+				"refusing to run %s without WHERE: it would affect every row of the table; add a WHERE clause, or pass %s: true if that is intended",
+				typ, allowFullTableArg)
+		}
+	}
+	return nil
+}
+
+// isSQLModifier reports whether tok may sit between a table keyword and the
+// table name, as in "CREATE TABLE IF NOT EXISTS t" or "DELETE FROM ONLY t".
+func isSQLModifier(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "IF", "NOT", "EXISTS", "ONLY", "LATERAL", "OR", "REPLACE", "IGNORE", "ABORT", "FAIL", "ROLLBACK":
+		return true
+	}
+	return false
+}
+
+func isSQLIdentifier(tok string) bool {
+	switch strings.ToUpper(tok) {
+	case "", "'", "SELECT", "SET", "VALUES", "DEFAULT":
+		return false
+	}
+	c := []rune(tok)[0]
+	return unicode.IsLetter(c) || c == '_'
+}
+
+// explainable lists the Postgres statement types EXPLAIN accepts.
+var explainable = map[string]bool{
+	"SELECT": true, "INSERT": true, "UPDATE": true, "DELETE": true,
+	"VALUES": true, "MERGE": true, "WITH": true,
+}
+
+// checkSQLite compiles stmt with EXPLAIN, which prepares it without running
+// it, against db opened read-only (or an empty in-memory DB).
+func checkSQLite(ctx context.Context, db, stmt string, env []string) (string, error) {
+	if db == "" {
+		db = ":memory:"
+	}
+	cmd := toolCommand(ctx, env, "sqlite3", "-readonly", db, "EXPLAIN "+stmt)
+	out, err := combinedOutput(cmd)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", fmt.Errorf("failed to run sqlite3: %v", err)
+	}
+	if msg := sqliteError(string(out)); msg != "" {
+		return msg, nil
+	}
+	if err != nil {
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", nil
+}
+
+// pgSyntaxTag is the dollar-quote tag wrapping statements in checkPostgres.
+const pgSyntaxTag = "$mcp_validate$"
+
+// checkPostgres checks stmt without executing it: with EXPLAIN when the
+// statement type supports it, otherwise by compiling it inside a DO block
+// that returns before reaching it, which checks syntax only.
+func checkPostgres(ctx context.Context, typ, stmt string, env []string) (string, error) {
+	stmt = strings.TrimRight(strings.TrimSpace(stmt), ";")
+	var sql string
+	if explainable[typ] {
+		sql = "EXPLAIN " + stmt + ";"
+	} else {
+		if strings.Contains(stmt, pgSyntaxTag) {
+			return "", fmt.Errorf("statement must not contain %s", pgSyntaxTag)
+		}
+		sql = fmt.Sprintf("DO %s BEGIN RETURN; %s; END %s;", pgSyntaxTag, stmt, pgSyntaxTag)
+	}
+	if _, err := runPsql(ctx, env, nil, sql); err != nil {
+		msg := strings.TrimPrefix(err.Error(), "psql failed: ")
+		if _, rest, ok := strings.Cut(msg, "ERROR:"); ok {
+			msg = strings.TrimSpace(rest)
+		}
+		return msg, nil
+	}
+	return "", nil
+}
+
+// isSyntaxError reports whether an engine error is about syntax rather than,
+// say, a missing table.
+func isSyntaxError(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, s := range []string{"syntax error", "incomplete input", "unrecognized token", "unterminated"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
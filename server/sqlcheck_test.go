@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestCheckFullTableWrites(t *testing.T) {
+	tests := []struct {
+		sql     string
+		refused bool
+	}{
+		{"DELETE FROM users", true},
+		{"DELETE FROM users WHERE id = 1", false},
+		{"UPDATE users SET active = 0", true},
+		{"UPDATE users SET active = 0 WHERE id = 1;", false},
+
+		// CTEs: a WHERE inside the CTE does not bound the outer write.
+		{"WITH x AS (SELECT 'a') DELETE FROM users", true},
+		{"WITH x AS (SELECT id FROM t WHERE a = 1) DELETE FROM users", true},
+		{"WITH x AS (SELECT 'a') DELETE FROM users WHERE id IN (SELECT * FROM x)", false},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", false},
+
+		// String literals hide keywords and semicolons.
+		{"UPDATE users SET name = 'WHERE'", true},
+		{"UPDATE users SET name = 'it''s; WHERE id = 1'", true},
+		{"UPDATE users SET note = '--' WHERE id = 1", false},
+		{"SELECT 'DELETE FROM users'", false},
+		{"INSERT INTO log VALUES ('x'); DELETE FROM users", true},
+
+		// Comments hide keywords too.
+		{"DELETE FROM users -- WHERE id = 1", true},
+		{"DELETE FROM users /* WHERE id = 1 */", true},
+		{"DELETE FROM users /* no filter yet */ WHERE id = 1", false},
+		{"-- DELETE FROM users\nSELECT 1", false},
+	}
+	for _, tt := range tests {
+		err := checkFullTableWrites(tt.sql)
+		if refused := err != nil; refused != tt.refused {
+			t.Errorf("checkFullTableWrites(%q) = %v, want refused %v", tt.sql, err, tt.refused)
+		}
+	}
+}
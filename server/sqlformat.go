@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// sqlLexeme is a token of a SQL script as written, including literals and
+// comments, so the script can be reassembled by formatSQL.
+type sqlLexeme struct {
+	text    string
+	word    bool // keyword or bare identifier
+	comment bool
+}
+
+// sqlLex splits a script into lexemes, keeping string literals, quoted
+// identifiers and comments intact. Postgres dollar quoting and $n parameters
+// are recognized for the postgres dialect; brackets quote identifiers only
+// in SQLite.
+func sqlLex(stmt, dialect string) []sqlLexeme {
+	var out []sqlLexeme
+	r := []rune(stmt)
+	// scanTo returns the index just past the first occurrence of end at or
+	// after i, or len(r) when the script ends first.
+	scanTo := func(i int, end string) int {
+		e := []rune(end)
+		for j := i; j+len(e) <= len(r); j++ {
+			if string(r[j:j+len(e)]) == end {
+				return j + len(e)
+			}
+		}
+		return len(r)
+	}
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < len(r) && r[i+1] == '-':
+			j := i
+			for j < len(r) && r[j] != '\n' {
+				j++
+			}
+			out = append(out, sqlLexeme{text: strings.TrimRight(string(r[i:j]), " \t\r"), comment: true})
+			i = j
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			j := scanTo(i+2, "*/")
+			out = append(out, sqlLexeme{text: string(r[i:j]), comment: true})
+			i = j
+		case c == '\'':
+			j := i + 1
+			for ; j < len(r); j++ {
+				if r[j] == '\'' {
+					if j+1 < len(r) && r[j+1] == '\'' {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			j = min(j+1, len(r))
+			// E'..', X'..' and similar prefixed literals stay one lexeme.
+			if n := len(out); n > 0 && out[n-1].word && len(out[n-1].text) == 1 && i > 0 && !unicode.IsSpace(r[i-1]) {
+				out[n-1] = sqlLexeme{text: out[n-1].text + string(r[i:j])}
+			} else {
+				out = append(out, sqlLexeme{text: string(r[i:j])})
+			}
+			i = j
+		case c == '"' || c == '`' || (c == '[' && dialect == "sqlite"):
+			closing := map[rune]string{'"': `"`, '`': "`", '[': "]"}[c]
+			j := scanTo(i+1, closing)
+			out = append(out, sqlLexeme{text: string(r[i:j]), word: true})
+			i = j
+		case c == '$' && dialect == "postgres" && i+1 < len(r) && unicode.IsDigit(r[i+1]):
+			j := i + 1
+			for j < len(r) && unicode.IsDigit(r[j]) {
+				j++
+			}
+			out = append(out, sqlLexeme{text: string(r[i:j]), word: true})
+			i = j
+		case c == '$' && dialect == "postgres":
+			// $tag$ ... $tag$; the tag may be empty.
+			j := i + 1
+			for j < len(r) && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || r[j] == '_') {
+				j++
+			}
+			if j >= len(r) || r[j] != '$' {
+				out = append(out, sqlLexeme{text: "$"})
+				i++
+				continue
+			}
+			tag := string(r[i : j+1])
+			end := scanTo(j+1, tag)
+			out = append(out, sqlLexeme{text: string(r[i:end])})
+			i = end
+		case unicode.IsLetter(c) || c == '_' || unicode.IsDigit(c):
+			j := i
+			for j < len(r) && (unicode.IsLetter(r[j]) || unicode.IsDigit(r[j]) || r[j] == '_' || r[j] == '$' ||
+				(r[j] == '.' && unicode.IsDigit(c))) {
+				j++
+			}
+			out = append(out, sqlLexeme{text: string(r[i:j]), word: !unicode.IsDigit(c)})
+			i = j
+		default:
+			n := 1
+			for _, op := range []string{"->>", "::", "<=", ">=", "<>", "!=", "||", "->", "=="} {
+				if strings.HasPrefix(string(r[i:min(i+3, len(r))]), op) {
+					n = len(op)
+					break
+				}
+			}
+			out = append(out, sqlLexeme{text: string(r[i : i+n])})
+			i += n
+		}
+	}
+	return out
+}
+
+// sqlKeywords are uppercased by formatSQL; other words keep their case.
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, kw := range strings.Fields(`
+		ADD ALL ALTER AND AS ASC BEGIN BETWEEN BY CASE CAST CHECK COLLATE COLUMN
+		COMMIT CONFLICT CONSTRAINT CREATE CROSS DEFAULT DELETE DESC DISTINCT DO
+		DROP ELSE END EXCEPT EXISTS EXPLAIN FOREIGN FROM FULL GLOB GROUP HAVING
+		IF ILIKE IN INDEX INNER INSERT INTERSECT INTO IS JOIN KEY LEFT LIKE LIMIT
+		NATURAL NOT NOTHING NULL OFFSET ON OR ORDER OUTER OVER PARTITION PRAGMA
+		PRIMARY REFERENCES REPLACE RETURNING RIGHT ROLLBACK SELECT SET TABLE THEN
+		TRIGGER UNION UNIQUE UPDATE USING VALUES VIEW WHEN WHERE WITH`) {
+		sqlKeywords[kw] = true
+	}
+}
+
+// joinModifiers may precede JOIN and start the join clause themselves.
+var joinModifiers = map[string]bool{
+	"LEFT": true, "RIGHT": true, "INNER": true, "FULL": true, "CROSS": true, "NATURAL": true, "OUTER": true,
+}
+
+// sqlFrame is a parenthesized level of a statement. Clauses only start new
+// lines in query frames (the statement itself and subqueries), so function
+// arguments and column lists stay on one line.
+type sqlFrame struct {
+	query  bool
+	indent int
+	clause string
+}
+
+// sqlFormatter assembles formatted SQL line by line.
+type sqlFormatter struct {
+	b         strings.Builder
+	lineStart bool
+	lineInd   int
+	prev      string
+}
+
+func (f *sqlFormatter) newline(indent int) {
+	if f.b.Len() > 0 && !f.lineStart {
+		f.b.WriteString("\n")
+	}
+	f.b.WriteString(strings.Repeat(" ", indent))
+	f.lineStart, f.lineInd = true, indent
+}
+
+func (f *sqlFormatter) write(text string, space bool) {
+	if space && !f.lineStart {
+		f.b.WriteString(" ")
+	}
+	f.b.WriteString(text)
+	f.lineStart = false
+	f.prev = text
+}
+
+// formatSQL pretty-prints a script: keywords are uppercased, each clause of
+// a query starts its own line, select lists get one column per line and
+// AND/OR conditions are indented under their clause.
+func formatSQL(stmt, dialect string) string {
+	lx := sqlLex(stmt, dialect)
+	upper := func(i int) string {
+		if i < 0 || i >= len(lx) || !lx[i].word {
+			return ""
+		}
+		return strings.ToUpper(lx[i].text)
+	}
+
+	f := &sqlFormatter{lineStart: true}
+	stack := []sqlFrame{{query: true}}
+	between := false
+	for i, t := range lx {
+		top := &stack[len(stack)-1]
+		up := upper(i)
+		text := t.text
+		if sqlKeywords[up] {
+			text = up
+		}
+		prevUp := strings.ToUpper(f.prev)
+
+		switch {
+		case t.comment:
+			f.write(text, true)
+			if strings.HasPrefix(text, "--") {
+				f.newline(f.lineInd)
+			}
+		case t.word && top.query && isClauseStart(up, prevUp, upper(i+1)):
+			f.newline(top.indent)
+			top.clause = up
+			f.write(text, false)
+		case (up == "AND" || up == "OR") && top.query && top.clause != "SELECT":
+			if up == "AND" && between {
+				between = false
+				f.write(text, true)
+				break
+			}
+			f.newline(top.indent + 2)
+			f.write(text, false)
+		case text == "(":
+			prev := f.prev
+			sub := upper(i+1) == "SELECT" || upper(i+1) == "WITH"
+			fn := prev != "" && !sqlKeywords[prevUp] && (unicode.IsLetter([]rune(prev)[0]) || prev[0] == '"' || prev[0] == '_')
+			f.write(text, !fn && prev != "(" && prev != "::")
+			frame := sqlFrame{query: sub, indent: top.indent}
+			if sub {
+				frame.indent = f.lineInd + 2
+			}
+			stack = append(stack, frame)
+		case text == ")":
+			if len(stack) > 1 {
+				closed := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if closed.query {
+					f.newline(closed.indent - 2)
+				}
+			}
+			f.write(text, false)
+		case text == ",":
+			f.write(text, false)
+			if top.query && top.clause == "SELECT" {
+				f.newline(top.indent + 2)
+			}
+		case text == ";":
+			f.write(text, false)
+			stack = []sqlFrame{{query: true}}
+			if i < len(lx)-1 {
+				f.b.WriteString("\n")
+				f.newline(0)
+				f.prev = ""
+			}
+		default:
+			if up == "BETWEEN" {
+				between = true
+			}
+			f.write(text, f.prev != "(" && f.prev != "." && f.prev != "::" && text != "." && text != "::")
+		}
+	}
+	return strings.TrimSpace(f.b.String()) + "\n"
+}
+
+// isClauseStart reports whether word begins a new clause of a query, given
+// the words before and after it.
+func isClauseStart(word, prev, next string) bool {
+	switch word {
+	case "SELECT", "WHERE", "HAVING", "LIMIT", "OFFSET", "UNION", "EXCEPT", "INTERSECT", "VALUES", "SET", "RETURNING":
+		return true
+	case "FROM":
+		return prev != "DELETE" && prev != "DISTINCT"
+	case "GROUP", "ORDER":
+		return next == "BY"
+	case "JOIN":
+		return !joinModifiers[prev]
+	}
+	return joinModifiers[word] && !joinModifiers[prev] && (next == "JOIN" || joinModifiers[next])
+}
+
+// sqlStatements splits the tokens of a script (see sqlTokens) into
+// statements, dropping empty ones.
+func sqlStatements(tokens []string) [][]string {
+	var stmts [][]string
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i == len(tokens) || tokens[i] == ";" {
+			if i > start {
+				stmts = append(stmts, tokens[start:i])
+			}
+			start = i + 1
+		}
+	}
+	return stmts
+}
+
+// topLevel reports whether any of the keywords appears in tokens outside
+// parentheses.
+func topLevel(tokens []string, keywords ...string) bool {
+	depth := 0
+	for _, tok := range tokens {
+		switch tok {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		default:
+			if depth == 0 {
+				for _, kw := range keywords {
+					if strings.EqualFold(tok, kw) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// unboundedWrite returns UPDATE or DELETE when the statement is one that
+// lacks a WHERE clause and so touches every row of its table.
+func unboundedWrite(tokens []string) (string, bool) {
+	typ, _ := describeTokens(tokens)
+	if typ != "UPDATE" && typ != "DELETE" {
+		return "", false
+	}
+	return typ, !topLevel(tokens, "WHERE")
+}
+
+// lintSQL reports risky patterns in a script, one warning per finding.
+func lintSQL(stmt string) []string {
+	var warnings []string
+	stmts := sqlStatements(sqlTokens(stmt))
+	for n, tokens := range stmts {
+		prefix := ""
+		if len(stmts) > 1 {
+			prefix = fmt.Sprintf("statement %d: ", n+1)
+		}
+		warn := func(msg string) { warnings = append(warnings, prefix+msg) }
+
+		if typ, ok := unboundedWrite(tokens); ok {
+			warn(typ + " without WHERE affects every row of the table")
+		}
+		for i, tok := range tokens {
+			if tok != "*" || i == 0 {
+				continue
+			}
+			p := strings.ToUpper(tokens[i-1])
+			if p == "SELECT" || p == "DISTINCT" || p == "," || strings.HasSuffix(p, ".") {
+				warn("SELECT * returns every column; list the columns you need")
+				break
+			}
+		}
+		if topLevel(tokens, "LIMIT") && !topLevel(tokens, "ORDER") {
+			warn("LIMIT without ORDER BY returns an arbitrary subset of rows")
+		}
+		if strings.EqualFold(tokens[0], "INSERT") {
+			for i, tok := range tokens {
+				if strings.EqualFold(tok, "INTO") && i+2 < len(tokens) {
+					if next := strings.ToUpper(tokens[i+2]); next == "VALUES" || next == "SELECT" {
+						warn("INSERT without a column list breaks when the table's columns change")
+					}
+					break
+				}
+			}
+		}
+	}
+	return warnings
+}
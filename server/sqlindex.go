@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sqlIdentPattern matches the plain identifiers create_index accepts; table
+// names may be schema-qualified.
+var sqlIdentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// quoteIdent validates a possibly dotted identifier and double-quotes each
+// part, so it is used verbatim and case-sensitively.
+func quoteIdent(name string) (string, error) {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return "", toolErrorf(CodeInvalidParam, "invalid identifier %q", name)
+	}
+	for i, p := range parts {
+		if !sqlIdentPattern.MatchString(p) {
+			return "", toolErrorf(CodeInvalidParam, "invalid identifier %q: use letters, digits and underscores", name)
+		}
+		parts[i] = `"` + p + `"`
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// indexColumn quotes a column of an index, keeping an optional ASC or DESC
+// suffix.
+func indexColumn(col string) (string, error) {
+	fields := strings.Fields(col)
+	if len(fields) == 0 || len(fields) > 2 || strings.Contains(fields[0], ".") {
+		return "", toolErrorf(CodeInvalidParam, "invalid column %q", col)
+	}
+	quoted, err := quoteIdent(fields[0])
+	if err != nil {
+		return "", err
+	}
+	if len(fields) == 2 {
+		dir := strings.ToUpper(fields[1])
+		if dir != "ASC" && dir != "DESC" {
+			return "", toolErrorf(CodeInvalidParam, "invalid column %q: only ASC or DESC may follow the name", col)
+		}
+		quoted += " " + dir
+	}
+	return quoted, nil
+}
+
+// defaultIndexName names an index after its table and columns, e.g.
+// idx_users_last_name_first_name.
+func defaultIndexName(table string, columns []string) string {
+	parts := []string{"idx", table[strings.LastIndex(table, ".")+1:]}
+	for _, c := range columns {
+		parts = append(parts, strings.Fields(c)[0])
+	}
+	return strings.Join(parts, "_")
+}
+
+// createIndexSQL builds the CREATE INDEX statement. In Postgres the index
+// always lives in its table's schema, so only the table name is qualified.
+func createIndexSQL(table, name string, columns []string, unique bool) (string, error) {
+	qTable, err := quoteIdent(table)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(name, ".") {
+		return "", toolErrorf(CodeInvalidParam, "invalid index name %q: it takes the schema of its table", name)
+	}
+	qName, err := quoteIdent(name)
+	if err != nil {
+		return "", err
+	}
+	cols := make([]string, len(columns))
+	for i, c := range columns {
+		if cols[i], err = indexColumn(c); err != nil {
+			return "", err
+		}
+	}
+	kind := "INDEX"
+	if unique {
+		kind = "UNIQUE INDEX"
+	}
+	return fmt.Sprintf("CREATE %s %s ON %s (%s);", kind, qName, qTable, strings.Join(cols, ", ")), nil
+}
+
+// errIndexExists is returned by createIndex when the index name is taken.
+var errIndexExists = toolErrorf(CodeInvalidParam, "index already exists")
+
+// createIndex runs a CREATE INDEX statement on SQLite (db) or Postgres.
+func createIndex(ctx context.Context, dialect, db, stmt string, env []string) error {
+	var msg string
+	if dialect == "sqlite" {
+		cmd := toolCommand(ctx, env, "sqlite3", "-batch", db, stmt)
+		out, err := combinedOutput(cmd)
+		if err == nil {
+			return nil
+		}
+		if msg = sqliteError(string(out)); msg == "" {
+			return fmt.Errorf("sqlite3 failed: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	} else {
+		_, err := runPsql(ctx, env, nil, stmt)
+		if err == nil {
+			return nil
+		}
+		msg = err.Error()
+	}
+	if strings.Contains(msg, "already exists") {
+		return errIndexExists
+	}
+	return toolErrorf(CodeCommandFailed, "failed to create index: %s", msg)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultSQLiteDB is the database SQLite tools use when a call has no db
+// argument, from MCP_SQLITE_DB.
+func defaultSQLiteDB() string {
+	return cleanPath(strings.TrimSpace(os.Getenv("MCP_SQLITE_DB")))
+}
+
+// withDBArg declares the db argument of the SQLite tools. It is required
+// unless MCP_SQLITE_DB provides a default.
+func withDBArg() mcp.ToolOption {
+	if def := defaultSQLiteDB(); def != "" {
+		return mcp.WithString("db",
+			mcp.Description(fmt.Sprintf("Path to the .db file (default %s)", def)),
+		)
+	}
+	return mcp.WithString("db",
+		mcp.Required(),
+		mcp.Description("Path to the .db file"),
+	)
+}
+
+// sqliteDB returns the db argument of a call, falling back to MCP_SQLITE_DB.
+func sqliteDB(args map[string]any) (string, error) {
+	if db, _ := args["db"].(string); db != "" {
+		return db, nil
+	}
+	if def := defaultSQLiteDB(); def != "" {
+		return def, nil
+	}
+	return "", toolErrorf(CodeInvalidParam, "invalid or missing db parameter (no MCP_SQLITE_DB default is set)")
+}
+
+// nonNegativeIntArg reads an optional non-negative integer argument. ok is
+// false when the argument is absent.
+func nonNegativeIntArg(args map[string]any, name string) (n int, ok bool, err error) {
+	raw, present := args[name]
+	if !present || raw == nil {
+		return 0, false, nil
+	}
+	f, isNum := raw.(float64)
+	if !isNum || f < 0 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return 0, false, toolErrorf(CodeInvalidParam, "invalid %s parameter: must be a non-negative integer", name)
+	}
+	return int(f), true, nil
+}
+
+// pageQuery wraps a SELECT so it returns one page of rows. It asks for one
+// row more than the limit so the caller can tell whether more rows follow.
+// A negative limit means no limit.
+func pageQuery(q string, limit, offset int) string {
+	q = strings.TrimRight(strings.TrimSpace(q), ";")
+	if limit >= 0 {
+		limit++
+	}
+	return fmt.Sprintf("SELECT * FROM (%s) LIMIT %d OFFSET %d;", q, limit, offset)
+}
+
+// trimPage drops the extra row fetched by pageQuery from sqlite3's CSV output
+// and reports whether it was there.
+func trimPage(out string, limit int) (string, bool, error) {
+	if limit < 0 {
+		return out, false, nil
+	}
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to parse query output: %v", err)
+	}
+	if len(records) <= limit {
+		return out, false, nil
+	}
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.WriteAll(records[:limit])
+	return b.String(), true, w.Error()
+}
+
+// sqlQuote returns s as a SQL string literal.
+func sqlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// schemaQuery selects the CREATE statements of tables, then their indexes,
+// views and triggers, optionally only those belonging to table.
+func schemaQuery(table string) string {
+	where := "sql IS NOT NULL"
+	if table != "" {
+		where += " AND tbl_name = " + sqlQuote(table)
+	}
+	return "SELECT sql || ';' FROM sqlite_master WHERE " + where +
+		" ORDER BY tbl_name, CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 ELSE 2 END, name;"
+}
+
+// bulkInsertBatch is the number of rows per INSERT statement of bulk_insert,
+// and maxBulkRows the most rows one call may insert.
+const (
+	bulkInsertBatch = 500
+	maxBulkRows     = 10000
+)
+
+// sqlLiteral renders a JSON value as a SQLite literal. Whole numbers are
+// written as integers so they keep INTEGER affinity.
+func sqlLiteral(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return strconv.FormatInt(int64(v), 10), nil
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case string:
+		return sqlQuote(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v: expected a string, number, boolean or null", v)
+	}
+}
+
+// bulkInsertSQL builds multi-row INSERT statements for rows, bulkInsertBatch
+// rows at a time. Each row must have one value per column.
+func bulkInsertSQL(table string, columns []string, rows [][]any) (string, error) {
+	qTable, err := quoteIdent(table)
+	if err != nil {
+		return "", err
+	}
+	qCols := make([]string, len(columns))
+	for i, c := range columns {
+		if strings.Contains(c, ".") {
+			return "", toolErrorf(CodeInvalidParam, "invalid column %q", c)
+		}
+		if qCols[i], err = quoteIdent(c); err != nil {
+			return "", err
+		}
+	}
+	head := fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", qTable, strings.Join(qCols, ", "))
+
+	var b strings.Builder
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return "", toolErrorf(CodeInvalidParam, "row %d has %d values, expected %d", i+1, len(row), len(columns))
+		}
+		vals := make([]string, len(row))
+		for j, v := range row {
+			if vals[j], err = sqlLiteral(v); err != nil {
+				return "", toolErrorf(CodeInvalidParam, "row %d, column %s: %v", i+1, columns[j], err)
+			}
+		}
+		switch {
+		case i%bulkInsertBatch == 0:
+			b.WriteString(head)
+		default:
+			b.WriteString(",\n")
+		}
+		b.WriteString("(" + strings.Join(vals, ", ") + ")")
+		if i%bulkInsertBatch == bulkInsertBatch-1 || i == len(rows)-1 {
+			b.WriteString(";\n")
+		}
+	}
+	return b.String(), nil
+}
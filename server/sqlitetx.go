@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// txStatementTimeout bounds how long a single statement inside a transaction
+// may run; an unterminated statement would otherwise block forever.
+const txStatementTimeout = time.Minute
+
+// sqliteTx is an open SQLite transaction. SQLite transactions live as long
+// as the connection, so each one keeps its own sqlite3 process running and
+// feeds it statements over stdin.
+type sqliteTx struct {
+	mu       sync.Mutex
+	db       string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	out      *bufio.Reader
+	sentinel string
+	timer    *time.Timer
+}
+
+// startSQLiteTx starts sqlite3 on db and opens a transaction in it.
+func startSQLiteTx(db string, env []string) (*sqliteTx, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd := toolCommand(context.Background(), env, "sqlite3", "-batch", db)
+	// Errors go to stderr; sharing one pipe keeps them in order with output.
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		pr.Close()
+		pw.Close()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		pr.Close()
+		pw.Close()
+		return nil, fmt.Errorf("failed to start sqlite3: %v", err)
+	}
+	pw.Close()
+
+	tx := &sqliteTx{
+		db:       db,
+		cmd:      cmd,
+		stdin:    stdin,
+		out:      bufio.NewReader(pr),
+		sentinel: "__mcp_tx_" + strings.ReplaceAll(uuid.New().String(), "-", "") + "__",
+	}
+	if _, err := tx.exec("BEGIN;"); err != nil {
+		tx.close()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// exec runs sql inside the transaction and returns its output. Statement
+// errors are returned as errors; the transaction stays open.
+func (tx *sqliteTx) exec(sql string) (string, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	// The lone ";" terminates a statement missing its semicolon so the
+	// .print marking the end of the output is read as a command.
+	if _, err := fmt.Fprintf(tx.stdin, "%s\n;\n.print %s\n", sql, tx.sentinel); err != nil {
+		return "", fmt.Errorf("transaction connection lost: %v", err)
+	}
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var b strings.Builder
+		for {
+			line, err := tx.out.ReadString('\n')
+			if strings.TrimSpace(line) == tx.sentinel {
+				done <- result{out: b.String()}
+				return
+			}
+			b.WriteString(line)
+			if err != nil {
+				done <- result{out: b.String(), err: fmt.Errorf("transaction connection lost: %v", err)}
+				return
+			}
+		}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.out, r.err
+		}
+		if msg := sqliteError(r.out); msg != "" {
+			return r.out, toolErrorf(CodeCommandFailed, "%s", msg)
+		}
+		return r.out, nil
+	case <-time.After(txStatementTimeout):
+		tx.cmd.Process.Kill()
+		return "", toolErrorf(CodeTimeout, "statement did not finish within %s; transaction aborted", txStatementTimeout)
+	}
+}
+
+// sqliteError returns the first error sqlite3 reported in out, if any.
+func sqliteError(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		for _, prefix := range []string{"Parse error", "Runtime error", "Error:"} {
+			if strings.HasPrefix(line, prefix) {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+	return ""
+}
+
+// close ends the sqlite3 process. A transaction still open at that point is
+// rolled back by SQLite.
+func (tx *sqliteTx) close() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.stdin.Close()
+	if err := tx.cmd.Wait(); err != nil {
+		log.Debugf("sqlite3 for %s exited: %v", tx.db, err)
+	}
+}
+
+// txManager tracks the open transaction of each MCP session. Transactions
+// left idle for longer than timeout are rolled back.
+type txManager struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	txs     map[string]*sqliteTx
+}
+
+// newTxManagerFromEnv builds the manager with the idle timeout taken from
+// MCP_TX_TIMEOUT (default 5m).
+func newTxManagerFromEnv() *txManager {
+	return &txManager{
+		timeout: envDuration("MCP_TX_TIMEOUT", 5*time.Minute),
+		txs:     make(map[string]*sqliteTx),
+	}
+}
+
+// sessionID returns the MCP session a tool call belongs to.
+func sessionID(ctx context.Context) (string, error) {
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return "", errors.New("transactions require an MCP session")
+	}
+	return session.SessionID(), nil
+}
+
+// begin opens a transaction on db for the session.
+func (m *txManager) begin(session, db string, env []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if tx, ok := m.txs[session]; ok {
+		return toolErrorf(CodeInvalidParam, "a transaction on %s is already open; commit or roll it back first", tx.db)
+	}
+	tx, err := startSQLiteTx(db, env)
+	if err != nil {
+		return err
+	}
+	tx.timer = time.AfterFunc(m.timeout, func() { m.expire(session, tx) })
+	m.txs[session] = tx
+	return nil
+}
+
+// lookup returns the session's open transaction, or nil.
+func (m *txManager) lookup(session string) *sqliteTx {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := m.txs[session]
+	if tx != nil {
+		tx.timer.Reset(m.timeout)
+	}
+	return tx
+}
+
+// finish commits or rolls back the session's transaction and closes it.
+func (m *txManager) finish(session string, commit bool) error {
+	m.mu.Lock()
+	tx, ok := m.txs[session]
+	delete(m.txs, session)
+	m.mu.Unlock()
+	if !ok {
+		return toolErrorf(CodeNotFound, "no open transaction")
+	}
+	tx.timer.Stop()
+	defer tx.close()
+
+	stmt := "ROLLBACK;"
+	if commit {
+		stmt = "COMMIT;"
+	}
+	_, err := tx.exec(stmt)
+	return err
+}
+
+// expire rolls back tx if it is still the session's open transaction.
+func (m *txManager) expire(session string, tx *sqliteTx) {
+	m.mu.Lock()
+	if m.txs[session] != tx {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.txs, session)
+	m.mu.Unlock()
+
+	log.Warnf("Rolling back transaction on %s idle for more than %s", tx.db, m.timeout)
+	tx.close()
+}
+
+// forget rolls back the transaction of a closed session.
+func (m *txManager) forget(ctx context.Context, session server.ClientSession) {
+	if err := m.finish(session.SessionID(), false); err == nil {
+		log.Infof("Rolled back open transaction of closed session %s", session.SessionID())
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// sqlScriptStatement is one statement of a run_sql_file script, without its
+// terminating semicolon. Line is the line of the file it starts on.
+type sqlScriptStatement struct {
+	Text string
+	Line int
+}
+
+// sqlScriptResult is the summary run_sql_file returns after committing.
+// RowsAffected counts the rows inserted, updated or deleted, statement by
+// statement in Rows.
+type sqlScriptResult struct {
+	Dialect      string  `json:"dialect"`
+	Path         string  `json:"path"`
+	Statements   int     `json:"statements"`
+	Executed     int     `json:"executed"`
+	RowsAffected int64   `json:"rows_affected"`
+	Rows         []int64 `json:"rows"`
+}
+
+// sqlScriptFailure is a statement that failed, ending the script. Index
+// counts statements from 1; 0 means the final COMMIT failed.
+type sqlScriptFailure struct {
+	Index    int
+	Line     int
+	Executed int
+	Message  string
+}
+
+// dollarQuote matches the opening tag of a Postgres dollar-quoted string.
+var dollarQuote = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// splitSQLScript splits script into statements at the semicolons outside
+// quotes and comments, and outside the bodies of SQLite triggers. Leading
+// comments are dropped from each statement. Postgres scripts may use
+// dollar quoting and E'...' strings but not psql meta-commands, and SQLite
+// scripts may not hold sqlite3 dot-commands: both could do anything the
+// server can.
+func splitSQLScript(script, dialect string) ([]sqlScriptStatement, error) {
+	var stmts []sqlScriptStatement
+	start, startLine, line := -1, 0, 1
+	begin := func(i int) {
+		if start < 0 {
+			start, startLine = i, line
+		}
+	}
+	// skipTo moves past the first end at or after i, counting lines.
+	skipTo := func(i int, end string, what string) (int, error) {
+		j := strings.Index(script[i:], end)
+		if j < 0 {
+			return 0, toolErrorf(CodeInvalidParam, "unterminated %s starting on line %d", what, line)
+		}
+		line += strings.Count(script[i:i+j], "\n")
+		return i + j + len(end), nil
+	}
+
+	for i := 0; i < len(script); {
+		c := script[i]
+		var err error
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if i, err = skipTo(i+2, "*/", "comment"); err != nil {
+				return nil, err
+			}
+		case c == '\'' || c == '"' || ((c == '`' || c == '[') && dialect == "sqlite"):
+			begin(i)
+			closing := map[byte]byte{'\'': '\'', '"': '"', '`': '`', '[': ']'}[c]
+			escapes := dialect == "postgres" && c == '\'' && i > 0 && (script[i-1] == 'E' || script[i-1] == 'e')
+			opened := line
+			j := i + 1
+			for ; j < len(script); j++ {
+				if escapes && script[j] == '\\' {
+					j++
+					continue
+				}
+				if script[j] == '\n' {
+					line++
+				}
+				if script[j] == closing {
+					// A doubled quote is an escaped one.
+					if closing != ']' && j+1 < len(script) && script[j+1] == closing {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(script) {
+				return nil, toolErrorf(CodeInvalidParam, "unterminated quoted string starting on line %d", opened)
+			}
+			i = j + 1
+		case c == '$' && dialect == "postgres" && dollarQuote.MatchString(script[i:]):
+			begin(i)
+			tag := dollarQuote.FindString(script[i:])
+			if i, err = skipTo(i+len(tag), tag, "dollar-quoted string"); err != nil {
+				return nil, err
+			}
+		case c == '\\' && dialect == "postgres":
+			return nil, toolErrorf(CodeInvalidParam, "line %d: psql meta-commands are not allowed", line)
+		case c == ';':
+			if start >= 0 && !(dialect == "sqlite" && openTrigger(script[start:i])) {
+				stmts = append(stmts, sqlScriptStatement{Text: script[start:i], Line: startLine})
+				start = -1
+			}
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		default:
+			if start < 0 && c == '.' && dialect == "sqlite" {
+				return nil, toolErrorf(CodeInvalidParam, "line %d: sqlite3 dot-commands are not allowed", line)
+			}
+			begin(i)
+			i++
+		}
+	}
+	if start >= 0 {
+		stmts = append(stmts, sqlScriptStatement{Text: script[start:], Line: startLine})
+	}
+
+	for n, s := range stmts {
+		tokens := sqlTokens(s.Text)
+		switch strings.ToUpper(tokens[0]) {
+		case "BEGIN", "START", "COMMIT", "END", "ROLLBACK", "ABORT":
+			return nil, toolErrorf(CodeInvalidParam,
+				"statement %d (line %d): %s is not allowed; run_sql_file runs the whole file in one transaction",
+				n+1, s.Line, strings.ToUpper(tokens[0]))
+		}
+	}
+	return stmts, nil
+}
+
+// openTrigger reports whether stmt is a CREATE TRIGGER whose BEGIN ... END
+// body has not ended yet, so a semicolon inside it does not end the
+// statement.
+func openTrigger(stmt string) bool {
+	tokens := sqlTokens(stmt)
+	for _, tok := range tokens {
+		switch strings.ToUpper(tok) {
+		case "CREATE", "TEMP", "TEMPORARY":
+			continue
+		case "TRIGGER":
+			return !strings.EqualFold(tokens[len(tokens)-1], "END")
+		}
+		return false
+	}
+	return false
+}
+
+// runSQLiteScript runs stmts on db in one transaction with sqlite3. After
+// each statement the script prints a marker with changes() and
+// total_changes(), from which the rows each statement changed are worked
+// out and the statement that failed is found: -bail stops sqlite3 at the
+// first error, leaving the transaction to be rolled back when it exits.
+// changes() leaves out rows changed by triggers but keeps its value over
+// statements that change nothing, which total_changes() tells apart.
+func runSQLiteScript(ctx context.Context, env []string, db string, stmts []sqlScriptStatement) ([]int64, *sqlScriptFailure, error) {
+	marker := "run_sql_file:" + uuid.New().String()
+	var script strings.Builder
+	fmt.Fprintf(&script, "BEGIN;\n.print %s 0\nSELECT changes(), total_changes();\n", marker)
+	for i, s := range stmts {
+		fmt.Fprintf(&script, "%s\n;\n.print %s %d\nSELECT changes(), total_changes();\n", s.Text, marker, i+1)
+	}
+	script.WriteString("COMMIT;\n")
+
+	cmd := toolCommand(ctx, env, "sqlite3", "-bail", "-batch", db)
+	cmd.Stdin = strings.NewReader(script.String())
+	var stdout, stderr bytes.Buffer
+	runErr := runCommand(cmd, &stdout, &stderr)
+	if te, ok := runErr.(*ToolError); ok {
+		return nil, nil, te
+	}
+
+	var changes, totals []int64
+	lines := bufio.NewScanner(&stdout)
+	lines.Buffer(nil, maxCommandOutput())
+	for lines.Scan() {
+		if strings.HasPrefix(lines.Text(), marker+" ") && lines.Scan() {
+			last, total, _ := strings.Cut(strings.TrimSpace(lines.Text()), "|")
+			n, _ := strconv.ParseInt(last, 10, 64)
+			t, _ := strconv.ParseInt(total, 10, 64)
+			changes, totals = append(changes, n), append(totals, t)
+		}
+	}
+	rows := make([]int64, 0, len(stmts))
+	for i := 1; i < len(totals); i++ {
+		if totals[i] == totals[i-1] {
+			rows = append(rows, 0)
+		} else {
+			rows = append(rows, changes[i])
+		}
+	}
+	if runErr == nil {
+		return rows, nil, nil
+	}
+	if len(totals) == 0 {
+		return nil, nil, fmt.Errorf("sqlite3 failed: %v: %s", runErr, strings.TrimSpace(stderr.String()))
+	}
+	return rows, scriptFailure(stmts, len(rows), stderr.String(), runErr), nil
+}
+
+// psqlCommandTag matches the command tags psql prints for statements that
+// change or return rows, ending in the row count.
+var psqlCommandTag = regexp.MustCompile(`^(?:INSERT \d+|UPDATE|DELETE|MERGE|COPY|SELECT) (\d+)$`)
+
+// runPostgresScript runs stmts on the database dsn names in one transaction
+// with psql -1, echoing a marker after each statement. The rows a statement
+// changed come from the command tag psql prints before its marker.
+// ON_ERROR_STOP makes psql stop and roll back at the first error.
+func runPostgresScript(ctx context.Context, env []string, dsn string, stmts []sqlScriptStatement) ([]int64, *sqlScriptFailure, error) {
+	marker := "run_sql_file:" + uuid.New().String()
+	var script strings.Builder
+	for i, s := range stmts {
+		fmt.Fprintf(&script, "%s\n;\n\\echo '%s %d'\n", s.Text, marker, i+1)
+	}
+
+	cmd := toolCommand(ctx, env, "psql", "-X", "-A", "-t", "-1", "-v", "ON_ERROR_STOP=1", "-d", dsn, "-f", "-")
+	cmd.Stdin = strings.NewReader(script.String())
+	var stdout, stderr bytes.Buffer
+	runErr := runCommand(cmd, &stdout, &stderr)
+	if te, ok := runErr.(*ToolError); ok {
+		return nil, nil, te
+	}
+
+	rows := make([]int64, 0, len(stmts))
+	var n int64
+	lines := bufio.NewScanner(&stdout)
+	lines.Buffer(nil, maxCommandOutput())
+	for lines.Scan() {
+		if strings.HasPrefix(lines.Text(), marker+" ") {
+			rows = append(rows, n)
+			n = 0
+		} else if m := psqlCommandTag.FindStringSubmatch(lines.Text()); m != nil {
+			n, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+	}
+	if runErr == nil {
+		return rows, nil, nil
+	}
+	msg := stderr.String()
+	if !strings.Contains(msg, "ERROR:") {
+		// psql never got to run the script, e.g. it could not connect.
+		return nil, nil, fmt.Errorf("psql failed: %v: %s", runErr, strings.TrimSpace(msg))
+	}
+	return rows, scriptFailure(stmts, len(rows), msg, runErr), nil
+}
+
+// scriptErrorPrefix matches the position sqlite3 and psql put before their
+// error messages.
+var scriptErrorPrefix = regexp.MustCompile(`^(?:(?:Parse error|Runtime error|Error:?) near line \d+: |Error: |psql:<stdin>:\d+: (?:ERROR:\s+)?)`)
+
+// scriptFailure describes the statement after the executed ones, which is
+// the one that failed, from the first line of the command's stderr.
+func scriptFailure(stmts []sqlScriptStatement, executed int, stderr string, err error) *sqlScriptFailure {
+	msg, _, _ := strings.Cut(strings.TrimSpace(stderr), "\n")
+	if msg = scriptErrorPrefix.ReplaceAllString(msg, ""); msg == "" {
+		msg = err.Error()
+	}
+	f := &sqlScriptFailure{Executed: executed, Message: msg}
+	if executed < len(stmts) {
+		f.Index, f.Line = executed+1, stmts[executed].Line
+	}
+	return f
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// streamInterval is how long output may wait before being sent, so
+	// chatty tools send batches rather than one notification per line.
+	streamInterval = 250 * time.Millisecond
+	// streamChunkBytes sends pending output early once it grows this large.
+	streamChunkBytes = 8 << 10
+)
+
+// streamingToolHandler is a tool handler that writes its output to w as it
+// is produced, in addition to returning the consolidated result.
+type streamingToolHandler func(ctx context.Context, req mcp.CallToolRequest, w io.Writer) (*mcp.CallToolResult, error)
+
+// streamingHandler adapts h to a regular handler whose partial output is sent
+// to the client as notifications while the call runs, see resultStream.
+func streamingHandler(h streamingToolHandler) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		s := &resultStream{ctx: ctx, req: req}
+		defer s.Close()
+		return h(ctx, req, s)
+	}
+}
+
+// resultStream sends the output written to it to the client of a tool call.
+// Clients that sent a progress token get notifications/progress carrying the
+// output in message, with progress counting the bytes sent; others get
+// notifications/message entries logged under the tool's name.
+type resultStream struct {
+	ctx context.Context
+	req mcp.CallToolRequest
+
+	mu      sync.Mutex
+	pending []byte
+	sent    int
+	timer   *time.Timer
+	closed  bool
+}
+
+func (s *resultStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return len(p), nil
+	}
+	s.pending = append(s.pending, p...)
+	switch {
+	case len(s.pending) >= streamChunkBytes:
+		s.flushLocked()
+	case s.timer == nil:
+		s.timer = time.AfterFunc(streamInterval, s.flush)
+	}
+	return len(p), nil
+}
+
+func (s *resultStream) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked()
+}
+
+func (s *resultStream) flushLocked() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if len(s.pending) == 0 {
+		return
+	}
+	chunk := string(s.pending)
+	s.pending = s.pending[:0]
+	s.sent += len(chunk)
+
+	meta := s.req.Params.Meta
+	if meta == nil || meta.ProgressToken == nil {
+		clientLog(s.ctx, mcp.LoggingLevelInfo, s.req.Params.Name, map[string]any{"output": chunk})
+		return
+	}
+	err := mcpServer.SendNotificationToClient(s.ctx, "notifications/progress", map[string]any{
+		"progressToken": meta.ProgressToken,
+		"progress":      s.sent,
+		"message":       chunk,
+	})
+	if err != nil {
+		requestLog(s.ctx).Debugf("Failed to stream tool output: %v", err)
+	}
+}
+
+// Close sends any pending output; later writes are dropped.
+func (s *resultStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked()
+	s.closed = true
+	return nil
+}
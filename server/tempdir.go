@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type tempDirKey struct{}
+
+// requestTemp is the temporary directory of one tool call, created on first
+// use.
+type requestTemp struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// tempDirMiddleware gives each tool call a temporary directory, see
+// requestTempDir, and removes it with everything in it once the handler
+// returns, on error paths too.
+func tempDirMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		t := &requestTemp{}
+		defer func() {
+			if t.dir == "" {
+				return
+			}
+			if err := os.RemoveAll(t.dir); err != nil {
+				requestLog(ctx).Warnf("Failed to remove temp dir %s: %v", t.dir, err)
+			}
+		}()
+		return next(context.WithValue(ctx, tempDirKey{}, t), req)
+	}
+}
+
+// requestTempDir returns the temporary directory of the tool call in ctx.
+// Files created in it need no cleanup of their own.
+func requestTempDir(ctx context.Context) (string, error) {
+	t, ok := ctx.Value(tempDirKey{}).(*requestTemp)
+	if !ok {
+		return "", fmt.Errorf("no temp dir for this call")
+	}
+	t.once.Do(func() {
+		t.dir, t.err = os.MkdirTemp("", "mcp-call-*")
+	})
+	return t.dir, t.err
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ErrorCode classifies a failed tool call so clients can branch on it.
+type ErrorCode string
+
+const (
+	CodeInvalidParam        ErrorCode = "INVALID_PARAM"
+	CodeNotFound            ErrorCode = "NOT_FOUND"
+	CodePermissionDenied    ErrorCode = "PERMISSION_DENIED"
+	CodeTimeout             ErrorCode = "TIMEOUT"
+	CodeUpstreamUnavailable ErrorCode = "UPSTREAM_UNAVAILABLE"
+	CodeResourceExhausted   ErrorCode = "RESOURCE_EXHAUSTED"
+	CodeCommandFailed       ErrorCode = "COMMAND_FAILED"
+	CodeCancelled           ErrorCode = "CANCELLED"
+	CodeInternal            ErrorCode = "INTERNAL"
+)
+
+// ToolError is a tool failure with a machine-readable code. It is sent to
+// clients as the JSON text of an MCP error result.
+type ToolError struct {
+	Code    ErrorCode      `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+
+	cause error
+}
+
+func (e *ToolError) Error() string { return e.Message }
+
+func (e *ToolError) Unwrap() error { return e.cause }
+
+// toolErrorf builds a ToolError. A %w verb in format keeps the wrapped error
+// reachable through errors.Is and errors.As.
+func toolErrorf(code ErrorCode, format string, args ...any) *ToolError {
+	err := fmt.Errorf(format, args...)
+	return &ToolError{Code: code, Message: err.Error(), cause: errors.Unwrap(err)}
+}
+
+// asToolError returns err as a ToolError, classifying it when it is not one.
+func asToolError(err error) *ToolError {
+	var te *ToolError
+	if errors.As(err, &te) && te.Error() == err.Error() {
+		return te
+	}
+	return &ToolError{Code: classifyError(err), Message: err.Error(), cause: err}
+}
+
+// classifyError picks the code for an arbitrary error.
+func classifyError(err error) ErrorCode {
+	var te *ToolError
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &te):
+		return te.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeCancelled
+	case errors.Is(err, os.ErrNotExist), errdefs.IsNotFound(err):
+		return CodeNotFound
+	case errors.Is(err, os.ErrPermission), errdefs.IsUnauthorized(err), errdefs.IsForbidden(err):
+		return CodePermissionDenied
+	case errors.Is(err, exec.ErrNotFound), client.IsErrConnectionFailed(err), errdefs.IsUnavailable(err):
+		return CodeUpstreamUnavailable
+	case errdefs.IsInvalidParameter(err):
+		return CodeInvalidParam
+	case errors.As(err, &exitErr):
+		return CodeCommandFailed
+	default:
+		return CodeInternal
+	}
+}
+
+// Result renders the error as an MCP error result.
+func (e *ToolError) Result() *mcp.CallToolResult {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("%s: %s", e.Code, e.Message))
+	}
+	return mcp.NewToolResultError(string(b))
+}
+
+// errorResult converts any error into a coded MCP error result.
+func errorResult(err error) *mcp.CallToolResult {
+	return asToolError(err).Result()
+}
+
+// invalidParam reports a missing or malformed tool argument.
+func invalidParam(format string, args ...any) *mcp.CallToolResult {
+	return toolErrorf(CodeInvalidParam, format, args...).Result()
+}
+
+// commandError reports a failed external command, keeping its output in the
+// error details.
+func commandError(msg string, err error, output string) *mcp.CallToolResult {
+	te := asToolError(fmt.Errorf("%s: %w", msg, err))
+	if out := strings.TrimSpace(output); out != "" {
+		te.Details = map[string]any{"output": out}
+	}
+	return te.Result()
+}
+
+// toolErrorMiddleware turns errors returned by handlers into coded error
+// results, so clients always receive a ToolError.
+func toolErrorMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		res, err := next(ctx, req)
+		if err != nil {
+			return errorResult(err), nil
+		}
+		return res, nil
+	}
+}
+
+// recoverMiddleware turns a panic in a tool handler, or in the middleware
+// below it, into an INTERNAL error result so one faulty call cannot take
+// down the server. The stack is logged at debug level. Panics in goroutines
+// started by a handler are not caught.
+func recoverMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (res *mcp.CallToolResult, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				requestLog(ctx).Errorf("Tool '%s' panicked: %v", req.Params.Name, r)
+				requestLog(ctx).Debugf("Stack of the panic in tool '%s':\n%s", req.Params.Name, debug.Stack())
+				te := toolErrorf(CodeInternal, "internal error in tool %s: %v", req.Params.Name, r)
+				te.Details = map[string]any{"panic": fmt.Sprint(r)}
+				res, err = te.Result(), nil
+			}
+		}()
+		return next(ctx, req)
+	}
+}
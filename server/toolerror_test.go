@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// callTool sends a tools/call request for name straight to s.
+func callTool(t *testing.T, s *server.MCPServer, id int, name string) *mcp.CallToolResult {
+	t.Helper()
+	msg := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":%q}}`, id, name)
+	resp, ok := s.HandleMessage(context.Background(), json.RawMessage(msg)).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("call %d to %s did not return a result", id, name)
+	}
+	res, ok := resp.Result.(mcp.CallToolResult)
+	if !ok {
+		t.Fatalf("call %d to %s returned %T, want a tool result", id, name, resp.Result)
+	}
+	return &res
+}
+
+func TestRecoverMiddlewareKeepsServing(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0",
+		server.WithToolCapabilities(false),
+		server.WithToolHandlerMiddleware(toolErrorMiddleware),
+		server.WithToolHandlerMiddleware(recoverMiddleware),
+	)
+	s.AddTool(mcp.NewTool("explode"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var m map[string]int
+		m["boom"]++
+		return nil, nil
+	})
+	s.AddTool(mcp.NewTool("echo"), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("still here"), nil
+	})
+
+	res := callTool(t, s, 1, "explode")
+	if !res.IsError || len(res.Content) != 1 {
+		t.Fatalf("panicking call = %+v, want one error result", res)
+	}
+	var te ToolError
+	if err := json.Unmarshal([]byte(res.Content[0].(mcp.TextContent).Text), &te); err != nil {
+		t.Fatalf("panicking call did not return a ToolError: %v", err)
+	}
+	if te.Code != CodeInternal || te.Details["panic"] == nil {
+		t.Errorf("ToolError = %+v, want an INTERNAL error carrying the panic", te)
+	}
+
+	res = callTool(t, s, 2, "echo")
+	if res.IsError || len(res.Content) != 1 || res.Content[0].(mcp.TextContent).Text != "still here" {
+		t.Errorf("call after the panic = %+v, want the echo result", res)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+// toolExample is a sample call of a tool shown by describe_tool.
+type toolExample struct {
+	Description string         `json:"description"`
+	Arguments   map[string]any `json:"arguments"`
+}
+
+// toolExamples holds hand-written sample calls for each tool. Keep them in
+// step with the tool registrations in main.
+var toolExamples = map[string][]toolExample{
+	"to-markdown": {
+		{"Convert a PDF in the workspace to Markdown", map[string]any{"input": "docs/spec.pdf", "output": "docs/spec.md"}},
+	},
+	"ast-grep": {
+		{"Replace console.log calls with a logger in a directory", map[string]any{"pattern": "console.log($MSG)", "new-pattern": "logger.info($MSG)", "language": "javascript", "path": "src"}},
+	},
+	"ast-grep-undo": {
+		{"Undo a rewrite by the operation id it reported", map[string]any{"operation_id": "3f2b9c1e-8d4a-4b6e-9a0f-1c2d3e4f5a6b"}},
+		{"Undo it even though some files were edited since", map[string]any{"operation_id": "3f2b9c1e-8d4a-4b6e-9a0f-1c2d3e4f5a6b", "force": true}},
+	},
+	"ast-grep-diff": {
+		{"Find fmt.Println calls in uncommitted changes", map[string]any{"pattern": "fmt.Println($$$ARGS)", "language": "go"}},
+		{"Rewrite only the files changed on a branch", map[string]any{"pattern": "errors.Wrap($E, $M)", "new-pattern": "fmt.Errorf($M+\": %w\", $E)", "language": "go", "range": "main..HEAD"}},
+	},
+	"mirrord-exec": {
+		{"Run with a mirrord config from the workspace", map[string]any{"config": ".mirrord/mirrord.json"}},
+	},
+	"pull_image": {
+		{"Pull a tagged image", map[string]any{"image": "nginx:1.27"}},
+	},
+	"docker_resolve_digest": {
+		{"Pin a tag for the Docker daemon's platform", map[string]any{"image": "nginx:1.27"}},
+		{"Resolve the arm64 image", map[string]any{"image": "nginx:1.27", "platform": "linux/arm64"}},
+		{"Use the digest of the local copy", map[string]any{"image": "nginx:1.27", "local": true}},
+	},
+	"docker_image_history": {
+		{"Show the layers of an image", map[string]any{"image": "nginx:latest"}},
+	},
+	"docker_build_cache_info": {
+		{"Analyze a saved build log", map[string]any{"output_file": "build.log"}},
+		{"Analyze pasted output", map[string]any{"build_output": "#5 [2/3] COPY go.mod ./\n#5 CACHED\n#6 [3/3] RUN go build\n#6 DONE 8.1s\n"}},
+	},
+	"docker_image_inspect": {
+		{"Show an image's metadata", map[string]any{"image": "nginx:latest"}},
+		{"Print only its platform", map[string]any{"image": "nginx:latest", "format": "{{.Os}}/{{.Architecture}}"}},
+	},
+	"docker_info": {
+		{"Show Docker daemon information", map[string]any{}},
+	},
+	"docker_list_volumes": {
+		{"List every volume", map[string]any{}},
+		{"List volumes no container uses", map[string]any{"filter": []any{"dangling=true"}}},
+	},
+	"docker_list_networks": {
+		{"List bridge networks", map[string]any{"filter": []any{"driver=bridge"}}},
+	},
+	"disk_usage": {
+		{"Report free space of the workspace and Docker data directory", map[string]any{}},
+	},
+	"docker_image_save": {
+		{"Save an image to a tar file", map[string]any{"image": "nginx:latest", "output_path": "images/nginx.tar"}},
+	},
+	"docker_image_load": {
+		{"Load images from a tar file", map[string]any{"input_path": "images/nginx.tar"}},
+	},
+	"docker_logs_multi": {
+		{"Follow two containers for 30 seconds", map[string]any{"containers": []any{"web", "db"}, "duration": 30}},
+		{"Show only new lines", map[string]any{"containers": []any{"web"}, "tail": 0}},
+	},
+	"dockerfile_lint": {
+		{"Lint a Dockerfile in the workspace", map[string]any{"path": "Dockerfile"}},
+		{"Lint inline content", map[string]any{"content": "FROM ubuntu\nRUN apt-get update && apt-get install curl\n"}},
+	},
+	"docker_exec": {
+		{"List a directory inside a container", map[string]any{"container": "web", "command": "ls", "args": []any{"-la", "/app"}}},
+	},
+	"compose_up": {
+		{"Start the project in a directory", map[string]any{"project_dir": "deploy"}},
+		{"Start with a specific compose file", map[string]any{"project_dir": "deploy", "file": "compose.dev.yaml"}},
+	},
+	"compose_down": {
+		{"Stop the project in a directory", map[string]any{"project_dir": "deploy"}},
+	},
+	"docker_cancel": {
+		{"Stop a pull started earlier", map[string]any{"operation_id": "6f1c2d9e-8a3b-4c5d-9e0f-1a2b3c4d5e6f"}},
+	},
+	"ops_list": {
+		{"See what the server is running", map[string]any{}},
+	},
+	"ops_kill": {
+		{"Stop a stuck call found with ops_list", map[string]any{"operation_id": "6f1c2d9e-8a3b-4c5d-9e0f-1a2b3c4d5e6f"}},
+	},
+	"get_pods": {
+		{"List pods in the current context", map[string]any{}},
+		{"List pods of another cluster", map[string]any{"context": "staging"}},
+	},
+	"k8s_events": {
+		{"Show recent warnings in all namespaces", map[string]any{"type": "Warning"}},
+		{"Show events of one pod", map[string]any{"namespace": "default", "kind": "Pod", "name": "web-7d4b9c6f5-x2kqp"}},
+	},
+	"k8s_top_pods": {
+		{"Show the pods using the most memory in a namespace", map[string]any{"namespace": "default", "sort_by": "memory"}},
+		{"Show CPU usage across the cluster", map[string]any{"all_namespaces": true, "sort_by": "cpu"}},
+	},
+	"k8s_watch_pods": {
+		{"Watch pods of an app for two minutes", map[string]any{"namespace": "default", "selector": "app=web", "timeout": 120}},
+	},
+	"helm_template": {
+		{"Render a chart in the workspace", map[string]any{"chart": "charts/web", "release_name": "web"}},
+		{"Render a repository chart with inline values", map[string]any{"chart": "bitnami/nginx", "values": "replicaCount: 2\n", "namespace": "web"}},
+	},
+	"kustomize_build": {
+		{"Build an overlay", map[string]any{"path": "k8s/overlays/prod"}},
+	},
+	"k8s_diff": {
+		{"Diff a manifest directory against the cluster", map[string]any{"path": "k8s/base"}},
+		{"Diff inline YAML", map[string]any{"manifest": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  mode: prod\n"}},
+	},
+	"kubectl": {
+		{"List pods with their nodes", map[string]any{"verb": "get", "args": []any{"pods", "-n", "default", "-o", "wide"}}},
+		{"Show the last log lines of a deployment", map[string]any{"verb": "logs", "args": []any{"deployment/web", "--tail", "100"}}},
+		{"Apply inline manifests (needs apply in MCP_KUBECTL_VERBS)", map[string]any{"verb": "apply", "args": []any{"-f", "-"}, "stdin": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: staging\n"}},
+	},
+	"pipeline": {
+		{"Count the error lines of a log", map[string]any{"stages": []any{
+			map[string]any{"binary": "grep", "args": []any{"-i", "error", "logs/app.log"}},
+			map[string]any{"binary": "wc", "args": []any{"-l"}},
+		}}},
+		{"Show the most frequent values of a column", map[string]any{"stages": []any{
+			map[string]any{"binary": "cut", "args": []any{"-d,", "-f2", "data/users.csv"}},
+			map[string]any{"binary": "sort"},
+			map[string]any{"binary": "uniq", "args": []any{"-c"}},
+			map[string]any{"binary": "sort", "args": []any{"-rn"}},
+			map[string]any{"binary": "head", "args": []any{"-n", "5"}},
+		}}},
+	},
+	"checksum": {
+		{"Compute the SHA-256 of a file", map[string]any{"path": "dist/app.tar.gz"}},
+		{"Compute the MD5 of a file", map[string]any{"path": "dist/app.tar.gz", "algorithm": "md5"}},
+	},
+	"watch_file": {
+		{"Wait up to two minutes for a build artifact", map[string]any{"path": "dist/app.tar.gz", "timeout": 120}},
+		{"Watch a log directory for ten seconds", map[string]any{"path": "logs", "timeout": 10}},
+	},
+	"create_archive": {
+		{"Archive a directory", map[string]any{"source": "dist", "output": "dist.tar.gz"}},
+	},
+	"extract_archive": {
+		{"Unpack an archive", map[string]any{"archive": "dist.tar.gz", "destination": "unpacked"}},
+	},
+	"render_template": {
+		{"Render an inline template", map[string]any{"template": "replicas: {{.replicas}}", "data": map[string]any{"replicas": 3}}},
+		{"Render a template file to a manifest", map[string]any{"template_file": "templates/deploy.yaml.tmpl", "data": map[string]any{"image": "web:1.2.0"}, "output": "k8s/deploy.yaml"}},
+	},
+	"validate_manifest": {
+		{"Check Kubernetes manifests in the workspace", map[string]any{"path": "deploy/app.yaml"}},
+		{"Check inline JSON syntax only", map[string]any{"content": "{\"replicas\": 3}", "kind": "json"}},
+	},
+	"convert_format": {
+		{"Convert inline JSON to YAML", map[string]any{"content": `{"name": "web", "replicas": 2}`, "from": "json", "to": "yaml"}},
+		{"Convert a TOML config file to JSON in the workspace", map[string]any{"input_file": "config/app.toml", "to": "json", "output": "config/app.json"}},
+	},
+	"git_init": {
+		{"Initialize a repository", map[string]any{"directory": "projects/api"}},
+	},
+	"create_table": {
+		{"Create a Postgres table with one row", map[string]any{"table_name": "users", "headers": "id SERIAL PRIMARY KEY, name TEXT", "values": "DEFAULT, 'alice'"}},
+	},
+	"postgres_table_stats": {
+		{"Report tables in the public schema", map[string]any{"schema": "public"}},
+	},
+	"read-query": {
+		{"Read the first page of a table", map[string]any{"db": "data/app.db", "query": "SELECT id, name FROM users ORDER BY id", "limit": 100}},
+		{"Read the next page", map[string]any{"db": "data/app.db", "query": "SELECT id, name FROM users ORDER BY id", "limit": 100, "offset": 100}},
+	},
+	"write-query": {
+		{"Update rows", map[string]any{"db": "data/app.db", "query": "UPDATE users SET active = 0 WHERE last_login < '2024-01-01'"}},
+	},
+	"bulk_insert": {
+		{"Insert several rows", map[string]any{"db": "data/app.db", "table": "users", "columns": []any{"id", "name"}, "rows": []any{[]any{1, "alice"}, []any{2, "bob"}}}},
+	},
+	"run_sql_file": {
+		{"Run a script on a SQLite DB", map[string]any{"db": "data/app.db", "path": "sql/seed.sql"}},
+		{"Run a script on Postgres", map[string]any{"dsn": "postgres://app@localhost:5432/app", "path": "sql/backfill.sql", "timeout": 300}},
+	},
+	"begin_transaction": {
+		{"Start a transaction", map[string]any{"db": "data/app.db"}},
+	},
+	"commit_transaction": {
+		{"Commit the session's transaction", map[string]any{}},
+	},
+	"rollback_transaction": {
+		{"Roll back the session's transaction", map[string]any{}},
+	},
+	"create-SQLtable": {
+		{"Create a table", map[string]any{"db": "data/app.db", "definition": "CREATE TABLE users(id INTEGER PRIMARY KEY, name TEXT);"}},
+	},
+	"list-tables": {
+		{"List the tables of a database", map[string]any{"db": "data/app.db"}},
+	},
+	"sqlite_schema": {
+		{"Show the schema of one table", map[string]any{"db": "data/app.db", "table": "users"}},
+	},
+	"validate_sql": {
+		{"Check a query against a SQLite database", map[string]any{"query": "SELECT name FROM users WHERE id = 1", "dialect": "sqlite", "db": "data/app.db"}},
+		{"Check Postgres syntax", map[string]any{"query": "SELECT now()::date", "dialect": "postgres"}},
+	},
+	"format_sql": {
+		{"Format and lint a query", map[string]any{"query": "select * from users where id=1", "dialect": "postgres"}},
+	},
+	"create_index": {
+		{"Index a SQLite table", map[string]any{"dialect": "sqlite", "db": "data/app.db", "table": "users", "columns": []any{"email"}, "unique": true}},
+		{"Index a Postgres table", map[string]any{"dialect": "postgres", "table": "public.orders", "columns": []any{"customer_id", "created_at DESC"}}},
+	},
+	"get_secret": {
+		{"Reference a database password", map[string]any{"key": "db_password"}},
+		{"Reference a nested key of the vault file", map[string]any{"key": "registry.token"}},
+	},
+	"docker_ping": {
+		{"Check that Docker answers", map[string]any{}},
+	},
+	"postgres_ping": {
+		{"Check that Postgres answers", map[string]any{}},
+	},
+	"sqlite_ping": {
+		{"Check that a database opens", map[string]any{"db": "data/app.db"}},
+	},
+	"k8s_ping": {
+		{"Check the API server of a context", map[string]any{"context": "staging"}},
+	},
+	"list_tools": {
+		{"List every tool", map[string]any{}},
+	},
+	"describe_tool": {
+		{"Describe one tool", map[string]any{"name": "read-query"}},
+	},
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+)
+
+type toolPriority int
+
+const (
+	priorityLow toolPriority = iota
+	priorityNormal
+	priorityHigh
+)
+
+var priorityNames = [...]string{"low", "normal", "high"}
+
+func (p toolPriority) String() string { return priorityNames[p] }
+
+// defaultToolPriorities favours quick introspection tools over builds, pulls
+// and other long operations. Unlisted tools run at normal priority.
+var defaultToolPriorities = map[string]toolPriority{
+	"docker_ping":           priorityHigh,
+	"postgres_ping":         priorityHigh,
+	"sqlite_ping":           priorityHigh,
+	"k8s_ping":              priorityHigh,
+	"list_tools":            priorityHigh,
+	"describe_tool":         priorityHigh,
+	"docker_info":           priorityHigh,
+	"docker_list_volumes":   priorityHigh,
+	"docker_list_networks":  priorityHigh,
+	"docker_image_inspect":  priorityHigh,
+	"docker_image_history":  priorityHigh,
+	"docker_resolve_digest": priorityHigh,
+	"get_pods":              priorityHigh,
+	"k8s_events":            priorityHigh,
+	"k8s_top_pods":          priorityHigh,
+	"read-query":            priorityHigh,
+	"list-tables":           priorityHigh,
+	"sqlite_schema":         priorityHigh,
+	"validate_sql":          priorityHigh,
+	"validate_manifest":     priorityHigh,
+	"format_sql":            priorityHigh,
+	"checksum":              priorityHigh,
+
+	"pull_image":        priorityLow,
+	"docker_image_save": priorityLow,
+	"docker_image_load": priorityLow,
+	"compose_up":        priorityLow,
+	"compose_down":      priorityLow,
+	"helm_template":     priorityLow,
+	"kustomize_build":   priorityLow,
+	"k8s_diff":          priorityLow,
+	"to-markdown":       priorityLow,
+	"create_archive":    priorityLow,
+	"extract_archive":   priorityLow,
+	"bulk_insert":       priorityLow,
+	"run_sql_file":      priorityLow,
+	"ast-grep":          priorityLow,
+}
+
+// unqueuedTools control other calls and run at once, without a worker, so
+// they still work when every worker is busy with the calls they control.
+var unqueuedTools = map[string]bool{
+	"docker_cancel": true,
+	"ops_list":      true,
+	"ops_kill":      true,
+}
+
+// queuedCall is a tool call waiting for a worker; ready is closed when it
+// is handed one.
+type queuedCall struct {
+	ready chan struct{}
+}
+
+// toolQueue runs at most workers tool calls at once. Calls beyond that wait,
+// and a freed worker goes to the oldest waiting call of the highest
+// priority, so cheap reads are not stuck behind queued builds.
+type toolQueue struct {
+	mu         sync.Mutex
+	workers    int
+	running    int
+	waiting    [len(priorityNames)][]*queuedCall
+	priorities map[string]toolPriority
+}
+
+// newToolQueueFromEnv builds the queue from MCP_TOOL_WORKERS and
+// MCP_TOOL_PRIORITIES (e.g. "pull_image=low,my_tool=high"), which overrides
+// defaultToolPriorities. It returns nil, disabling the queue, when
+// MCP_TOOL_WORKERS is unset or not positive.
+func newToolQueueFromEnv() *toolQueue {
+	workers := envInt("MCP_TOOL_WORKERS", 0)
+	if workers <= 0 {
+		return nil
+	}
+	q := &toolQueue{workers: workers, priorities: make(map[string]toolPriority)}
+	for name, p := range defaultToolPriorities {
+		q.priorities[name] = p
+	}
+	for _, entry := range strings.Split(os.Getenv("MCP_TOOL_PRIORITIES"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, level, _ := strings.Cut(entry, "=")
+		p, ok := parsePriority(strings.TrimSpace(level))
+		if name = strings.TrimSpace(name); name == "" || !ok {
+			log.Warnf("Ignoring invalid MCP_TOOL_PRIORITIES entry %q; want tool=low|normal|high", entry)
+			continue
+		}
+		q.priorities[name] = p
+	}
+	log.Infof("Tool queue enabled with %d workers", workers)
+	return q
+}
+
+func parsePriority(s string) (toolPriority, bool) {
+	for i, name := range priorityNames {
+		if strings.EqualFold(s, name) {
+			return toolPriority(i), true
+		}
+	}
+	return 0, false
+}
+
+func (q *toolQueue) priority(tool string) toolPriority {
+	if p, ok := q.priorities[tool]; ok {
+		return p
+	}
+	return priorityNormal
+}
+
+// acquire takes a worker, waiting in line at priority p until one is free
+// or ctx is done.
+func (q *toolQueue) acquire(ctx context.Context, p toolPriority) error {
+	q.mu.Lock()
+	if q.running < q.workers {
+		q.running++
+		q.mu.Unlock()
+		return nil
+	}
+	call := &queuedCall{ready: make(chan struct{})}
+	q.waiting[p] = append(q.waiting[p], call)
+	q.mu.Unlock()
+
+	select {
+	case <-call.ready:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		for i, c := range q.waiting[p] {
+			if c == call {
+				q.waiting[p] = append(q.waiting[p][:i], q.waiting[p][i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// Handed a worker just as ctx ended; pass it on.
+		q.releaseLocked()
+		return ctx.Err()
+	}
+}
+
+func (q *toolQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked()
+}
+
+// releaseLocked hands the worker to the next waiting call, if any.
+func (q *toolQueue) releaseLocked() {
+	for p := len(q.waiting) - 1; p >= 0; p-- {
+		if len(q.waiting[p]) > 0 {
+			next := q.waiting[p][0]
+			q.waiting[p] = q.waiting[p][1:]
+			close(next.ready)
+			return
+		}
+	}
+	q.running--
+}
+
+// middleware runs every tool call on a worker from the queue.
+func (q *toolQueue) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if unqueuedTools[req.Params.Name] {
+			return next(ctx, req)
+		}
+		p := q.priority(req.Params.Name)
+		if err := q.acquire(ctx, p); err != nil {
+			requestLog(ctx).Warnf("Tool '%s' gave up waiting in the %s priority queue: %v", req.Params.Name, p, err)
+			return errorResult(toolErrorf(CodeTimeout, "cancelled while queued: %v", err)), nil
+		}
+		defer q.release()
+		return next(ctx, req)
+	}
+}
+
+// writeMetrics writes the queue depth per priority and the busy workers in
+// the Prometheus text format.
+func (q *toolQueue) writeMetrics(w io.Writer) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	fmt.Fprintln(w, "# HELP mcp_tool_queue_depth Tool calls waiting for a worker by priority.")
+	fmt.Fprintln(w, "# TYPE mcp_tool_queue_depth gauge")
+	for p, calls := range q.waiting {
+		fmt.Fprintf(w, "mcp_tool_queue_depth{priority=%q} %d\n", toolPriority(p), len(calls))
+	}
+	fmt.Fprintln(w, "# HELP mcp_tool_workers_busy Tool calls currently running on a worker.")
+	fmt.Fprintln(w, "# TYPE mcp_tool_workers_busy gauge")
+	fmt.Fprintf(w, "mcp_tool_workers_busy %d\n", q.running)
+}
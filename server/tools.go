@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registeredTools returns the tools srv advertises, exactly as a tools/list
+// request over MCP would, so the result stays in sync with the registrations
+// without keeping a second copy of them.
+func registeredTools(ctx context.Context, srv *server.MCPServer) ([]mcp.Tool, error) {
+	msg := json.RawMessage(`{"jsonrpc":"2.0","id":"list_tools","method":"tools/list"}`)
+	switch resp := srv.HandleMessage(ctx, msg).(type) {
+	case mcp.JSONRPCResponse:
+		res, ok := resp.Result.(mcp.ListToolsResult)
+		if !ok {
+			return nil, fmt.Errorf("unexpected tools/list result %T", resp.Result)
+		}
+		return res.Tools, nil
+	case mcp.JSONRPCError:
+		return nil, fmt.Errorf("tools/list failed: %s", resp.Error.Message)
+	default:
+		return nil, fmt.Errorf("unexpected tools/list response %T", resp)
+	}
+}
+
+// toolArgument describes one argument of a tool for describe_tool.
+type toolArgument struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type,omitempty"`
+	Required    bool     `json:"required"`
+	Description string   `json:"description,omitempty"`
+	Enum        []any    `json:"enum,omitempty"`
+	Default     any      `json:"default,omitempty"`
+	Items       any      `json:"items,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+}
+
+// toolDescription is the full definition of a tool returned by describe_tool.
+type toolDescription struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Arguments   []toolArgument `json:"arguments"`
+	Examples    []toolExample  `json:"examples,omitempty"`
+}
+
+// describeTool flattens the input schema of t into a list of arguments,
+// required ones first, and adds the tool's examples.
+func describeTool(t mcp.Tool) toolDescription {
+	d := toolDescription{
+		Name:        t.Name,
+		Description: t.Description,
+		Arguments:   []toolArgument{},
+		Examples:    toolExamples[t.Name],
+	}
+	for name, p := range t.InputSchema.Properties {
+		prop, _ := p.(map[string]any)
+		arg := toolArgument{
+			Name:     name,
+			Required: slices.Contains(t.InputSchema.Required, name),
+			Default:  prop["default"],
+			Items:    prop["items"],
+		}
+		for alias, canonical := range argAliases[t.Name] {
+			if canonical == name {
+				arg.Aliases = append(arg.Aliases, alias)
+			}
+		}
+		slices.Sort(arg.Aliases)
+		arg.Type, _ = prop["type"].(string)
+		arg.Description, _ = prop["description"].(string)
+		switch enum := prop["enum"].(type) {
+		case []string:
+			for _, v := range enum {
+				arg.Enum = append(arg.Enum, v)
+			}
+		case []any:
+			arg.Enum = enum
+		}
+		d.Arguments = append(d.Arguments, arg)
+	}
+	sort.Slice(d.Arguments, func(i, j int) bool {
+		a, b := d.Arguments[i], d.Arguments[j]
+		if a.Required != b.Required {
+			return a.Required
+		}
+		return a.Name < b.Name
+	})
+	return d
+}
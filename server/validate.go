@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// argValidator checks the arguments of a tool call against the tool's input
+// schema before the handler runs. Every missing or invalid argument is
+// reported in one error, so a caller can fix them all in one go instead of
+// learning about them from the handler one at a time.
+type argValidator struct {
+	mu      sync.Mutex
+	schemas map[string]mcp.ToolInputSchema
+}
+
+// argProblems collects what is wrong with a call's arguments.
+type argProblems struct {
+	Missing      []string          `json:"missing,omitempty"`
+	InvalidType  map[string]string `json:"invalid_type,omitempty"`
+	InvalidValue map[string]string `json:"invalid_value,omitempty"`
+}
+
+// schema returns the input schema of tool, loading the schemas of every
+// tool from srv on first use.
+func (v *argValidator) schema(ctx context.Context, srv *server.MCPServer, tool string) (mcp.ToolInputSchema, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.schemas == nil {
+		tools, err := registeredTools(ctx, srv)
+		if err != nil {
+			requestLog(ctx).Warnf("Cannot load tool schemas, skipping argument validation: %v", err)
+			return mcp.ToolInputSchema{}, false
+		}
+		v.schemas = make(map[string]mcp.ToolInputSchema, len(tools))
+		for _, t := range tools {
+			v.schemas[t.Name] = t.InputSchema
+		}
+	}
+	s, ok := v.schemas[tool]
+	return s, ok
+}
+
+// validateArgs checks args against schema. Null values count as absent and
+// empty strings as missing when the argument is required.
+func validateArgs(schema mcp.ToolInputSchema, args map[string]any) argProblems {
+	var p argProblems
+	for _, name := range schema.Required {
+		switch v := args[name].(type) {
+		case nil:
+			p.Missing = append(p.Missing, name)
+		case string:
+			if v == "" {
+				p.Missing = append(p.Missing, name)
+			}
+		}
+	}
+	sort.Strings(p.Missing)
+	for name, value := range args {
+		prop, _ := schema.Properties[name].(map[string]any)
+		if prop == nil || value == nil {
+			continue
+		}
+		if want, _ := prop["type"].(string); want != "" && !hasSchemaType(value, want) {
+			if p.InvalidType == nil {
+				p.InvalidType = map[string]string{}
+			}
+			p.InvalidType[name] = fmt.Sprintf("expected %s, got %s", want, jsonType(value))
+			continue
+		}
+		if s, ok := value.(string); ok && s != "" {
+			if enum := enumStrings(prop["enum"]); enum != nil && !slices.Contains(enum, s) {
+				if p.InvalidValue == nil {
+					p.InvalidValue = map[string]string{}
+				}
+				p.InvalidValue[name] = "expected one of " + strings.Join(enum, ", ")
+			}
+		}
+	}
+	return p
+}
+
+// empty reports whether no problem was found.
+func (p argProblems) empty() bool {
+	return len(p.Missing) == 0 && len(p.InvalidType) == 0 && len(p.InvalidValue) == 0
+}
+
+// String lists the problems, e.g. "missing: db, query; invalid type: limit
+// (expected number, got string)".
+func (p argProblems) String() string {
+	var parts []string
+	if len(p.Missing) > 0 {
+		parts = append(parts, "missing: "+strings.Join(p.Missing, ", "))
+	}
+	for _, group := range []struct {
+		label    string
+		problems map[string]string
+	}{{"invalid type", p.InvalidType}, {"invalid value", p.InvalidValue}} {
+		if len(group.problems) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(group.problems))
+		for name := range group.problems {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			names[i] = fmt.Sprintf("%s (%s)", name, group.problems[name])
+		}
+		parts = append(parts, group.label+": "+strings.Join(names, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// hasSchemaType reports whether a decoded JSON value has the JSON schema
+// type want.
+func hasSchemaType(v any, want string) bool {
+	switch want {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+// jsonType names the JSON type of a decoded value.
+func jsonType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// enumStrings returns the allowed values of a string enum, or nil.
+func enumStrings(enum any) []string {
+	switch enum := enum.(type) {
+	case []string:
+		return enum
+	case []any:
+		var out []string
+		for _, v := range enum {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// middleware rejects calls with missing or invalid arguments, listing every
+// problem in the message and, by kind, in the error details.
+func (v *argValidator) middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		srv := server.ServerFromContext(ctx)
+		if srv == nil {
+			return next(ctx, req)
+		}
+		schema, ok := v.schema(ctx, srv, req.Params.Name)
+		if !ok {
+			return next(ctx, req)
+		}
+		problems := validateArgs(schema, req.Params.Arguments)
+		if problems.empty() {
+			return next(ctx, req)
+		}
+		err := toolErrorf(CodeInvalidParam, "invalid arguments: %s", problems)
+		err.Details = map[string]any{"problems": problems}
+		requestLog(ctx).Warnf("Rejected tool '%s': %v", req.Params.Name, err)
+		return err.Result(), nil
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// maxWatchEvents bounds the events one watch_file call collects; later ones
+// are only counted.
+const maxWatchEvents = 1000
+
+// fileEvent is one change seen by watch_file.
+type fileEvent struct {
+	Time string `json:"time"`
+	Op   string `json:"op"`
+	Path string `json:"path"`
+}
+
+// watchReport is the result of watch_file.
+type watchReport struct {
+	Path    string      `json:"path"`
+	Watched string      `json:"watched"`
+	Events  []fileEvent `json:"events"`
+	Dropped int         `json:"dropped,omitempty"`
+}
+
+// watchFile reports changes to target, a path inside the workspace root,
+// until ctx is done. A directory is watched for changes to its entries (not
+// recursively). A file is watched through its directory, so files that do
+// not exist yet and files replaced by rename, as editors and build tools
+// do, are seen too. Each event is also written to w as it happens.
+func watchFile(ctx context.Context, root, target string, w io.Writer) (*watchReport, error) {
+	dir, name := target, ""
+	fi, err := os.Stat(target)
+	switch {
+	case err == nil && fi.IsDir():
+	case err == nil || os.IsNotExist(err):
+		dir, name = filepath.Dir(target), filepath.Base(target)
+		if di, err := os.Stat(dir); err != nil || !di.IsDir() {
+			return nil, toolErrorf(CodeNotFound, "no such directory: %s", dir)
+		}
+	default:
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, toolErrorf(CodeResourceExhausted, "cannot create file watcher: %v", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return nil, toolErrorf(CodeResourceExhausted, "cannot watch %s: %v", dir, err)
+	}
+
+	rep := &watchReport{Path: relPath(root, target), Watched: relPath(root, dir), Events: []fileEvent{}}
+	for {
+		select {
+		case <-ctx.Done():
+			return rep, nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return rep, nil
+			}
+			requestLog(ctx).Warnf("File watcher error on %s: %v", dir, err)
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return rep, nil
+			}
+			if name != "" && filepath.Base(ev.Name) != name {
+				continue
+			}
+			if len(rep.Events) == maxWatchEvents {
+				rep.Dropped++
+				continue
+			}
+			e := fileEvent{Time: time.Now().UTC().Format(time.RFC3339Nano), Op: ev.Op.String(), Path: relPath(root, ev.Name)}
+			rep.Events = append(rep.Events, e)
+			fmt.Fprintf(w, "%s %s %s\n", e.Time, e.Op, e.Path)
+		}
+	}
+}
+
+// relPath returns p relative to the workspace root, or p itself when it is
+// not below it.
+func relPath(root, p string) string {
+	if rel, err := filepath.Rel(root, p); err == nil {
+		return rel
+	}
+	return p
+}
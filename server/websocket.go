@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+// wsSession is the MCP session of one WebSocket connection.
+type wsSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+	logLevel      atomic.Value // mcp.LoggingLevel
+}
+
+func (s *wsSession) SessionID() string { return s.id }
+
+func (s *wsSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *wsSession) Initialize() { s.initialized.Store(true) }
+
+func (s *wsSession) Initialized() bool { return s.initialized.Load() }
+
+// SetLogLevel and GetLogLevel let the client call logging/setLevel; the
+// level itself is applied by clientLog.
+func (s *wsSession) SetLogLevel(level mcp.LoggingLevel) { s.logLevel.Store(level) }
+
+func (s *wsSession) GetLogLevel() mcp.LoggingLevel {
+	if level, ok := s.logLevel.Load().(mcp.LoggingLevel); ok {
+		return level
+	}
+	return defaultClientLogLevel()
+}
+
+// wsHandler serves MCP over WebSocket. Every text frame from the client is a
+// JSON-RPC message handled by srv as if it had arrived over SSE; responses
+// and server-initiated notifications go back as text frames on the same
+// connection.
+func wsHandler(srv *server.MCPServer) http.Handler {
+	origins := allowedWSOrigins()
+	return websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			return checkWSOrigin(origins, r.Header.Get("Origin"))
+		},
+		Handler: func(ws *websocket.Conn) {
+			serveWebSocket(srv, ws)
+		},
+	}
+}
+
+// allowedWSOrigins returns the browser origins that may open a WebSocket,
+// read from the comma-separated MCP_WS_ORIGINS (e.g.
+// "http://localhost:3000"). When it is unset no browser origin is allowed.
+func allowedWSOrigins() map[string]bool {
+	allowed := make(map[string]bool)
+	for _, o := range strings.Split(os.Getenv("MCP_WS_ORIGINS"), ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			allowed[strings.ToLower(o)] = true
+		}
+	}
+	return allowed
+}
+
+// checkWSOrigin accepts a handshake without an Origin header, as sent by
+// non-browser clients, and one from an allowed origin. Anything else could
+// be a web page the user happens to visit calling tools through their
+// browser (cross-site WebSocket hijacking), so it is rejected.
+func checkWSOrigin(allowed map[string]bool, origin string) error {
+	if origin == "" || allowed[strings.ToLower(origin)] {
+		return nil
+	}
+	log.Warnf("Rejected WebSocket connection from origin %q (see MCP_WS_ORIGINS)", origin)
+	return errors.New("websocket: origin not allowed")
+}
+
+func serveWebSocket(srv *server.MCPServer, ws *websocket.Conn) {
+	defer ws.Close()
+	ws.PayloadType = websocket.TextFrame
+
+	session := &wsSession{
+		id:            uuid.New().String(),
+		notifications: make(chan mcp.JSONRPCNotification, 100),
+	}
+	ctx, cancel := context.WithCancel(withRemoteAddr(ws.Request().Context(), ws.Request()))
+	defer cancel()
+	if err := srv.RegisterSession(ctx, session); err != nil {
+		log.Errorf("Failed to register WebSocket session: %v", err)
+		return
+	}
+	defer srv.UnregisterSession(context.Background(), session.id)
+	ctx = srv.WithContext(ctx, session)
+	log.Infof("WebSocket session %s opened from %s", session.id, ws.Request().RemoteAddr)
+
+	// Responses and notifications are written from different goroutines.
+	var writeMu sync.Mutex
+	send := func(msg any) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := websocket.JSON.Send(ws, msg); err != nil {
+			log.Debugf("WebSocket session %s: write failed: %v", session.id, err)
+			cancel()
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case n := <-session.notifications:
+				send(n)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+	for ctx.Err() == nil {
+		var msg []byte
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Debugf("WebSocket session %s: read failed: %v", session.id, err)
+			}
+			break
+		}
+		// Handle messages concurrently so a long tool call does not hold up
+		// pings or cancellations sent after it.
+		inflight.Add(1)
+		go func(msg json.RawMessage) {
+			defer inflight.Done()
+			if resp := srv.HandleMessage(withRequestLog(ctx, msg), msg); resp != nil {
+				send(resp)
+			}
+		}(msg)
+	}
+	cancel()
+	log.Infof("WebSocket session %s closed", session.id)
+}
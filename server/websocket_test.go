@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+	"golang.org/x/net/websocket"
+)
+
+func TestCheckWSOrigin(t *testing.T) {
+	t.Setenv("MCP_WS_ORIGINS", "http://localhost:3000, https://Console.example.com/")
+	allowed := allowedWSOrigins()
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://localhost:3000", true},
+		{"https://console.example.com", true},
+		{"http://localhost:3001", false},
+		{"https://evil.example", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		if err := checkWSOrigin(allowed, tt.origin); (err == nil) != tt.ok {
+			t.Errorf("checkWSOrigin(%q) = %v, want allowed %v", tt.origin, err, tt.ok)
+		}
+	}
+}
+
+func TestWebSocketHandshakeChecksOrigin(t *testing.T) {
+	t.Setenv("MCP_WS_ORIGINS", "http://localhost:3000")
+	ts := httptest.NewServer(wsHandler(server.NewMCPServer("test", "1.0.0")))
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	if ws, err := websocket.Dial(url, "", "https://evil.example"); err == nil {
+		ws.Close()
+		t.Error("handshake from a foreign origin succeeded")
+	}
+	ws, err := websocket.Dial(url, "", "http://localhost:3000")
+	if err != nil {
+		t.Fatalf("handshake from an allowed origin: %v", err)
+	}
+	ws.Close()
+}
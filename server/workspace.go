@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workspaceRoot is the directory file-based tools are confined to. It comes
+// from MCP_WORKSPACE and defaults to the server's working directory.
+func workspaceRoot() (string, error) {
+	root := os.Getenv("MCP_WORKSPACE")
+	if root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to determine workspace root: %v", err)
+		}
+		root = wd
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid workspace root %q: %v", root, err)
+	}
+	// Resolve symlinks so the containment check compares real paths.
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	return abs, nil
+}
+
+// workspacePath resolves p (absolute, or relative to the workspace root) and
+// rejects it when it points outside the workspace, including via symlinks.
+func workspacePath(p string) (string, error) {
+	root, err := workspaceRoot()
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	p = filepath.Clean(p)
+	resolved := resolveExisting(p)
+	if !within(root, resolved) {
+		return "", toolErrorf(CodePermissionDenied, "path %q is outside the workspace %q", p, root)
+	}
+	return resolved, nil
+}
+
+// resolveExisting resolves symlinks on the longest existing prefix of the
+// clean path p; the rest may not exist yet (e.g. an output file about to be
+// written) and is appended as it is.
+func resolveExisting(p string) string {
+	resolved, rest := p, ""
+	for {
+		if real, err := filepath.EvalSymlinks(resolved); err == nil {
+			return filepath.Join(real, rest)
+		}
+		parent := filepath.Dir(resolved)
+		if parent == resolved {
+			return p
+		}
+		rest = filepath.Join(filepath.Base(resolved), rest)
+		resolved = parent
+	}
+}
+
+// within reports whether path is root or lies below it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}